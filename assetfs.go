@@ -0,0 +1,47 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stojg/cspace/lib/overlayfs"
+	"github.com/stojg/cspace/lib/shaders"
+)
+
+// embeddedAssets are the default shaders, models, textures and scenes, built into the binary so that it runs from
+// anywhere
+//
+//go:embed shaders models textures scenes
+var embeddedAssets embed.FS
+
+// assetFS is where the shaders, models and textures are loaded from, with paths like "models/cube" relative to the
+// root of the repository
+var assetFS fs.FS = embeddedAssets
+
+// useAssetDir lays the files in dir over the embedded assets, so that they can be changed or added to without
+// rebuilding. An empty dir only uses the embedded assets.
+func useAssetDir(dir string) error {
+	assetFS = embeddedAssets
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return err
+		}
+		assetFS = overlayfs.New(os.DirFS(dir), embeddedAssets)
+	}
+	shaders.Files = assetFS
+	return nil
+}
+
+// watchAssetDir reloads the models and textures in the asset directory when they're changed
+func watchAssetDir(dir string) {
+	newFileWatcher(filepath.Join(dir, "textures"), filepath.Join(dir, "models")).Watch(time.Second, func(file string) {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return
+		}
+		uploads.push(func() { assets.Reload(filepath.ToSlash(rel)) })
+	})
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/stojg/cspace/lib/meshcache"
+)
+
+// assets is the manager used by LoadTexture, GetTexture and LoadModel
+var assets = NewAssets()
+
+// Assets caches textures and models by their path and load options, so that each is only read and uploaded once.
+// Every call to Texture or Model adds a reference that is handed back with ReleaseTexture or ReleaseModel, the OpenGL
+// objects are deleted when the last reference is released.
+type Assets struct {
+	textures map[textureKey]*textureAsset
+	models   map[modelKey]*modelAsset
+}
+
+type textureKey struct {
+	file         string
+	textureType  TextureType
+	gammaCorrect bool
+	channels     Channels
+}
+
+type textureAsset struct {
+	key     textureKey
+	texture *Texture
+	refs    int
+	owned   bool // false while the texture shows a placeholder or fallback that must not be deleted
+}
+
+type modelKey struct {
+	directory  string
+	shaderType ShaderType
+}
+
+type modelAsset struct {
+	key     modelKey
+	meshes  []*Mesh
+	done    bool // false while the model is loading
+	refs    int
+	waiting []func([]*Mesh)
+	// every mesh handed out from the model, so that they can be updated when the model is reloaded
+	handedOut []*Mesh
+}
+
+func NewAssets() *Assets {
+	return &Assets{
+		textures: make(map[textureKey]*textureAsset),
+		models:   make(map[modelKey]*modelAsset),
+	}
+}
+
+// Texture returns the shared texture for the file, loading it on first use
+func (a *Assets) Texture(texType TextureType, file string, gammaCorrect bool) (*Texture, error) {
+	return a.sharedTexture(textureKey{file: path.Clean(file), textureType: texType, gammaCorrect: gammaCorrect})
+}
+
+// PackedTexture returns the shared texture for the file that carries one property per channel, loading it on first
+// use. Packed textures are linear.
+func (a *Assets) PackedTexture(file string, channels Channels) (*Texture, error) {
+	return a.sharedTexture(textureKey{file: path.Clean(file), textureType: Packed, channels: channels})
+}
+
+func (a *Assets) sharedTexture(key textureKey) (*Texture, error) {
+	if asset, ok := a.textures[key]; ok {
+		asset.refs++
+		return asset.texture, nil
+	}
+	texture, err := newTexture(key.textureType, key.file, key.gammaCorrect)
+	if err != nil {
+		return nil, err
+	}
+	texture.channels = key.channels
+	texture.asset = &textureAsset{key: key, texture: texture, refs: 1, owned: true}
+	a.textures[key] = texture.asset
+	return texture, nil
+}
+
+// TextureAsync returns the shared texture for the file. On first use it returns a placeholder and decodes the file
+// on a worker goroutine, the texture is swapped in when the upload queue is drained. Files that fail to load are
+// logged and replaced with the fallback texture.
+func (a *Assets) TextureAsync(texType TextureType, file string, gammaCorrect bool) *Texture {
+	return a.sharedTextureAsync(textureKey{file: path.Clean(file), textureType: texType, gammaCorrect: gammaCorrect})
+}
+
+// PackedTextureAsync is TextureAsync for textures that carry one property per channel
+func (a *Assets) PackedTextureAsync(file string, channels Channels) *Texture {
+	return a.sharedTextureAsync(textureKey{file: path.Clean(file), textureType: Packed, channels: channels})
+}
+
+func (a *Assets) sharedTextureAsync(key textureKey) *Texture {
+	if asset, ok := a.textures[key]; ok {
+		asset.refs++
+		return asset.texture
+	}
+	texture := placeholderTexture(key.textureType, key.channels)
+	asset := &textureAsset{key: key, texture: texture, refs: 1}
+	texture.asset = asset
+	a.textures[key] = asset
+
+	loadAsync(func() {
+		data, err := decodeTexture(key.file)
+		uploads.push(func() {
+			// released before it finished loading
+			if a.textures[key] != asset {
+				return
+			}
+			var loaded *Texture
+			if err == nil {
+				loaded, err = data.upload(key.textureType, key.gammaCorrect)
+			}
+			if err != nil {
+				glError(fmt.Errorf("%v, using a fallback texture", err))
+				// the placeholder of a packed texture already has the neutral value of each channel
+				if key.textureType != Packed {
+					texture.replace(FallbackTexture(key.textureType))
+				}
+				return
+			}
+			texture.replace(loaded)
+			asset.owned = true
+		})
+	})
+	return texture
+}
+
+// ReleaseTexture drops a reference to a texture returned by Texture, textures that wasn't loaded by the manager are
+// ignored
+func (a *Assets) ReleaseTexture(texture *Texture) {
+	asset := texture.asset
+	if asset == nil || a.textures[asset.key] != asset {
+		return
+	}
+	asset.refs--
+	if asset.refs > 0 {
+		return
+	}
+	glLogf("releasing texture %s\n", asset.key.file)
+	delete(a.textures, asset.key)
+	if asset.owned {
+		texture.Delete()
+	}
+}
+
+// Model returns the meshes of the model in the directory, loading it on first use. Every call returns new Mesh values
+// that share the vertex buffers, so that textures and material values can be changed per instance.
+func (a *Assets) Model(directory string, shaderType ShaderType) []*Mesh {
+	key := modelKey{directory: path.Clean(directory), shaderType: shaderType}
+	asset, ok := a.models[key]
+	if !ok {
+		asset = &modelAsset{key: key}
+		a.models[key] = asset
+	}
+	asset.refs++
+
+	// a model that is still loading in the background is loaded again here rather than by running the upload queue,
+	// which would upload every other asset as well, the background load is then dropped
+	if !asset.done {
+		data, err := loadMeshData(key.directory)
+		asset.loaded(buildModel(key.directory, shaderType, data, err, a.Texture))
+	}
+	return asset.instances()
+}
+
+// ModelAsync parses the model in the directory on a worker goroutine and calls ready with the meshes from the render
+// loop once they have been uploaded. The textures of the model are loaded with TextureAsync.
+func (a *Assets) ModelAsync(directory string, shaderType ShaderType, ready func([]*Mesh)) {
+	key := modelKey{directory: path.Clean(directory), shaderType: shaderType}
+	asset, ok := a.models[key]
+	if !ok {
+		asset = &modelAsset{key: key}
+		a.models[key] = asset
+		loadAsync(func() {
+			data, err := loadMeshData(key.directory)
+			uploads.push(func() {
+				// Model has loaded it in the meantime
+				if asset.done {
+					return
+				}
+				asset.loaded(buildModel(key.directory, shaderType, data, err, a.textureAsync))
+			})
+		})
+	}
+	asset.refs++
+
+	if asset.done {
+		ready(asset.instances())
+		return
+	}
+	asset.waiting = append(asset.waiting, ready)
+}
+
+// textureAsync is TextureAsync with the signature of a textureLoader
+func (a *Assets) textureAsync(texType TextureType, file string, gammaCorrect bool) (*Texture, error) {
+	return a.TextureAsync(texType, file, gammaCorrect), nil
+}
+
+// loaded stores the meshes of the model and hands them to everyone that is waiting for them
+func (m *modelAsset) loaded(meshes []*Mesh) {
+	for _, mesh := range meshes {
+		mesh.model = m
+	}
+	m.meshes = meshes
+	m.done = true
+	for _, ready := range m.waiting {
+		ready(m.instances())
+	}
+	m.waiting = nil
+}
+
+func (m *modelAsset) instances() []*Mesh {
+	meshes := make([]*Mesh, len(m.meshes))
+	for i, mesh := range m.meshes {
+		meshes[i] = mesh.instance()
+	}
+	m.handedOut = append(m.handedOut, meshes...)
+	return meshes
+}
+
+// Reload loads the textures and models that use the file again, in the background, and swaps the new OpenGL objects
+// into the existing Texture and Mesh values so that everything holding them sees the change. Failures are logged and
+// the old data is kept.
+func (a *Assets) Reload(file string) {
+	file = path.Clean(file)
+	for _, asset := range a.textures {
+		if asset.key.file == file {
+			a.reloadTexture(asset)
+		}
+	}
+	switch path.Ext(file) {
+	case ".obj", ".mtl", meshcache.Extension:
+		for _, asset := range a.models {
+			if asset.done && asset.key.directory == path.Dir(file) {
+				a.reloadModel(asset)
+			}
+		}
+	}
+}
+
+func (a *Assets) reloadTexture(asset *textureAsset) {
+	key := asset.key
+	loadAsync(func() {
+		data, err := decodeTexture(key.file)
+		uploads.push(func() {
+			if a.textures[key] != asset {
+				return
+			}
+			var loaded *Texture
+			if err == nil {
+				loaded, err = data.upload(key.textureType, key.gammaCorrect)
+			}
+			if err != nil {
+				glError(fmt.Errorf("reloading texture: %v", err))
+				return
+			}
+			old := asset.texture.ID
+			asset.texture.replace(loaded)
+			if asset.owned {
+				gl.DeleteTextures(1, &old)
+			}
+			asset.owned = true
+			glLogf("reloaded texture %s\n", key.file)
+		})
+	})
+}
+
+func (a *Assets) reloadModel(asset *modelAsset) {
+	key := asset.key
+	loadAsync(func() {
+		data, err := loadMeshData(key.directory)
+		uploads.push(func() {
+			if a.models[key] != asset {
+				return
+			}
+			if err != nil {
+				glError(fmt.Errorf("reloading model %s: %v", key.directory, err))
+				return
+			}
+			a.swapModel(asset, buildModel(key.directory, key.shaderType, data, nil, a.textureAsync))
+			glLogf("reloaded model %s\n", key.directory)
+		})
+	})
+}
+
+// swapModel moves the buffers, material and textures of the reloaded meshes into the meshes of the model and every
+// instance of them. Values that an instance has changed are kept.
+func (a *Assets) swapModel(asset *modelAsset, meshes []*Mesh) {
+	if len(meshes) != len(asset.meshes) {
+		glError(fmt.Errorf("reloading model %s: it has %d objects instead of %d, restart to see all of them", asset.key.directory, len(meshes), len(asset.meshes)))
+	}
+	for i, fresh := range meshes {
+		if i >= len(asset.meshes) {
+			a.releaseMesh(fresh)
+			continue
+		}
+		prototype := asset.meshes[i]
+		for _, mesh := range asset.handedOut {
+			if mesh.buffers != prototype.buffers {
+				continue
+			}
+			// the textures of the model come last, after the ones of a scene file material
+			offset := len(mesh.Textures) - len(prototype.Textures)
+			for k, texture := range prototype.Textures {
+				if j := offset + k; j >= 0 && k < len(fresh.Textures) && mesh.Textures[j] == texture {
+					mesh.Textures[j] = fresh.Textures[k]
+				}
+			}
+			if mesh.Albedo == prototype.Albedo && mesh.Metallic == prototype.Metallic && mesh.Roughness == prototype.Roughness && mesh.Emissive == prototype.Emissive {
+				mesh.Albedo, mesh.Metallic, mesh.Roughness, mesh.Emissive = fresh.Albedo, fresh.Metallic, fresh.Roughness, fresh.Emissive
+			}
+			mesh.Vertices, mesh.NumVertices, mesh.Indices, mesh.Material = fresh.Vertices, fresh.NumVertices, fresh.Indices, fresh.Material
+			mesh.Primitive, mesh.Attributes, mesh.Bounds = fresh.Primitive, fresh.Attributes, fresh.Bounds
+		}
+		for _, texture := range prototype.Textures {
+			a.ReleaseTexture(texture)
+		}
+		prototype.buffers.delete()
+		*prototype.buffers = *fresh.buffers
+		buffers := prototype.buffers
+		*prototype = *fresh
+		prototype.buffers = buffers
+		prototype.model = asset
+	}
+}
+
+// releaseMesh drops the textures and deletes the buffers of a mesh that was never handed out
+func (a *Assets) releaseMesh(mesh *Mesh) {
+	for _, texture := range mesh.Textures {
+		a.ReleaseTexture(texture)
+	}
+	mesh.buffers.delete()
+}
+
+// ReleaseModel drops a reference to the meshes returned by one call to Model, the textures that was loaded with the
+// model are released together with its buffers. Textures that was added to the meshes afterwards are released once,
+// as the reference the caller took for them.
+func (a *Assets) ReleaseModel(meshes []*Mesh) {
+	if len(meshes) == 0 {
+		return
+	}
+	asset := meshes[0].model
+	if asset == nil || a.models[asset.key] != asset {
+		return
+	}
+	a.releaseInstances(asset, meshes)
+	asset.refs--
+	if asset.refs > 0 {
+		return
+	}
+	glLogf("releasing model %s\n", asset.key.directory)
+	delete(a.models, asset.key)
+	for _, mesh := range asset.meshes {
+		a.releaseMesh(mesh)
+	}
+}
+
+// releaseInstances forgets the meshes that was handed out and releases the textures that was added to them
+func (a *Assets) releaseInstances(asset *modelAsset, meshes []*Mesh) {
+	added := make(map[*Texture]bool)
+	for _, mesh := range meshes {
+		for _, texture := range mesh.Textures {
+			added[texture] = true
+		}
+		for i, handedOut := range asset.handedOut {
+			if handedOut == mesh {
+				asset.handedOut = append(asset.handedOut[:i:i], asset.handedOut[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, prototype := range asset.meshes {
+		for _, texture := range prototype.Textures {
+			delete(added, texture)
+		}
+	}
+	for texture := range added {
+		a.ReleaseTexture(texture)
+	}
+}
@@ -37,6 +37,15 @@ type Camera struct {
 	view       mgl32.Mat4
 }
 
+// Place moves the camera to the position and turns it to the yaw and pitch, in degrees
+func (cam *Camera) Place(position mgl32.Vec3, yaw, pitch float32) {
+	cam.position = position
+	cam.yaw = yaw
+	cam.pitch = pitch
+	cam.updateVectors()
+	cam.view = mgl32.LookAtV(cam.position, cam.position.Add(cam.front), cam.up)
+}
+
 func (cam *Camera) View(elapsed float64) mgl32.Mat4 {
 	changed := false
 	if cam.handleKeyboard(elapsed) {
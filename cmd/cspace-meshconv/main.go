@@ -0,0 +1,92 @@
+// cspace-meshconv converts models into the binary mesh cache that LoadModel prefers over parsing the source file.
+//
+// Usage:
+//
+//	cspace-meshconv [-o out.mesh] [-crease degrees] models/winged_victory/model.obj [...]
+//
+// glTF (.gltf and .glb) files are flattened, since the cache has no node hierarchy, see meshcache.FromGLTF for what
+// is kept. Write them to model.mesh in a model directory to load them with LoadModel.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stojg/cspace/lib/gltf"
+	"github.com/stojg/cspace/lib/meshcache"
+	"github.com/stojg/cspace/lib/obj"
+)
+
+func main() {
+	out := flag.String("o", "", "output file, only valid with a single input. Defaults to the input with a "+meshcache.Extension+" extension")
+	crease := flag.Float64("crease", 0, "recompute the normals of OBJ models, with hard edges where faces meet at a sharper angle than this in degrees")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-o out%s] [-crease degrees] model.obj|model.gltf|model.glb [...]\n", os.Args[0], meshcache.Extension)
+		fmt.Fprintln(os.Stderr, "glTF files are flattened into one mesh per primitive with the node transforms baked in, only their base colour,")
+		fmt.Fprintln(os.Stderr, "normal and emissive textures in image files of their own are kept")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || (*out != "" && flag.NArg() > 1) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, in := range flag.Args() {
+		dest := *out
+		if dest == "" {
+			dest = strings.TrimSuffix(in, filepath.Ext(in)) + meshcache.Extension
+		}
+		if err := convert(in, dest, obj.Options{CreaseAngle: *crease}); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", in, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func convert(in, out string, options obj.Options) error {
+	start := time.Now()
+	meshes, err := load(in, options)
+	if err != nil {
+		return err
+	}
+	if err := meshcache.WriteFile(out, meshes); err != nil {
+		return err
+	}
+	var vertices, indices int
+	for _, m := range meshes {
+		vertices += len(m.Vertices)
+		indices += len(m.Indices)
+	}
+	fmt.Printf("%s -> %s: %d meshes, %d vertices, %d indices in %s\n", in, out, len(meshes), vertices, indices, time.Since(start))
+	return nil
+}
+
+// load picks the importer by the file extension
+func load(in string, options obj.Options) ([]*meshcache.Mesh, error) {
+	switch strings.ToLower(filepath.Ext(in)) {
+	case ".obj":
+		objects, err := obj.LoadOptions(in, options)
+		if err != nil {
+			return nil, err
+		}
+		return meshcache.FromObjects(objects)
+	case ".gltf", ".glb":
+		doc, err := gltf.Open(in)
+		if err != nil {
+			return nil, err
+		}
+		return meshcache.FromGLTF(doc)
+	default:
+		return nil, fmt.Errorf("unsupported model format %q", filepath.Ext(in))
+	}
+}
@@ -0,0 +1,92 @@
+// cspace-texprep prepares the textures of a material for the engine. The images are resized to a power of two, the
+// single valued maps are packed into the channels of one texture, and every output is written as a KTX file with a
+// full mip chain in OpenGL row order, so that it's uploaded as is.
+//
+// Usage:
+//
+//	cspace-texprep -o textures/rock_floor -albedo Base_Color.png -normal Normal.png -roughness Roughness.png [...]
+//
+// The outputs are named after their input, albedo.ktx, normal.ktx, emissive.ktx, and orm.ktx or mr.ktx for the packed
+// maps. Load the packed texture with LoadPackedTexture and the ORM or MR channels.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stojg/cspace/lib/gpuimage"
+)
+
+func main() {
+	out := flag.String("o", ".", "output directory")
+	maxSize := flag.Int("max", 2048, "largest width or height of the outputs")
+	pack := flag.String("pack", "orm", "pack the ao, roughness and metallic maps into one texture: orm, mr (ao is written on its own) or none")
+	flipGreen := flag.Bool("flip-green", false, "flip the green channel of the normal map, to convert between the DirectX (Y down) and OpenGL (Y up) convention")
+	inputs := make(map[string]*string)
+	for _, name := range []string{albedo, normal, emissive, ao, roughness, metallic} {
+		inputs[name] = flag.String(name, "", "the "+name+" map")
+	}
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-o dir] [-pack orm|mr|none] [-flip-green] -albedo file -normal file [...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	files := make(map[string]string)
+	for name, file := range inputs {
+		if *file != "" {
+			files[name] = *file
+		}
+	}
+	if flag.NArg() > 0 || len(files) == 0 || (*pack != "orm" && *pack != "mr" && *pack != "none") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	outputs, err := plan(files, *pack)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, o := range outputs {
+		o.flipGreen = *flipGreen
+		if err := o.write(filepath.Join(*out, o.name+".ktx"), *maxSize); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", o.name, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// write reads the inputs of the output, processes them and writes the result
+func (o *output) write(dest string, maxSize int) error {
+	start := time.Now()
+	img, err := o.build(maxSize)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if err := gpuimage.EncodeKTX(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %dx%d %s, %d levels in %s\n", dest, img.Width, img.Height, img.Format, len(img.Levels), time.Since(start))
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/stojg/cspace/lib/gpuimage"
+)
+
+// the input maps of a material
+const (
+	albedo    = "albedo"
+	normal    = "normal"
+	emissive  = "emissive"
+	ao        = "ao"
+	roughness = "roughness"
+	metallic  = "metallic"
+)
+
+// neutral are the values of the packed channels that don't have an input
+var neutral = map[string]float32{
+	ao:        1,
+	roughness: 1,
+	metallic:  0,
+	"":        0,
+}
+
+type outputKind int
+
+const (
+	colorMap  outputKind = iota // sRGB encoded colours
+	normalMap                   // unit vectors
+	packedMap                   // linear values, one map per channel
+	greyMap                     // a single linear value
+)
+
+// output is a texture file that is built from one or more input maps
+type output struct {
+	name      string
+	kind      outputKind
+	file      string
+	channels  [4]string // the maps in the red, green, blue and alpha channel of a packed output
+	files     map[string]string
+	flipGreen bool
+}
+
+// plan decides which outputs to write for the input maps
+func plan(files map[string]string, pack string) ([]*output, error) {
+	var outputs []*output
+	for _, name := range []string{albedo, emissive} {
+		if file, ok := files[name]; ok {
+			outputs = append(outputs, &output{name: name, kind: colorMap, file: file})
+		}
+	}
+	if file, ok := files[normal]; ok {
+		outputs = append(outputs, &output{name: normal, kind: normalMap, file: file})
+	}
+
+	var packed *output
+	switch pack {
+	case "orm":
+		packed = &output{name: "orm", kind: packedMap, channels: [4]string{ao, roughness, metallic}}
+	case "mr":
+		packed = &output{name: "mr", kind: packedMap, channels: [4]string{"", roughness, metallic}}
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown packing %q", pack)
+	}
+	for _, name := range []string{ao, roughness, metallic} {
+		file, ok := files[name]
+		if !ok {
+			continue
+		}
+		if packed != nil && packed.hasChannel(name) {
+			if packed.files == nil {
+				packed.files = make(map[string]string)
+				outputs = append(outputs, packed)
+			}
+			packed.files[name] = file
+			continue
+		}
+		outputs = append(outputs, &output{name: name, kind: greyMap, file: file})
+	}
+	return outputs, nil
+}
+
+func (o *output) hasChannel(name string) bool {
+	for _, c := range o.channels {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// build loads and processes the inputs into a mip mapped image in OpenGL row order
+func (o *output) build(maxSize int) (*gpuimage.Image, error) {
+	pic, err := o.load()
+	if err != nil {
+		return nil, err
+	}
+	pic = pic.resize(powerOfTwo(pic.width, maxSize), powerOfTwo(pic.height, maxSize))
+	if o.kind == normalMap {
+		if o.flipGreen {
+			pic.flipGreen()
+		}
+		pic.normalize()
+	}
+
+	img := &gpuimage.Image{
+		Format:   gpuimage.RGBA8,
+		SRGB:     o.kind == colorMap,
+		Width:    pic.width,
+		Height:   pic.height,
+		BottomUp: true,
+	}
+	if o.kind == greyMap {
+		img.Format = gpuimage.R8
+	}
+	for {
+		img.Levels = append(img.Levels, o.encodeLevel(pic))
+		if pic.width == 1 && pic.height == 1 {
+			return img, nil
+		}
+		// the mips are averaged in linear space and encoded afterwards
+		pic = pic.half()
+		if o.kind == normalMap {
+			pic.normalize()
+		}
+	}
+}
+
+func (o *output) load() (*picture, error) {
+	if o.kind == packedMap {
+		return o.pack()
+	}
+	return load(o.file, o.kind == colorMap)
+}
+
+// pack loads the red channel of every input map into its channel of the output, the inputs are resized to the size
+// of the first one
+func (o *output) pack() (*picture, error) {
+	var result *picture
+	for c, name := range o.channels {
+		file, ok := o.files[name]
+		if !ok {
+			continue
+		}
+		src, err := load(file, false)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = newPicture(src.width, src.height)
+			for i := range result.pix {
+				result.pix[i] = neutral[o.channels[i%4]]
+			}
+			// unused alpha channels are opaque
+			if o.channels[3] == "" {
+				for i := 3; i < len(result.pix); i += 4 {
+					result.pix[i] = 1
+				}
+			}
+		}
+		src = src.resize(result.width, result.height)
+		for i := 0; i < len(result.pix); i += 4 {
+			result.pix[i+c] = src.pix[i]
+		}
+	}
+	if result == nil {
+		return nil, errors.New("no input maps")
+	}
+	return result, nil
+}
+
+// encodeLevel converts the picture to bytes, bottom row first
+func (o *output) encodeLevel(pic *picture) []byte {
+	channels := 4
+	if o.kind == greyMap {
+		channels = 1
+	}
+	data := make([]byte, 0, pic.width*pic.height*channels)
+	for y := pic.height - 1; y >= 0; y-- {
+		for x := 0; x < pic.width; x++ {
+			px := pic.at(x, y)
+			for c := 0; c < channels; c++ {
+				v := px[c]
+				if o.kind == colorMap && c < 3 {
+					v = linearToSRGB(v)
+				}
+				data = append(data, toUint8(v))
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+)
+
+// picture is an image with four linear float channels, the first row is the top of the image
+type picture struct {
+	width, height int
+	pix           []float32
+}
+
+func newPicture(width, height int) *picture {
+	return &picture{width: width, height: height, pix: make([]float32, width*height*4)}
+}
+
+func (p *picture) at(x, y int) []float32 {
+	i := (y*p.width + x) * 4
+	return p.pix[i : i+4]
+}
+
+// load decodes an image file, the colour channels of sRGB images are converted to linear values
+func load(file string, srgb bool) (*picture, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	bounds := img.Bounds()
+	p := newPicture(bounds.Dx(), bounds.Dy())
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			c := color.NRGBA64Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA64)
+			px := p.at(x, y)
+			px[0], px[1], px[2], px[3] = float32(c.R)/0xffff, float32(c.G)/0xffff, float32(c.B)/0xffff, float32(c.A)/0xffff
+			if srgb {
+				for i := 0; i < 3; i++ {
+					px[i] = srgbToLinear(px[i])
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// powerOfTwo returns the power of two closest to size that isn't larger than max
+func powerOfTwo(size, max int) int {
+	p := 1
+	for p*2 <= size {
+		p *= 2
+	}
+	if size-p > p*2-size {
+		p *= 2
+	}
+	for p > max && p > 1 {
+		p /= 2
+	}
+	return p
+}
+
+// resize scales the picture with a tent filter, which is widened when shrinking so that every source pixel
+// contributes. Textures tile, so the filter wraps around the edges.
+func (p *picture) resize(width, height int) *picture {
+	if width == p.width && height == p.height {
+		return p
+	}
+	// scale the rows, then the columns
+	xTaps, yTaps := filterTaps(p.width, width), filterTaps(p.height, height)
+	rows := newPicture(width, p.height)
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < width; x++ {
+			dst := rows.at(x, y)
+			for _, t := range xTaps[x] {
+				src := p.at(t.index, y)
+				for c := range dst {
+					dst[c] += src[c] * t.weight
+				}
+			}
+		}
+	}
+	result := newPicture(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst := result.at(x, y)
+			for _, t := range yTaps[y] {
+				src := rows.at(x, t.index)
+				for c := range dst {
+					dst[c] += src[c] * t.weight
+				}
+			}
+		}
+	}
+	return result
+}
+
+type tap struct {
+	index  int
+	weight float32
+}
+
+// filterTaps returns the source pixels and their weights for each pixel when scaling from one size to another
+func filterTaps(from, to int) [][]tap {
+	scale := float64(from) / float64(to)
+	radius := math.Max(1, scale)
+	taps := make([][]tap, to)
+	for i := range taps {
+		center := (float64(i)+0.5)*scale - 0.5
+		var sum float32
+		for j := int(math.Ceil(center - radius)); j <= int(math.Floor(center+radius)); j++ {
+			weight := float32(1 - math.Abs(float64(j)-center)/radius)
+			if weight <= 0 {
+				continue
+			}
+			taps[i] = append(taps[i], tap{index: ((j % from) + from) % from, weight: weight})
+			sum += weight
+		}
+		for k := range taps[i] {
+			taps[i][k].weight /= sum
+		}
+	}
+	return taps
+}
+
+// half returns the next mip level by averaging blocks of 2x2 pixels, the picture must have power of two dimensions
+func (p *picture) half() *picture {
+	width, height := maxInt(1, p.width/2), maxInt(1, p.height/2)
+	stepX, stepY := p.width/width, p.height/height
+	result := newPicture(width, height)
+	weight := 1 / float32(stepX*stepY)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst := result.at(x, y)
+			for sy := 0; sy < stepY; sy++ {
+				for sx := 0; sx < stepX; sx++ {
+					src := p.at(x*stepX+sx, y*stepY+sy)
+					for c := range dst {
+						dst[c] += src[c] * weight
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+// normalize makes the vectors stored in a normal map unit length again, they get shorter when they are averaged
+func (p *picture) normalize() {
+	for i := 0; i < len(p.pix); i += 4 {
+		x, y, z := p.pix[i]*2-1, p.pix[i+1]*2-1, p.pix[i+2]*2-1
+		length := float32(math.Sqrt(float64(x*x + y*y + z*z)))
+		if length == 0 {
+			x, y, z, length = 0, 0, 1, 1
+		}
+		p.pix[i], p.pix[i+1], p.pix[i+2] = x/length*0.5+0.5, y/length*0.5+0.5, z/length*0.5+0.5
+	}
+}
+
+// flipGreen converts a normal map between the DirectX and OpenGL convention for the direction of the Y axis
+func (p *picture) flipGreen() {
+	for i := 1; i < len(p.pix); i += 4 {
+		p.pix[i] = 1 - p.pix[i]
+	}
+}
+
+func srgbToLinear(v float32) float32 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return float32(math.Pow((float64(v)+0.055)/1.055, 2.4))
+}
+
+func linearToSRGB(v float32) float32 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return float32(1.055*math.Pow(float64(v), 1/2.4) - 0.055)
+}
+
+// toUint8 rounds a value in the 0 to 1 range to a byte
+func toUint8(v float32) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(float64(v)*255))))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
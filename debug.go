@@ -8,8 +8,43 @@ import (
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/stojg/cspace/lib/rgbe"
 )
 
+// initDebugShaders builds the shaders that the Display* functions draw the debug views with, so that a broken
+// debug shader stops the startup instead of showing up the first time the debug views are turned on
+func initDebugShaders() error {
+	var err error
+	if albedoDebugShader, err = NewDefaultShader("fbo_debug", "fbo_debug"); err != nil {
+		return err
+	}
+	if depthShader, err = NewDefaultShader("depth_debug", "depth_debug"); err != nil {
+		return err
+	}
+	depthShaderTextureLoc = uniformLocation(depthShader, "screenTexture")
+	if ssaoDebug, err = NewDefaultShader("depth_debug", "depth_debug"); err != nil {
+		return err
+	}
+	ssaoDebugTextureLoc = uniformLocation(ssaoDebug, "screenTexture")
+	if roughnessDebug, err = NewDefaultShader("depth_debug", "fbo_debug_alpha"); err != nil {
+		return err
+	}
+	roughnessDebugTextureLoc = uniformLocation(roughnessDebug, "screenTexture")
+	if metallicDebug, err = NewDefaultShader("depth_debug", "fbo_debug_alpha"); err != nil {
+		return err
+	}
+	metallicDebugTextureLoc = uniformLocation(metallicDebug, "screenTexture")
+	if shadowDebug, err = NewDefaultShader("depth_debug", "depth_debug"); err != nil {
+		return err
+	}
+	shadowDebugTextureLoc = uniformLocation(shadowDebug, "screenTexture")
+	if BloomDebug, err = NewDefaultShader("fbo_debug", "fbo_debug"); err != nil {
+		return err
+	}
+	BloomDebugTextureLoc = uniformLocation(BloomDebug, "screenTexture")
+	return nil
+}
+
 var albedoDebugShader *DefaultShader
 var vaoAlbedodoDebug uint32
 
@@ -32,7 +67,6 @@ func DisplayAlbedoTexBuffer(textureID uint32) {
 		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 5*4, gl.PtrOffset(3*4))
-		albedoDebugShader = NewDefaultShader("fbo_debug", "fbo_debug")
 	}
 
 	albedoDebugShader.Use()
@@ -67,7 +101,6 @@ func DisplayNormalBufferTexture(textureID uint32) {
 		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 5*4, gl.PtrOffset(3*4))
-		albedoDebugShader = NewDefaultShader("fbo_debug", "fbo_debug")
 	}
 
 	gl.ActiveTexture(gl.TEXTURE0)
@@ -104,8 +137,6 @@ func DisplayDepthbufferTexture(textureID uint32) {
 		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 5*4, gl.PtrOffset(3*4))
-		depthShader = NewDefaultShader("depth_debug", "depth_debug")
-		depthShaderTextureLoc = uniformLocation(depthShader, "screenTexture")
 	}
 
 	depthShader.Use()
@@ -142,8 +173,6 @@ func DisplaySsaoTexture(textureID uint32) {
 		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 5*4, gl.PtrOffset(3*4))
-		ssaoDebug = NewDefaultShader("depth_debug", "depth_debug")
-		ssaoDebugTextureLoc = uniformLocation(ssaoDebug, "screenTexture")
 	}
 
 	ssaoDebug.Use()
@@ -179,8 +208,6 @@ func DisplayRoughnessTexture(textureID uint32) {
 		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 5*4, gl.PtrOffset(3*4))
-		roughnessDebug = NewDefaultShader("depth_debug", "fbo_debug_alpha")
-		roughnessDebugTextureLoc = uniformLocation(roughnessDebug, "screenTexture")
 	}
 
 	roughnessDebug.Use()
@@ -216,8 +243,6 @@ func DisplayMetallicTexture(textureID uint32) {
 		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 5*4, gl.PtrOffset(3*4))
-		metallicDebug = NewDefaultShader("depth_debug", "fbo_debug_alpha")
-		metallicDebugTextureLoc = uniformLocation(metallicDebug, "screenTexture")
 	}
 
 	metallicDebug.Use()
@@ -253,8 +278,6 @@ func DisplayShadowTexture(textureID uint32) {
 		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 5*4, gl.PtrOffset(3*4))
-		shadowDebug = NewDefaultShader("depth_debug", "depth_debug")
-		shadowDebugTextureLoc = uniformLocation(shadowDebug, "screenTexture")
 	}
 
 	shadowDebug.Use()
@@ -290,8 +313,6 @@ func DisplayBloomTexture(textureID uint32) {
 		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
 		gl.EnableVertexAttribArray(1)
 		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 5*4, gl.PtrOffset(3*4))
-		BloomDebug = NewDefaultShader("fbo_debug", "fbo_debug")
-		BloomDebugTextureLoc = uniformLocation(BloomDebug, "screenTexture")
 	}
 
 	BloomDebug.Use()
@@ -400,7 +421,8 @@ func fpsCounter(window *glfw.Window) {
 		fpsPrevSeconds = currentSeconds
 		fps := (float64(fpsFrameCount) / elapsedSeconds)
 		ms := 1000 / fps
-		msg := fmt.Sprintf("cspace @ %.2fms / %.0f", ms, fps)
+		msg := fmt.Sprintf("cspace @ %.2fms / %.0f, meshes %d drawn %d culled, shadow %d drawn %d culled", ms, fps,
+			gBufferCulling.Drawn, gBufferCulling.Culled, shadowCulling.Drawn, shadowCulling.Culled)
 		window.SetTitle(msg)
 		fmt.Println(msg)
 		fpsFrameCount = 0
@@ -545,6 +567,25 @@ func glLogGLParams() {
 	}
 }
 
+// SaveHDRTexture writes the content of a RGB float texture into a Radiance .hdr file
+func SaveHDRTexture(textureID uint32, width, height int32, file string) error {
+	data := make([]float32, width*height*3)
+	gl.BindTexture(gl.TEXTURE_2D, textureID)
+	gl.GetTexImage(gl.TEXTURE_2D, 0, gl.RGB, gl.FLOAT, gl.Ptr(data))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	// OpenGL stores the bottom row first
+	if err := rgbe.Encode(f, int(width), int(height), flipImgData(int(width), int(height), data)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 //func CaptureRGBA(im *image.RGBA) {
 //	b := im.Bounds()
 //	gl.ReadBuffer(gl.BACK_LEFT)
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stojg/cspace/lib/geom"
+)
+
+// Frustum is the volume that a projection and view matrix can see, as six planes with normals pointing inwards. A
+// plane is the normal in xyz and the distance from the origin in w.
+type Frustum [6]mgl32.Vec4
+
+// NewFrustum extracts the planes of the frustum from the combined projection and view matrix, it works for both
+// perspective and orthographic projections
+func NewFrustum(viewProjection mgl32.Mat4) *Frustum {
+	var f Frustum
+	m := viewProjection
+	for i := 0; i < 3; i++ {
+		// the left, bottom and near planes add the row to the w row, the right, top and far planes subtract it
+		f[i*2] = m.Row(3).Add(m.Row(i))
+		f[i*2+1] = m.Row(3).Sub(m.Row(i))
+	}
+	for i := range f {
+		f[i] = f[i].Mul(1 / f[i].Vec3().Len())
+	}
+	return &f
+}
+
+// CullStats counts the meshes that were drawn and the ones that were skipped because they were outside the frustum
+type CullStats struct {
+	Drawn  int
+	Culled int
+}
+
+// Visible returns false when the bounds, transformed into the world, are completely outside the frustum. The bounding
+// sphere is tested first, and the box when the sphere touches a plane. A nil frustum sees everything.
+func (f *Frustum) Visible(bounds geom.BoundingVolume, world mgl32.Mat4) bool {
+	if f == nil {
+		return true
+	}
+	center := world.Mul4x1(mgl32.Vec3(bounds.Center).Vec4(1)).Vec3()
+	maxScale := math.Max(float64(world.Col(0).Vec3().Len()), math.Max(float64(world.Col(1).Vec3().Len()), float64(world.Col(2).Vec3().Len())))
+	radius := bounds.Radius * float32(maxScale)
+
+	// the half size of the box that contains the transformed box
+	local := bounds.Extents()
+	var extents mgl32.Vec3
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			extents[row] += float32(math.Abs(float64(world.At(row, col)))) * local[col]
+		}
+	}
+
+	for _, plane := range f {
+		normal := plane.Vec3()
+		distance := normal.Dot(center) + plane[3]
+		if distance >= radius {
+			continue
+		}
+		if distance < -radius {
+			return false
+		}
+		r := float32(math.Abs(float64(normal[0])))*extents[0] + float32(math.Abs(float64(normal[1])))*extents[1] + float32(math.Abs(float64(normal[2])))*extents[2]
+		if distance < -r {
+			return false
+		}
+	}
+	return true
+}
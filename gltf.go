@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stojg/cspace/lib/gltf"
+	"github.com/stojg/cspace/lib/obj"
+)
+
+// LoadGLTFAsync adds a node named after the .gltf or .glb file to the graph and returns it straight away. The file is
+// parsed and its images decoded on a worker goroutine, the nodes in its default scene are added below the returned
+// node from the render loop. The glTF nodes keep their hierarchy and names, so they can be looked up with Find once
+// they are loaded. The textures show a placeholder until they have been uploaded, and files that fail to load are
+// logged and leave the node empty.
+func LoadGLTFAsync(file string, graph NodeParent) *Node {
+	l := &gltfLoader{
+		file:     file,
+		meshes:   make(map[int][]*Mesh),
+		textures: make(map[gltfTextureKey]*Texture),
+	}
+	group := &Node{name: file, transform: mgl32.Ident4(), gltf: file, free: l.free}
+	graph.AddChild(group)
+
+	loadAsync(func() {
+		scene, err := decodeGLTF(file)
+		uploads.push(func() {
+			// released before it finished loading
+			if group.released {
+				return
+			}
+			if err != nil {
+				glError(fmt.Errorf("LoadGLTF: %v", err))
+				return
+			}
+			l.scene = scene
+			for _, root := range scene.roots {
+				l.addNode(group, root)
+			}
+		})
+	})
+	return group
+}
+
+type gltfTextureKey struct {
+	texture     int
+	textureType TextureType
+	channels    Channels
+}
+
+// gltfScene is a glTF file that has been parsed and had its images decoded, without using OpenGL
+type gltfScene struct {
+	roots  []*gltfNode
+	meshes map[int][]*gltfPrimitive
+	// the images of the textures by texture index, flipped into OpenGL order
+	images map[int]*image.RGBA
+}
+
+type gltfNode struct {
+	name      string
+	transform mgl32.Mat4
+	mesh      int // -1 for nodes without a mesh
+	children  []*gltfNode
+}
+
+type gltfPrimitive struct {
+	name          string
+	vertices      []Vertex
+	indices       []uint32
+	material      *obj.Material
+	textures      []gltfTexture
+	texCoordSets  map[TextureType]int
+	hasTexCoords2 bool
+}
+
+type gltfTexture struct {
+	key          gltfTextureKey
+	gammaCorrect bool
+}
+
+// decodeGLTF parses the file and decodes everything that its default scene uses, it's safe to call from any goroutine
+func decodeGLTF(file string) (*gltfScene, error) {
+	doc, err := gltf.OpenFS(assetFS, file)
+	if err != nil {
+		return nil, err
+	}
+	d := &gltfDecoder{
+		file: file,
+		doc:  doc,
+		scene: &gltfScene{
+			meshes: make(map[int][]*gltfPrimitive),
+			images: make(map[int]*image.RGBA),
+		},
+	}
+	for _, root := range doc.RootNodes() {
+		node, err := d.node(root, make(map[int]bool))
+		if err != nil {
+			return nil, err
+		}
+		d.scene.roots = append(d.scene.roots, node)
+	}
+	return d.scene, nil
+}
+
+type gltfDecoder struct {
+	file  string
+	doc   *gltf.Document
+	scene *gltfScene
+}
+
+func (d *gltfDecoder) node(index int, visited map[int]bool) (*gltfNode, error) {
+	if index < 0 || index >= len(d.doc.Nodes) {
+		return nil, fmt.Errorf("%s: node %d does not exist", d.file, index)
+	}
+	if visited[index] {
+		return nil, fmt.Errorf("%s: node %d is part of a cycle", d.file, index)
+	}
+	visited[index] = true
+	defer delete(visited, index)
+
+	n := d.doc.Nodes[index]
+	node := &gltfNode{name: n.Name, transform: mgl32.Mat4(n.LocalTransform()), mesh: -1}
+	if n.Mesh != nil {
+		if err := d.mesh(*n.Mesh); err != nil {
+			return nil, err
+		}
+		node.mesh = *n.Mesh
+	}
+	for _, child := range n.Children {
+		c, err := d.node(child, visited)
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, c)
+	}
+	return node, nil
+}
+
+// mesh decodes the primitives of the glTF mesh, meshes are shared between the nodes that reference them
+func (d *gltfDecoder) mesh(index int) error {
+	if _, found := d.scene.meshes[index]; found {
+		return nil
+	}
+	if index < 0 || index >= len(d.doc.Meshes) {
+		return fmt.Errorf("%s: mesh %d does not exist", d.file, index)
+	}
+	var primitives []*gltfPrimitive
+	for i, p := range d.doc.Meshes[index].Primitives {
+		name := fmt.Sprintf("%s_%d", d.doc.Meshes[index].Name, i)
+		primitive, err := d.primitive(name, p)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", d.file, name, err)
+		}
+		primitives = append(primitives, primitive)
+	}
+	d.scene.meshes[index] = primitives
+	return nil
+}
+
+func (d *gltfDecoder) primitive(name string, p gltf.Primitive) (*gltfPrimitive, error) {
+	vertices, vertexIndices, hasTexCoords2, err := d.doc.PrimitiveVertices(p)
+	if err != nil {
+		return nil, err
+	}
+
+	mat := obj.NewMaterial()
+	mat.Name = name
+	primitive := &gltfPrimitive{name: name, vertices: vertices, indices: vertexIndices, material: mat, hasTexCoords2: hasTexCoords2}
+	if p.Material != nil {
+		if *p.Material < 0 || *p.Material >= len(d.doc.Materials) {
+			return nil, fmt.Errorf("material %d does not exist", *p.Material)
+		}
+		m := d.doc.Materials[*p.Material]
+		if m.Name != "" {
+			mat.Name = m.Name
+		}
+		baseColor := m.BaseColor()
+		mat.Diffuse = [3]float32{baseColor[0], baseColor[1], baseColor[2]}
+		mat.Transparency = baseColor[3]
+		mat.Metallic = m.Metallic()
+		mat.Roughness = m.Roughness()
+		mat.Emissive = m.EmissiveFactor
+		if err := d.materialTextures(primitive, m); err != nil {
+			return nil, err
+		}
+	}
+	return primitive, nil
+}
+
+// materialTextures maps the glTF metallic-roughness material onto the TextureType slots, and records the UV set of
+// each slot that is mapped with the second set. Textures that use a set the mesh doesn't have are mapped with the first.
+func (d *gltfDecoder) materialTextures(primitive *gltfPrimitive, m gltf.Material) error {
+	primitive.texCoordSets = make(map[TextureType]int)
+	add := func(info *gltf.TextureInfo, textureType TextureType, gammaCorrect bool, channels Channels) error {
+		if info == nil {
+			return nil
+		}
+		if err := d.image(info.Index); err != nil {
+			return err
+		}
+		key := gltfTextureKey{texture: info.Index, textureType: textureType, channels: channels}
+		primitive.textures = append(primitive.textures, gltfTexture{key: key, gammaCorrect: gammaCorrect})
+		if info.TexCoord == 1 && primitive.hasTexCoords2 {
+			slots := []TextureType{textureType}
+			if textureType == Packed {
+				slots = channels[:]
+			}
+			for _, slot := range slots {
+				if slot != "" {
+					primitive.texCoordSets[slot] = 1
+				}
+			}
+		}
+		return nil
+	}
+	occlusion := m.OcclusionTexture
+	if pbr := m.PBRMetallicRoughness; pbr != nil {
+		if err := add(pbr.BaseColorTexture, Albedo, true, Channels{}); err != nil {
+			return err
+		}
+		if mr := pbr.MetallicRoughnessTexture; mr != nil {
+			channels := MR
+			// occlusion is stored in the red channel, which is free in the metallicRoughness texture
+			if occlusion != nil && occlusion.Index == mr.Index && occlusion.TexCoord == mr.TexCoord {
+				channels, occlusion = ORM, nil
+			}
+			if err := add(mr, Packed, false, channels); err != nil {
+				return err
+			}
+		}
+	}
+	if err := add(m.NormalTexture, Normal, false, Channels{}); err != nil {
+		return err
+	}
+	if err := add(occlusion, Packed, false, Channels{AO}); err != nil {
+		return err
+	}
+	return add(m.EmissiveTexture, Emissive, true, Channels{})
+}
+
+// image decodes the image of a glTF texture into OpenGL row order
+func (d *gltfDecoder) image(index int) error {
+	if _, found := d.scene.images[index]; found {
+		return nil
+	}
+	data, err := d.doc.TextureImage(index)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("texture %d: %v", index, err)
+	}
+	rgba, err := toRGBA(img)
+	if err != nil {
+		return fmt.Errorf("texture %d: %v", index, err)
+	}
+	d.scene.images[index] = flip(rgba)
+	return nil
+}
+
+// gltfLoader uploads a decoded glTF file, it must be used on the main thread
+type gltfLoader struct {
+	file     string
+	scene    *gltfScene
+	meshes   map[int][]*Mesh
+	textures map[gltfTextureKey]*Texture
+	// the textures that have been swapped in for their placeholder, and own their OpenGL texture
+	uploaded []*Texture
+	freed    bool
+}
+
+func (l *gltfLoader) addNode(parent *Node, n *gltfNode) {
+	var meshes []*Mesh
+	if n.mesh >= 0 {
+		meshes = l.mesh(n.mesh)
+	}
+	group := parent.Add(meshes, n.transform)
+	group.SetName(n.name)
+	group.imported = true
+	for _, child := range n.children {
+		l.addNode(group, child)
+	}
+}
+
+// free deletes the buffers and textures of the file, the meshes aren't cached by the assets manager and are shared by
+// the nodes of the file only. Textures that are still waiting to be uploaded are dropped.
+func (l *gltfLoader) free() {
+	for _, meshes := range l.meshes {
+		for _, mesh := range meshes {
+			mesh.buffers.delete()
+		}
+	}
+	for _, texture := range l.uploaded {
+		texture.Delete()
+	}
+	l.freed = true
+}
+
+// mesh returns one Mesh per primitive in the glTF mesh, meshes are shared between the nodes that reference them
+func (l *gltfLoader) mesh(index int) []*Mesh {
+	if meshes, found := l.meshes[index]; found {
+		return meshes
+	}
+	var meshes []*Mesh
+	for _, p := range l.scene.meshes[index] {
+		meshes = append(meshes, l.primitive(p))
+	}
+	l.meshes[index] = meshes
+	return meshes
+}
+
+func (l *gltfLoader) primitive(p *gltfPrimitive) *Mesh {
+	var textures []*Texture
+	for _, t := range p.textures {
+		textures = append(textures, l.texture(t))
+	}
+
+	shaderType := MaterialMesh
+	if len(textures) > 0 {
+		shaderType = TexturedMesh
+		textures = completeTextures(textures)
+	}
+	mat := p.material
+	mesh := NewMesh(p.name, p.vertices, p.indices, textures, mat, shaderType)
+	mesh.Albedo = mat.Diffuse
+	mesh.Metallic = mat.Metallic
+	mesh.Roughness = mat.Roughness
+	mesh.Emissive = mat.Emissive
+	if shaderType == TexturedMesh {
+		// glTF multiplies the textures with the factors
+		mesh.AlbedoFactor = mat.Diffuse
+		mesh.MetallicFactor = mat.Metallic
+		mesh.RoughnessFactor = mat.Roughness
+		mesh.TexCoordSets = p.texCoordSets
+	}
+	return mesh
+}
+
+// texture returns a placeholder for a glTF texture and queues the upload of its image, which is swapped in when the
+// upload queue is drained. The channels are used by Packed textures.
+func (l *gltfLoader) texture(t gltfTexture) *Texture {
+	if texture, found := l.textures[t.key]; found {
+		return texture
+	}
+	texture := placeholderTexture(t.key.textureType, t.key.channels)
+	l.textures[t.key] = texture
+	rgba := l.scene.images[t.key.texture]
+	uploads.push(func() {
+		// the nodes of the file were released before the texture was uploaded
+		if l.freed {
+			return
+		}
+		texture.replace(newRGBATexture(t.key.textureType, rgba, t.gammaCorrect))
+		l.uploaded = append(l.uploaded, texture)
+	})
+	return texture
+}
+
+// completeTextures fills the albedo, metallic and roughness slots that the material is missing with white, so that the
+// shader only sees the factors of the mesh
+func completeTextures(textures []*Texture) []*Texture {
+	has := make(map[TextureType]bool)
+	for _, t := range textures {
+		for _, texType := range []TextureType{Albedo, Metallic, Roughness} {
+			if _, ok := t.channel(texType); ok {
+				has[texType] = true
+			}
+		}
+	}
+	for _, texType := range []TextureType{Albedo, Metallic, Roughness} {
+		if !has[texType] {
+			textures = append(textures, flatTexture(texType, [3]float32{1, 1, 1}))
+		}
+	}
+	return textures
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testGLTF = `{
+  "asset": {"version": "2.0"},
+  "scene": 0,
+  "scenes": [{"nodes": [0]}],
+  "nodes": [
+    {"name": "root", "children": [1, 2]},
+    {"name": "left", "mesh": 0, "translation": [-1, 0, 0]},
+    {"name": "right", "mesh": 0, "translation": [1, 0, 0]}
+  ],
+  "meshes": [{"name": "triangle", "primitives": [{"attributes": {"POSITION": 0}, "indices": 1, "material": 0}]}],
+  "materials": [{
+    "pbrMetallicRoughness": {"baseColorTexture": {"index": 0}, "metallicRoughnessTexture": {"index": 0}},
+    "occlusionTexture": {"index": 0}
+  }],
+  "textures": [{"source": 0}],
+  "images": [{"uri": "texture.png"}],
+  "accessors": [
+    {"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"},
+    {"bufferView": 1, "componentType": 5123, "count": 3, "type": "SCALAR"}
+  ],
+  "bufferViews": [{"buffer": 0, "byteOffset": 0, "byteLength": 36}, {"buffer": 0, "byteOffset": 36, "byteLength": 6}],
+  "buffers": [{"byteLength": 44, "uri": "triangle.bin"}]
+}`
+
+// testGLTFFS returns the assets of testGLTF, the texture is red at the top and blue at the bottom
+func testGLTFFS(t *testing.T, document string) fstest.MapFS {
+	t.Helper()
+	bin, err := os.ReadFile("lib/gltf/testdata/triangle.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 1, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(0, 1, color.RGBA{B: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return fstest.MapFS{
+		"models/scene.gltf":   {Data: []byte(document)},
+		"models/triangle.bin": {Data: bin},
+		"models/texture.png":  {Data: buf.Bytes()},
+	}
+}
+
+// TestDecodeGLTF checks what is prepared on the worker goroutine, without uploading anything
+func TestDecodeGLTF(t *testing.T) {
+	saved := assetFS
+	defer func() { assetFS = saved }()
+	assetFS = testGLTFFS(t, testGLTF)
+
+	scene, err := decodeGLTF("models/scene.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scene.roots) != 1 || scene.roots[0].name != "root" || len(scene.roots[0].children) != 2 {
+		t.Fatalf("expected a root node with two children, got %+v", scene.roots)
+	}
+	for _, child := range scene.roots[0].children {
+		if child.mesh != 0 {
+			t.Errorf("%s: mesh %d, expected 0", child.name, child.mesh)
+		}
+	}
+	if x := scene.roots[0].children[1].transform.Col(3).X(); x != 1 {
+		t.Errorf("right: translation x %v, expected 1", x)
+	}
+
+	// the mesh is decoded once for both nodes
+	if len(scene.meshes) != 1 || len(scene.meshes[0]) != 1 {
+		t.Fatalf("expected one mesh with one primitive, got %v", scene.meshes)
+	}
+	p := scene.meshes[0][0]
+	if len(p.vertices) != 3 || len(p.indices) != 3 {
+		t.Errorf("got %d vertices and %d indices, expected 3 of each", len(p.vertices), len(p.indices))
+	}
+	expected := []gltfTexture{
+		{key: gltfTextureKey{texture: 0, textureType: Albedo}, gammaCorrect: true},
+		// occlusion shares the metallicRoughness texture, so it's packed into its red channel
+		{key: gltfTextureKey{texture: 0, textureType: Packed, channels: ORM}},
+	}
+	if len(p.textures) != len(expected) {
+		t.Fatalf("got textures %+v, expected %+v", p.textures, expected)
+	}
+	for i := range expected {
+		if p.textures[i] != expected[i] {
+			t.Errorf("texture %d: got %+v, expected %+v", i, p.textures[i], expected[i])
+		}
+	}
+
+	// the image is decoded once and flipped into OpenGL order, with the bottom row first
+	if len(scene.images) != 1 {
+		t.Fatalf("expected one decoded image, got %d", len(scene.images))
+	}
+	if c := scene.images[0].RGBAAt(0, 0); c != (color.RGBA{B: 255, A: 255}) {
+		t.Errorf("first row is %v, expected the blue bottom row", c)
+	}
+}
+
+func TestDecodeGLTFCycle(t *testing.T) {
+	saved := assetFS
+	defer func() { assetFS = saved }()
+	assetFS = testGLTFFS(t, strings.Replace(testGLTF, `"name": "left", "mesh": 0,`, `"name": "left", "mesh": 0, "children": [0],`, 1))
+
+	if _, err := decodeGLTF("models/scene.gltf"); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+}
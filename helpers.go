@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"go/build"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
@@ -59,6 +58,13 @@ func initWindow() (*glfw.Window, error) {
 			}
 		}
 
+		if action == glfw.Release && key == glfw.KeyF12 {
+			saveScreenshot = true
+		}
+		if action == glfw.Release && key == glfw.KeyF11 {
+			saveScene = true
+		}
+
 		if action == glfw.Press {
 			keys[key] = true
 		} else if action == glfw.Release {
@@ -102,14 +108,3 @@ func initGL() error {
 	//gl.Enable(gl.FRAMEBUFFER_SRGB)
 	return nil
 }
-
-// importPathToDir resolves the absolute path from importPath.
-// There doesn't need to be a valid Go package inside that import path,
-// but the directory must exist.
-func importPathToDir(importPath string) (string, error) {
-	p, err := build.Import(importPath, "", build.FindOnly)
-	if err != nil {
-		return "", err
-	}
-	return p.Dir, nil
-}
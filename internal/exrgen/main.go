@@ -0,0 +1,492 @@
+//go:build ignore
+
+// Exrgen writes the EXR decoder's test images into lib/exr/testdata, a 16x44 RGB image in half and float pixels for
+// each of the NONE, ZIP and PIZ compression methods. It has its own encoder, which follows ImfZip, ImfPizCompressor,
+// ImfWav and ImfHuf from the OpenEXR reference implementation, so that the decoder isn't tested against itself. The
+// images in lib/exr/testdata/openexr are written by the reference implementation itself and check both against it.
+// Run it from the root of the repository with
+//
+//	go run internal/exrgen/main.go
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	width  = 16
+	height = 44
+
+	pixelHalf  = 1
+	pixelFloat = 2
+
+	compressionNone = 0
+	compressionZIP  = 3
+	compressionPIZ  = 4
+)
+
+// value is the pixel value of channel c (0 is red), it must match the one in the tests. The values are multiples of
+// 1/32 between 1 and 1.5, which are exact in half floats and give the wavelet and Huffman coding something to compress,
+// and every fifth line is flat so that the Huffman coder writes runs.
+func value(x, y, c int) float32 {
+	if y%5 == 0 {
+		return 1.25
+	}
+	return 1 + float32((x*3+y*5+c*7)%16)/32
+}
+
+func main() {
+	for _, compression := range []struct {
+		name   string
+		method uint8
+	}{{"none", compressionNone}, {"zip", compressionZIP}, {"piz", compressionPIZ}} {
+		for _, pixel := range []struct {
+			name string
+			typ  int32
+		}{{"half", pixelHalf}, {"float", pixelFloat}} {
+			file := filepath.Join("lib", "exr", "testdata", fmt.Sprintf("%s_%s.exr", compression.name, pixel.name))
+			if err := os.WriteFile(file, encode(compression.method, pixel.typ), 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func encode(compression uint8, pixelType int32) []byte {
+	var b bytes.Buffer
+	b.WriteString("\x76\x2f\x31\x01")
+	put(&b, uint32(2))
+
+	// the channels are sorted by name
+	var chlist bytes.Buffer
+	for _, name := range []string{"B", "G", "R"} {
+		chlist.WriteString(name + "\x00")
+		put(&chlist, pixelType)
+		chlist.Write([]byte{0, 0, 0, 0})
+		put(&chlist, int32(1))
+		put(&chlist, int32(1))
+	}
+	chlist.WriteByte(0)
+	window := box(0, 0, width-1, height-1)
+	attribute(&b, "channels", "chlist", chlist.Bytes())
+	attribute(&b, "compression", "compression", []byte{compression})
+	attribute(&b, "dataWindow", "box2i", window)
+	attribute(&b, "displayWindow", "box2i", window)
+	attribute(&b, "lineOrder", "lineOrder", []byte{0})
+	attribute(&b, "pixelAspectRatio", "float", float(1))
+	attribute(&b, "screenWindowCenter", "v2f", append(float(0), float(0)...))
+	attribute(&b, "screenWindowWidth", "float", float(1))
+	b.WriteByte(0)
+
+	lines := 1
+	switch compression {
+	case compressionZIP:
+		lines = 16
+	case compressionPIZ:
+		lines = 32
+	}
+	var chunks [][]byte
+	for y := 0; y < height; y += lines {
+		n := lines
+		if y+n > height {
+			n = height - y
+		}
+		raw := scanlines(y, n, pixelType)
+		packed := raw
+		switch compression {
+		case compressionZIP:
+			packed = zip(raw)
+		case compressionPIZ:
+			packed = piz(raw, pixelType, n)
+		}
+		// like the reference implementation, chunks that don't get smaller are stored uncompressed
+		if compression != compressionNone && len(packed) >= len(raw) {
+			fmt.Fprintf(os.Stderr, "chunk at line %d doesn't compress, storing it uncompressed\n", y)
+			packed = raw
+		}
+		var chunk bytes.Buffer
+		put(&chunk, int32(y))
+		put(&chunk, int32(len(packed)))
+		chunk.Write(packed)
+		chunks = append(chunks, chunk.Bytes())
+	}
+
+	offset := b.Len() + 8*len(chunks)
+	for _, chunk := range chunks {
+		put(&b, uint64(offset))
+		offset += len(chunk)
+	}
+	for _, chunk := range chunks {
+		b.Write(chunk)
+	}
+	return b.Bytes()
+}
+
+func put(b *bytes.Buffer, v interface{}) {
+	binary.Write(b, binary.LittleEndian, v)
+}
+
+func attribute(b *bytes.Buffer, name, typ string, value []byte) {
+	b.WriteString(name + "\x00" + typ + "\x00")
+	put(b, int32(len(value)))
+	b.Write(value)
+}
+
+func box(xMin, yMin, xMax, yMax int32) []byte {
+	var b bytes.Buffer
+	put(&b, [4]int32{xMin, yMin, xMax, yMax})
+	return b.Bytes()
+}
+
+func float(f float32) []byte {
+	var b bytes.Buffer
+	put(&b, f)
+	return b.Bytes()
+}
+
+// scanlines returns n uncompressed lines starting at y, every line has all its blue, then green, then red pixels
+func scanlines(y, n int, pixelType int32) []byte {
+	var b bytes.Buffer
+	for line := y; line < y+n; line++ {
+		for _, c := range []int{2, 1, 0} {
+			for x := 0; x < width; x++ {
+				if pixelType == pixelHalf {
+					put(&b, toHalf(value(x, line, c)))
+				} else {
+					put(&b, value(x, line, c))
+				}
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+// toHalf converts floats that are exact in half precision and in the normal range
+func toHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	exponent := int(bits>>23&0xff) - 127 + 15
+	return uint16(bits>>16&0x8000) | uint16(exponent)<<10 | uint16(bits>>13&0x3ff)
+}
+
+// zip interleaves the even and odd bytes, applies the delta predictor and deflates the result
+func zip(raw []byte) []byte {
+	tmp := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i += 2 {
+		tmp = append(tmp, raw[i])
+	}
+	for i := 1; i < len(raw); i += 2 {
+		tmp = append(tmp, raw[i])
+	}
+	for i := len(tmp) - 1; i > 0; i-- {
+		tmp[i] = tmp[i] - tmp[i-1] + 128
+	}
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(tmp)
+	w.Close()
+	return b.Bytes()
+}
+
+const (
+	bitmapSize  = 1 << 13
+	ushortRange = 1 << 16
+)
+
+// piz gathers the lines of each channel into one block of 16 bit words, maps the words that are used onto a dense
+// range, wavelet transforms every channel block and Huffman codes the result
+func piz(raw []byte, pixelType int32, lines int) []byte {
+	wordsPerPixel := 1
+	if pixelType == pixelFloat {
+		wordsPerPixel = 2
+	}
+	words := make([]uint16, len(raw)/2)
+	block := width * lines * wordsPerPixel
+	lineWords := width * wordsPerPixel
+	pos := 0
+	for line := 0; line < lines; line++ {
+		for c := 0; c < 3; c++ {
+			for i := 0; i < lineWords; i++ {
+				words[c*block+line*lineWords+i] = binary.LittleEndian.Uint16(raw[pos:])
+				pos += 2
+			}
+		}
+	}
+
+	var bitmap [bitmapSize]byte
+	for _, w := range words {
+		bitmap[w>>3] |= 1 << (w & 7)
+	}
+	// zero is always in the table and isn't stored
+	bitmap[0] &^= 1
+	minNonZero, maxNonZero := bitmapSize-1, 0
+	for i, b := range bitmap {
+		if b != 0 {
+			if i < minNonZero {
+				minNonZero = i
+			}
+			if i > maxNonZero {
+				maxNonZero = i
+			}
+		}
+	}
+
+	lut := make([]uint16, ushortRange)
+	k := 0
+	for i := 0; i < ushortRange; i++ {
+		if i == 0 || bitmap[i>>3]&(1<<uint(i&7)) != 0 {
+			lut[i] = uint16(k)
+			k++
+		}
+	}
+	maxValue := uint16(k - 1)
+	for i, w := range words {
+		words[i] = lut[w]
+	}
+
+	for c := 0; c < 3; c++ {
+		for j := 0; j < wordsPerPixel; j++ {
+			wav2Encode(words[c*block+j:], width, wordsPerPixel, lines, width*wordsPerPixel, maxValue)
+		}
+	}
+
+	var b bytes.Buffer
+	put(&b, uint16(minNonZero))
+	put(&b, uint16(maxNonZero))
+	if minNonZero <= maxNonZero {
+		b.Write(bitmap[minNonZero : maxNonZero+1])
+	}
+	compressed := hufCompress(words)
+	put(&b, int32(len(compressed)))
+	b.Write(compressed)
+	return b.Bytes()
+}
+
+// wav2Encode does an in place 2D haar wavelet transform of nx * ny values, which are ox apart horizontally and oy apart
+// vertically
+func wav2Encode(in []uint16, nx, ox, ny, oy int, mx uint16) {
+	enc := wenc16
+	if mx < 1<<14 {
+		enc = wenc14
+	}
+	n := ny
+	if nx < n {
+		n = nx
+	}
+	p, p2 := 1, 2
+	for p2 <= n {
+		py := 0
+		ey := oy * (ny - p2)
+		oy1, oy2 := oy*p, oy*p2
+		ox1, ox2 := ox*p, ox*p2
+		for ; py <= ey; py += oy2 {
+			px := py
+			ex := py + ox*(nx-p2)
+			for ; px <= ex; px += ox2 {
+				p01 := px + ox1
+				p10 := px + oy1
+				p11 := p10 + ox1
+				i00, i01 := enc(in[px], in[p01])
+				i10, i11 := enc(in[p10], in[p11])
+				in[px], in[p10] = enc(i00, i10)
+				in[p01], in[p11] = enc(i01, i11)
+			}
+			// odd column
+			if nx&p != 0 {
+				p10 := px + oy1
+				in[px], in[p10] = enc(in[px], in[p10])
+			}
+		}
+		// odd row
+		if ny&p != 0 {
+			px := py
+			ex := py + ox*(nx-p2)
+			for ; px <= ex; px += ox2 {
+				p01 := px + ox1
+				in[px], in[p01] = enc(in[px], in[p01])
+			}
+		}
+		p = p2
+		p2 <<= 1
+	}
+}
+
+func wenc14(a, b uint16) (uint16, uint16) {
+	as, bs := int(int16(a)), int(int16(b))
+	return uint16(int16((as + bs) >> 1)), uint16(int16(as - bs))
+}
+
+func wenc16(a, b uint16) (uint16, uint16) {
+	const offset = 1 << 15
+	ao := (int(a) + offset) & 0xffff
+	m := (ao + int(b)) >> 1
+	d := ao - int(b)
+	if d < 0 {
+		m = (m + offset) & 0xffff
+	}
+	return uint16(m), uint16(d & 0xffff)
+}
+
+const (
+	hufEncSize       = 1<<16 + 1
+	shortZeroCodeRun = 59
+	longZeroCodeRun  = 63
+	shortestLongRun  = 2 + longZeroCodeRun - shortZeroCodeRun
+	longestLongRun   = 255 + shortestLongRun
+)
+
+// hufCompress writes the header, the packed code lengths and the coded words
+func hufCompress(words []uint16) []byte {
+	freq := make([]int, hufEncSize)
+	for _, w := range words {
+		freq[w]++
+	}
+	im, iM := hufEncSize, 0
+	for i, f := range freq {
+		if f > 0 {
+			if i < im {
+				im = i
+			}
+			iM = i
+		}
+	}
+	// the pseudo symbol after the largest word marks a run of the previous word
+	iM++
+	freq[iM] = 1
+	codes := hufCodeLengths(freq)
+	hufCanonicalCodeTable(codes)
+
+	table := &bitWriter{}
+	for i := im; i <= iM; i++ {
+		l := codes[i] & 63
+		if l == 0 {
+			run := 1
+			for i < iM && run < longestLongRun && codes[i+1]&63 == 0 {
+				i++
+				run++
+			}
+			if run >= 2 {
+				if run >= shortestLongRun {
+					table.bits(6, longZeroCodeRun)
+					table.bits(8, uint64(run-shortestLongRun))
+				} else {
+					table.bits(6, uint64(shortZeroCodeRun+run-2))
+				}
+				continue
+			}
+		}
+		table.bits(6, l)
+	}
+	table.flush()
+
+	data := &bitWriter{}
+	send := func(code uint64, runs int) {
+		rlc := codes[iM]
+		if int(code&63+rlc&63)+8 < int(code&63)*runs {
+			data.code(code)
+			data.code(rlc)
+			data.bits(8, uint64(runs))
+			return
+		}
+		for ; runs >= 0; runs-- {
+			data.code(code)
+		}
+	}
+	s, runs := words[0], 0
+	for _, w := range words[1:] {
+		if w == s && runs < 255 {
+			runs++
+		} else {
+			send(codes[s], runs)
+			runs = 0
+		}
+		s = w
+	}
+	send(codes[s], runs)
+	nBits := len(data.out)*8 + data.lc
+	data.flush()
+
+	var b bytes.Buffer
+	put(&b, [5]uint32{uint32(im), uint32(iM), uint32(len(table.out)), uint32(nBits), 0})
+	b.Write(table.out)
+	b.Write(data.out)
+	return b.Bytes()
+}
+
+// hufCodeLengths returns the length of the Huffman code of every symbol with a frequency
+func hufCodeLengths(freq []int) []uint64 {
+	type node struct {
+		freq    int
+		symbols []int
+	}
+	var nodes []node
+	for i, f := range freq {
+		if f > 0 {
+			nodes = append(nodes, node{f, []int{i}})
+		}
+	}
+	lengths := make([]uint64, len(freq))
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].freq < nodes[j].freq })
+		merged := node{nodes[0].freq + nodes[1].freq, append(append([]int{}, nodes[0].symbols...), nodes[1].symbols...)}
+		for _, s := range merged.symbols {
+			lengths[s]++
+		}
+		nodes = append([]node{merged}, nodes[2:]...)
+	}
+	return lengths
+}
+
+// hufCanonicalCodeTable turns code lengths into canonical codes, stored as code << 6 | length
+func hufCanonicalCodeTable(codes []uint64) {
+	var n [59]uint64
+	for _, l := range codes {
+		n[l]++
+	}
+	var c uint64
+	for i := 58; i > 0; i-- {
+		nc := (c + n[i]) >> 1
+		n[i] = c
+		c = nc
+	}
+	for i, l := range codes {
+		if l > 0 {
+			codes[i] = l | n[l]<<6
+			n[l]++
+		}
+	}
+}
+
+// bitWriter writes bits most significant first
+type bitWriter struct {
+	out []byte
+	c   uint64
+	lc  int
+}
+
+func (w *bitWriter) bits(n int, v uint64) {
+	w.c = w.c<<uint(n) | v
+	w.lc += n
+	for w.lc >= 8 {
+		w.lc -= 8
+		w.out = append(w.out, byte(w.c>>uint(w.lc)))
+	}
+}
+
+func (w *bitWriter) code(code uint64) {
+	w.bits(int(code&63), code>>6)
+}
+
+func (w *bitWriter) flush() {
+	if w.lc > 0 {
+		w.out = append(w.out, byte(w.c<<uint(8-w.lc)))
+		w.lc = 0
+	}
+}
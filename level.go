@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stojg/cspace/lib/scenefile"
+)
+
+// defaultLevel is the scene that is loaded when no scene file is given
+const defaultLevel = "scenes/pbr.json"
+
+// levelTextures are the material texture keys of a scene file in the order they're added to the meshes, with the
+// texture type they're loaded as
+var levelTextures = []struct {
+	key          string
+	texType      TextureType
+	gammaCorrect bool
+}{
+	{"albedo", Albedo, true},
+	{"metallic", Metallic, false},
+	{"roughness", Roughness, false},
+	{"normal", Normal, false},
+	{"ao", AO, false},
+	{"emissive", Emissive, true},
+	{"orm", Packed, false},
+	{"mr", Packed, false},
+}
+
+// ReadLevel reads a scene file from disk, or the built in default scene when path is empty
+func ReadLevel(path string) (*scenefile.Scene, error) {
+	if path == "" {
+		return scenefile.OpenFS(assetFS, defaultLevel)
+	}
+	return scenefile.Open(path)
+}
+
+// Load sets up the environment, camera and lights from the level and adds its nodes to the graph. The models and
+// textures are loaded in the background, the nodes can be found by name or tag straight away, except for the nodes
+// inside glTF files which are added once the file has loaded.
+func (s *Scene) Load(level *scenefile.Scene) error {
+	if len(level.PointLights) > maxPointLights {
+		return fmt.Errorf("the scene has %d point lights, at most %d are supported", len(level.PointLights), maxPointLights)
+	}
+	var pointLights []*PointLight
+	for i, l := range level.PointLights {
+		att, ok := ligthAtt[l.RangeOrDefault()]
+		if !ok {
+			return fmt.Errorf("pointLights[%d]: there is no attenuation for range %d", i, l.RangeOrDefault())
+		}
+		pointLights = append(pointLights, &PointLight{
+			Position: l.Position,
+			Color:    l.Color,
+			Constant: att.Constant,
+			Linear:   att.Linear,
+			Exp:      att.Exp,
+		})
+	}
+	if pointLights != nil {
+		s.pointLights = pointLights
+		s.randomLights = false
+		currentNumLights = len(pointLights)
+	}
+
+	s.SetEnvironment(level.Environment)
+	if c := level.Camera; c != nil {
+		s.camera.Place(c.Position, c.Yaw, c.Pitch)
+	}
+	if l := level.DirectionalLight; l != nil {
+		directionLight.Direction = normalise(l.Direction)
+		directionLight.Color = l.Color
+		dirLightOn = !l.Disabled
+		s.shadow.SetLight(directionLight)
+	}
+
+	loader := &levelLoader{level: level}
+	for _, n := range level.Nodes {
+		if err := loader.addNode(s.graph, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetEnvironment loads the HDR environment map from the textures and updates the image based lighting and sky box with
+// it. A grey environment is used when file is empty or can't be loaded.
+func (s *Scene) SetEnvironment(file string) {
+	s.environment = file
+	envTexture := FallbackHDRTexture()
+	if file != "" {
+		texture, err := LoadHDRTexture(file)
+		if err != nil {
+			glError(fmt.Errorf("Scene.SetEnvironment: %v, using a grey environment", err))
+		} else {
+			envTexture = texture
+		}
+	}
+	s.ibl.Update(envTexture)
+}
+
+type levelLoader struct {
+	level *scenefile.Scene
+}
+
+// levelRepeat is the copies that a scene file node with a repeat was loaded as
+type levelRepeat struct {
+	repeat    scenefile.Repeat
+	transform scenefile.Transform
+	copies    []*Node
+}
+
+// addNode adds a node of the level, and its children, to the parent once for every copy
+func (l *levelLoader) addNode(parent NodeParent, n *scenefile.Node) error {
+	var repeat *levelRepeat
+	if n.Repeat != nil {
+		repeat = &levelRepeat{repeat: *n.Repeat, transform: n.Transform}
+	}
+	var nodes []*Node
+	for _, offset := range n.Copies() {
+		trs := n.Transform
+		if offset != [3]float32{} {
+			trs = trs.Translate(offset)
+		}
+		t := mgl32.Mat4(trs.Mat4())
+		var node *Node
+		if n.GLTF != "" {
+			node = LoadGLTFAsync(n.GLTF, parent)
+			node.SetTransform(t)
+		} else {
+			node = parent.Add(nil, t)
+		}
+		node.trs = &trs
+		if repeat != nil {
+			node.repeat = repeat
+			repeat.copies = append(repeat.copies, node)
+		}
+		if n.Name != "" {
+			node.SetName(n.Name)
+		}
+		node.Tag(n.Tags...)
+		node.SetVisible(!n.Hidden)
+		for _, child := range n.Children {
+			if err := l.addNode(node, child); err != nil {
+				return err
+			}
+		}
+		nodes = append(nodes, node)
+	}
+
+	if n.Model == "" {
+		return nil
+	}
+	material := l.level.Materials[n.Material]
+	shaderType := TexturedMesh
+	if material.ShaderOrDefault() == scenefile.Flat {
+		shaderType = MaterialMesh
+	}
+	// every copy gets its own instances of the meshes and references to the textures, so that it can be released on
+	// its own
+	for _, node := range nodes {
+		node := node
+		textures := l.materialTextures(n.Material)
+		LoadModelAsync(n.Model, shaderType, func(meshes []*Mesh) {
+			for _, mesh := range meshes {
+				applyMaterial(mesh, n.Material, material, textures)
+			}
+			if node.released {
+				assets.ReleaseModel(meshes)
+				return
+			}
+			node.addMeshes(meshes)
+		})
+	}
+	return nil
+}
+
+// materialTextures starts loading the textures of the material, the textures are shared with the other users of them
+// but every call takes a new reference
+func (l *levelLoader) materialTextures(name string) []*Texture {
+	material := l.level.Materials[name]
+	if material == nil {
+		return nil
+	}
+	var textures []*Texture
+	for _, t := range levelTextures {
+		file, ok := material.Textures[t.key]
+		if !ok {
+			continue
+		}
+		switch t.key {
+		case "orm":
+			textures = append(textures, LoadPackedTextureAsync(file, ORM))
+		case "mr":
+			textures = append(textures, LoadPackedTextureAsync(file, MR))
+		default:
+			textures = append(textures, LoadTextureAsync(t.texType, file, t.gammaCorrect))
+		}
+	}
+	return textures
+}
+
+// applyMaterial sets the values and textures of the material that are given, the rest is kept from the model
+func applyMaterial(mesh *Mesh, name string, material *scenefile.Material, textures []*Texture) {
+	if material == nil {
+		return
+	}
+	mesh.levelMaterial = material
+	mesh.levelMaterialName = name
+	// the material's textures go first, since only the first texture of a type is drawn
+	mesh.Textures = append(append([]*Texture(nil), textures...), mesh.Textures...)
+	if material.Albedo != nil {
+		mesh.Albedo = *material.Albedo
+	}
+	if material.Metallic != nil {
+		mesh.Metallic = *material.Metallic
+	}
+	if material.Roughness != nil {
+		mesh.Roughness = *material.Roughness
+	}
+	if material.Emissive != nil {
+		mesh.Emissive = *material.Emissive
+	}
+	if material.LineWidth != nil {
+		mesh.LineWidth = *material.LineWidth
+	}
+	if material.PointSize != nil {
+		mesh.PointSize = *material.PointSize
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/stojg/cspace/lib/scenefile"
+)
+
+// SaveLevel writes the scene, as it is now, to a scene file that Load reads back into the same scene
+func (s *Scene) SaveLevel(path string) error {
+	level, err := s.Level()
+	if err != nil {
+		return err
+	}
+	return scenefile.Save(path, level)
+}
+
+// Level describes the scene graph, the lights, the environment and the camera as a scene file. The copies of a repeat
+// are written as the repeat while they're all there and unchanged, and as a node each otherwise. The nodes loaded from
+// a glTF file are written as a reference to the file, changes to them aren't kept. Models and textures must have been
+// loaded from the assets and be done loading.
+func (s *Scene) Level() (*scenefile.Scene, error) {
+	if !loadsDone() {
+		return nil, fmt.Errorf("the scene can't be saved while models and textures are loading")
+	}
+	level := &scenefile.Scene{
+		Environment: s.environment,
+		Camera: &scenefile.Camera{
+			Position: s.camera.position,
+			Yaw:      s.camera.yaw,
+			Pitch:    s.camera.pitch,
+		},
+		DirectionalLight: &scenefile.DirectionalLight{
+			Direction: directionLight.Direction,
+			Color:     directionLight.Color,
+			Disabled:  !dirLightOn,
+		},
+	}
+	// the random lights are the same every time, they are only saved when the scene has its own
+	if !s.randomLights {
+		for i, l := range s.pointLights {
+			r, ok := attenuationRange(l)
+			if !ok {
+				return nil, fmt.Errorf("point light %d has an attenuation that isn't in ligthAtt", i)
+			}
+			level.PointLights = append(level.PointLights, scenefile.PointLight{Position: l.Position, Color: l.Color, Range: r})
+		}
+	}
+
+	w := &levelWriter{level: level, repeats: make(map[*levelRepeat]bool)}
+	nodes, err := w.nodes(s.graph.Children())
+	if err != nil {
+		return nil, err
+	}
+	level.Nodes = nodes
+	if level.Nodes == nil {
+		level.Nodes = []*scenefile.Node{}
+	}
+	return level, nil
+}
+
+// attenuationRange returns the ligthAtt key of the attenuation of the light
+func attenuationRange(l *PointLight) (int, bool) {
+	for r, att := range ligthAtt {
+		if att.Constant == l.Constant && att.Linear == l.Linear && att.Exp == l.Exp {
+			if r == scenefile.DefaultRange {
+				return 0, true
+			}
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+type levelWriter struct {
+	level *scenefile.Scene
+	// repeats are the repeats that have been seen, and whether they were written as a repeat or as a node per copy
+	repeats map[*levelRepeat]bool
+}
+
+func (w *levelWriter) nodes(children []*Node) ([]*scenefile.Node, error) {
+	var nodes []*scenefile.Node
+	for _, child := range children {
+		// meshes are written as the model of their parent, and nodes from a glTF file as the file
+		if child.mesh != nil || child.imported {
+			continue
+		}
+		if r := child.repeat; r != nil {
+			written, seen := w.repeats[r]
+			if written {
+				continue
+			}
+			if !seen {
+				n, err := w.repeat(r, child)
+				if err != nil {
+					return nil, err
+				}
+				w.repeats[r] = n != nil
+				if n != nil {
+					nodes = append(nodes, n)
+					continue
+				}
+			}
+		}
+		n, err := w.node(child)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func (w *levelWriter) node(node *Node) (*scenefile.Node, error) {
+	n := &scenefile.Node{
+		Name:   node.name,
+		Tags:   append([]string(nil), node.tags...),
+		Hidden: node.hidden,
+		GLTF:   node.gltf,
+	}
+	if n.GLTF != "" && n.Name == n.GLTF {
+		n.Name = ""
+	}
+	if node.trs != nil {
+		n.Transform = *node.trs
+	} else {
+		n.Transform = scenefile.Decompose(node.transform)
+	}
+
+	var meshes []*Mesh
+	for _, child := range node.children {
+		if child.mesh != nil {
+			meshes = append(meshes, child.mesh)
+		}
+	}
+	if len(meshes) > 0 {
+		if err := w.model(n, meshes); err != nil {
+			return nil, fmt.Errorf("node %q: %v", node.name, err)
+		}
+	}
+
+	children, err := w.nodes(node.children)
+	if err != nil {
+		return nil, err
+	}
+	n.Children = children
+	return n, nil
+}
+
+// repeat writes the copies of a repeat as the node they were loaded from, starting at the first copy. It returns nil
+// when copies have been removed, moved or changed since they were loaded.
+func (w *levelWriter) repeat(r *levelRepeat, first *Node) (*scenefile.Node, error) {
+	if first != r.copies[0] {
+		return nil, nil
+	}
+	var n *scenefile.Node
+	for _, c := range r.copies {
+		// trs is dropped when the transform of a copy changes
+		if c.parent != first.parent || c.trs == nil {
+			return nil, nil
+		}
+		copyNode, err := w.node(c)
+		if err != nil {
+			return nil, err
+		}
+		copyNode.Transform = r.transform
+		if n == nil {
+			n = copyNode
+		} else if !reflect.DeepEqual(copyNode, n) {
+			return nil, nil
+		}
+	}
+	repeat := r.repeat
+	n.Repeat = &repeat
+	return n, nil
+}
+
+// model sets the model and material of the node from its meshes, which must be all of the meshes of one model
+func (w *levelWriter) model(n *scenefile.Node, meshes []*Mesh) error {
+	model := meshes[0].model
+	if model == nil {
+		return fmt.Errorf("mesh %q wasn't loaded from a model", meshes[0].Name)
+	}
+	if len(meshes) != len(model.meshes) {
+		return fmt.Errorf("has %d of the %d meshes of %s", len(meshes), len(model.meshes), model.key.directory)
+	}
+	var material *scenefile.Material
+	var name string
+	for i, mesh := range meshes {
+		if mesh.model != model {
+			return fmt.Errorf("has meshes from both %s and %s", model.key.directory, mesh.model.key.directory)
+		}
+		m, err := meshMaterial(mesh, model.meshes[i])
+		if err != nil {
+			return fmt.Errorf("mesh %q: %v", mesh.Name, err)
+		}
+		if i == 0 {
+			material, name = m, mesh.levelMaterialName
+		} else if !reflect.DeepEqual(m, material) {
+			return fmt.Errorf("the meshes of %s have different materials, a scene file has one material per model", model.key.directory)
+		}
+	}
+	n.Model = model.key.directory
+	if material != nil {
+		n.Material = w.material(name, material)
+	}
+	return nil
+}
+
+// material adds the material to the level and returns its name, the name is suffixed with a number when it's already
+// used by a different material
+func (w *levelWriter) material(name string, material *scenefile.Material) string {
+	if w.level.Materials == nil {
+		w.level.Materials = make(map[string]*scenefile.Material)
+	}
+	if name == "" {
+		name = "material"
+	}
+	unique := name
+	for i := 2; ; i++ {
+		existing, ok := w.level.Materials[unique]
+		if !ok {
+			w.level.Materials[unique] = material
+			return unique
+		}
+		if reflect.DeepEqual(existing, material) {
+			return unique
+		}
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// meshMaterial returns what the mesh has been changed to since it was loaded from the model, as a material. The values
+// that the scene file material set are always written, even when the model has the same ones.
+func meshMaterial(mesh, model *Mesh) (*scenefile.Material, error) {
+	m := &scenefile.Material{}
+	set := &scenefile.Material{}
+	if mesh.levelMaterial != nil {
+		set = mesh.levelMaterial
+		m.Shader = set.Shader
+	}
+	if mesh.MeshType == MaterialMesh {
+		m.Shader = scenefile.Flat
+	}
+	if set.Albedo != nil || mesh.Albedo != model.Albedo {
+		albedo := mesh.Albedo
+		m.Albedo = &albedo
+	}
+	if set.Metallic != nil || mesh.Metallic != model.Metallic {
+		metallic := mesh.Metallic
+		m.Metallic = &metallic
+	}
+	if set.Roughness != nil || mesh.Roughness != model.Roughness {
+		roughness := mesh.Roughness
+		m.Roughness = &roughness
+	}
+	if set.Emissive != nil || mesh.Emissive != model.Emissive {
+		emissive := mesh.Emissive
+		m.Emissive = &emissive
+	}
+	if set.LineWidth != nil || mesh.LineWidth != model.LineWidth {
+		lineWidth := mesh.LineWidth
+		m.LineWidth = &lineWidth
+	}
+	if set.PointSize != nil || mesh.PointSize != model.PointSize {
+		pointSize := mesh.PointSize
+		m.PointSize = &pointSize
+	}
+
+	// the textures of the model come last, the ones added before them are the material's
+	textures := mesh.Textures
+	if n := len(textures) - len(model.Textures); n >= 0 && reflect.DeepEqual(textures[n:], model.Textures) {
+		textures = textures[:n]
+	}
+	for _, texture := range textures {
+		key, file, err := levelTexture(texture)
+		if err != nil {
+			return nil, err
+		}
+		if m.Textures == nil {
+			m.Textures = make(map[string]string)
+		}
+		// only the first texture of a type is used when drawing
+		if _, ok := m.Textures[key]; !ok {
+			m.Textures[key] = file
+		}
+	}
+	if reflect.DeepEqual(m, &scenefile.Material{}) {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// levelTexture returns the material texture key and the file, relative to the textures, of the texture
+func levelTexture(texture *Texture) (string, string, error) {
+	if texture.asset == nil {
+		return "", "", fmt.Errorf("a %s texture wasn't loaded from a file", texture.textureType)
+	}
+	file := texture.asset.key.file
+	if !strings.HasPrefix(file, "textures/") {
+		return "", "", fmt.Errorf("texture %s isn't in the textures", file)
+	}
+	file = strings.TrimPrefix(file, "textures/")
+	switch {
+	case texture.textureType != Packed:
+		return string(texture.textureType), file, nil
+	case texture.channels == ORM:
+		return "orm", file, nil
+	case texture.channels == MR:
+		return "mr", file, nil
+	}
+	return "", "", fmt.Errorf("texture %s has channels %v that a scene file can't describe", file, texture.channels)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stojg/cspace/lib/scenefile"
+)
+
+// TestLevelRoundTrip loads a level into a graph, saves the graph as a level and loads that into a second graph, which
+// must have the same nodes, transforms and materials. The model and textures are put in the assets up front, so that
+// nothing is loaded or uploaded.
+func TestLevelRoundTrip(t *testing.T) {
+	defer func(saved *Assets) { assets = saved }(assets)
+	assets = NewAssets()
+	model := &modelAsset{key: modelKey{directory: "models/cube", shaderType: TexturedMesh}}
+	model.loaded([]*Mesh{{
+		Name:      "cube",
+		MeshType:  TexturedMesh,
+		Albedo:    [3]float32{1, 1, 1},
+		Metallic:  0.5,
+		Roughness: 0.5,
+		LineWidth: DefaultLineWidth,
+		PointSize: DefaultPointSize,
+	}})
+	model.refs++
+	assets.models[model.key] = model
+	for _, key := range []textureKey{
+		{file: "textures/rock/albedo.png", textureType: Albedo, gammaCorrect: true},
+		{file: "textures/rock/orm.png", textureType: Packed, channels: ORM},
+	} {
+		texture := &Texture{textureType: key.textureType, channels: key.channels}
+		texture.asset = &textureAsset{key: key, texture: texture, refs: 1, owned: true}
+		assets.textures[key] = texture.asset
+	}
+
+	width, roughness := float32(2), float32(0.25)
+	level := &scenefile.Scene{
+		Materials: map[string]*scenefile.Material{
+			"rock": {
+				Roughness: &roughness,
+				LineWidth: &width,
+				Textures:  map[string]string{"albedo": "rock/albedo.png", "orm": "rock/orm.png"},
+			},
+		},
+		Nodes: []*scenefile.Node{
+			{
+				Name:      "pillars",
+				Tags:      []string{"stone"},
+				Model:     "models/cube",
+				Material:  "rock",
+				Transform: scenefile.Transform{Translation: &[3]float32{1, 2, 3}, Scale: &[3]float32{1, 4, 1}},
+				Repeat:    &scenefile.Repeat{Count: [3]int{3, 1, 2}, Step: [3]float32{5, 0, 5}},
+				Children: []*scenefile.Node{{
+					Model:     "models/cube",
+					Transform: scenefile.Transform{Rotation: &scenefile.Rotation{Axis: [3]float32{0, 1, 0}, Degrees: 45}},
+				}},
+			},
+			{Name: "hidden", Hidden: true, Model: "models/cube"},
+		},
+	}
+
+	first := loadTestLevel(t, level)
+	saved, err := (&Scene{camera: NewCamera(), graph: first}).Level()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Nodes) != 2 || !reflect.DeepEqual(saved.Nodes[0].Repeat, level.Nodes[0].Repeat) {
+		t.Fatalf("the repeat wasn't saved as one node, got %d nodes", len(saved.Nodes))
+	}
+	if !reflect.DeepEqual(saved.Materials, level.Materials) {
+		t.Fatalf("got materials %+v, expected %+v", saved.Materials["rock"], level.Materials["rock"])
+	}
+	second := loadTestLevel(t, saved)
+	compareNodes(t, "graph", first.Children(), second.Children())
+
+	// a copy that is moved is saved on its own, and the rest of the copies with it
+	first.Children()[1].SetTransform(mgl32.Translate3D(0, 10, 0))
+	saved, err = (&Scene{camera: NewCamera(), graph: first}).Level()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Nodes) != 7 {
+		t.Fatalf("got %d nodes, expected a node for each of the 6 copies and the hidden node", len(saved.Nodes))
+	}
+	compareNodes(t, "moved copy", first.Children(), loadTestLevel(t, saved).Children())
+}
+
+func loadTestLevel(t *testing.T, level *scenefile.Scene) SceneNode {
+	t.Helper()
+	if err := level.Check(); err != nil {
+		t.Fatal(err)
+	}
+	graph := NewBaseNode()
+	loader := &levelLoader{level: level}
+	for _, n := range level.Nodes {
+		if err := loader.addNode(graph, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return graph
+}
+
+func compareNodes(t *testing.T, name string, expected, got []*Node) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("%s: got %d children, expected %d", name, len(got), len(expected))
+	}
+	for i, e := range expected {
+		g := got[i]
+		field := path.Join(name, e.name)
+		if g.name != e.name || !reflect.DeepEqual(g.tags, e.tags) || g.hidden != e.hidden {
+			t.Fatalf("%s: got name %q, tags %v and hidden %v, expected %q, %v and %v", field, g.name, g.tags, g.hidden, e.name, e.tags, e.hidden)
+		}
+		if !g.transform.ApproxEqualThreshold(e.transform, 1e-5) {
+			t.Fatalf("%s: got transform %v, expected %v", field, g.transform, e.transform)
+		}
+		if (g.mesh == nil) != (e.mesh == nil) {
+			t.Fatalf("%s: got mesh %v, expected %v", field, g.mesh, e.mesh)
+		}
+		if m := e.mesh; m != nil {
+			if g.mesh.model != m.model || g.mesh.Albedo != m.Albedo || g.mesh.Metallic != m.Metallic ||
+				g.mesh.Roughness != m.Roughness || g.mesh.Emissive != m.Emissive || g.mesh.LineWidth != m.LineWidth ||
+				g.mesh.PointSize != m.PointSize || !reflect.DeepEqual(g.mesh.Textures, m.Textures) {
+				t.Fatalf("%s: got material %+v, expected %+v", field, *g.mesh, *m)
+			}
+		}
+		compareNodes(t, field, e.children, g.children)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stojg/cspace/lib/obj"
+	"github.com/stojg/cspace/lib/scenefile"
+)
+
+// TestMaterialOverridesModelTextures gives meshes the textures of a model that has its own albedo, metallic and
+// roughness maps, without uploading them, and checks that the textures of a scene file material win over the model's
+func TestMaterialOverridesModelTextures(t *testing.T) {
+	materials, err := obj.ParseMtrFS(assetFS, "models/sphere_bot/model.mtl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadTexture := func(texType TextureType, file string, gammaCorrect bool) (*Texture, error) {
+		return &Texture{textureType: texType, asset: &textureAsset{key: textureKey{file: file, textureType: texType}}}, nil
+	}
+	override := &Texture{textureType: Albedo, asset: &textureAsset{key: textureKey{file: "textures/red.png", textureType: Albedo}}}
+	material := &scenefile.Material{Textures: map[string]string{"albedo": "red.png"}}
+
+	for name, mat := range materials {
+		model := &Mesh{Name: name, Textures: materialTextures(mat, loadTexture), MeshType: TexturedMesh}
+		if texture, _ := model.texture(Albedo); texture.asset == nil {
+			t.Fatalf("%s: the model has no albedo texture to override", name)
+		}
+		metallic, _ := model.texture(Metallic)
+
+		mesh := &Mesh{Name: model.Name, Textures: append([]*Texture(nil), model.Textures...), MeshType: TexturedMesh}
+		applyMaterial(mesh, "red", material, []*Texture{override})
+		if texture, _ := mesh.texture(Albedo); texture != override {
+			t.Errorf("%s: albedo is %s, expected the material's", name, texture.asset.key.file)
+		}
+		if texture, _ := mesh.texture(Metallic); texture != metallic {
+			t.Errorf("%s: the metallic texture of the model was replaced", name)
+		}
+
+		// only the material's textures are saved back to the scene file
+		saved, err := meshMaterial(mesh, model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(saved.Textures) != 1 || saved.Textures["albedo"] != "red.png" {
+			t.Errorf("%s: saved textures %v, expected only the albedo of the material", name, saved.Textures)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package exr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// unRLE expands run length encoded data, where a negative count is followed by that many literal bytes and a positive
+// count by a byte repeated count+1 times, and undoes the predictor and interleaving applied before compression
+func unRLE(packed []byte, size int) ([]byte, error) {
+	tmp := make([]byte, 0, size)
+	for i := 0; i < len(packed); {
+		count := int(int8(packed[i]))
+		i++
+		if count < 0 {
+			count = -count
+			if i+count > len(packed) || len(tmp)+count > size {
+				return nil, errors.New("corrupt RLE data")
+			}
+			tmp = append(tmp, packed[i:i+count]...)
+			i += count
+			continue
+		}
+		if i >= len(packed) || len(tmp)+count+1 > size {
+			return nil, errors.New("corrupt RLE data")
+		}
+		for j := 0; j <= count; j++ {
+			tmp = append(tmp, packed[i])
+		}
+		i++
+	}
+	if len(tmp) != size {
+		return nil, fmt.Errorf("RLE data expanded to %d bytes, expected %d", len(tmp), size)
+	}
+	return reconstruct(tmp), nil
+}
+
+// unZIP inflates zlib compressed data and undoes the predictor and interleaving applied before compression
+func unZIP(packed []byte, size int) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	tmp := make([]byte, size)
+	if _, err := io.ReadFull(zr, tmp); err != nil {
+		return nil, fmt.Errorf("inflating ZIP data: %v", err)
+	}
+	return reconstruct(tmp), nil
+}
+
+// reconstruct reverses the delta predictor and then interleaves the two halves of the buffer back into one
+func reconstruct(tmp []byte) []byte {
+	for i := 1; i < len(tmp); i++ {
+		tmp[i] = tmp[i-1] + tmp[i] - 128
+	}
+	out := make([]byte, len(tmp))
+	half := (len(tmp) + 1) / 2
+	for i := range out {
+		if i%2 == 0 {
+			out[i] = tmp[i/2]
+		} else {
+			out[i] = tmp[half+i/2]
+		}
+	}
+	return out
+}
@@ -0,0 +1,457 @@
+package exr
+
+// https://www.openexr.com/documentation/openexrfilelayout.pdf
+// https://www.openexr.com/documentation/TechnicalIntroduction.pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Magic is the four bytes every OpenEXR file starts with
+const Magic = "\x76\x2f\x31\x01"
+
+const (
+	flagTiled     = 0x200
+	flagLongNames = 0x400
+	flagDeep      = 0x800
+	flagMultiPart = 0x1000
+)
+
+// pixel types
+const (
+	pixelUint  = 0
+	pixelHalf  = 1
+	pixelFloat = 2
+)
+
+// compression methods
+const (
+	compressionNone  = 0
+	compressionRLE   = 1
+	compressionZIPS  = 2
+	compressionZIP   = 3
+	compressionPIZ   = 4
+	compressionPXR24 = 5
+	compressionB44   = 6
+	compressionB44A  = 7
+)
+
+var compressionNames = map[uint8]string{
+	compressionNone:  "NONE",
+	compressionRLE:   "RLE",
+	compressionZIPS:  "ZIPS",
+	compressionZIP:   "ZIP",
+	compressionPIZ:   "PIZ",
+	compressionPXR24: "PXR24",
+	compressionB44:   "B44",
+	compressionB44A:  "B44A",
+	8:                "DWAA",
+	9:                "DWAB",
+}
+
+type channel struct {
+	name      string
+	pixelType int32
+	xSampling int32
+	ySampling int32
+}
+
+// size returns the number of bytes per pixel
+func (c channel) size() int {
+	if c.pixelType == pixelHalf {
+		return 2
+	}
+	return 4
+}
+
+type header struct {
+	channels    []channel
+	compression uint8
+	xMin, yMin  int32
+	xMax, yMax  int32
+}
+
+// width and height are only valid once readHeader has checked the size of the data window
+func (h header) width() int {
+	return int(span(h.xMin, h.xMax))
+}
+
+func (h header) height() int {
+	return int(span(h.yMin, h.yMax))
+}
+
+// span returns the number of pixels from lo to hi, in int64 since a wide data window overflows an int32
+func span(lo, hi int32) int64 {
+	return int64(hi) - int64(lo) + 1
+}
+
+// linesPerChunk returns how many scanlines are stored in each chunk of the file for the compression method
+func (h header) linesPerChunk() int {
+	switch h.compression {
+	case compressionZIP, compressionPXR24:
+		return 16
+	case compressionPIZ, compressionB44, compressionB44A:
+		return 32
+	}
+	return 1
+}
+
+// Decode reads a scanline OpenEXR image and returns the width, height and the RGB float pixels of the data window,
+// starting at the top left corner. Images with only a luminance (Y) channel are returned as grey.
+func Decode(r io.Reader) (int, int, []float32, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	in := &reader{buf: buf}
+
+	h, err := readHeader(in)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	width, height := h.width(), h.height()
+	// the RGB channel indices in h.channels, -1 if the channel is missing
+	rgb := [3]int{-1, -1, -1}
+	for i, c := range h.channels {
+		switch c.name {
+		case "R":
+			rgb[0] = i
+		case "G":
+			rgb[1] = i
+		case "B":
+			rgb[2] = i
+		}
+	}
+	// luminance only images
+	if rgb == [3]int{-1, -1, -1} {
+		for i, c := range h.channels {
+			if c.name == "Y" {
+				rgb = [3]int{i, i, i}
+			}
+		}
+	}
+	if rgb == [3]int{-1, -1, -1} {
+		return 0, 0, nil, errors.New("exr: image has neither RGB nor Y channels")
+	}
+
+	lines := h.linesPerChunk()
+	chunks := (height + lines - 1) / lines
+	if chunks > (len(buf)-in.pos)/8 {
+		return 0, 0, nil, fmt.Errorf("exr: offset table of %d chunks is larger than the file", chunks)
+	}
+	offsets := make([]uint64, chunks)
+	for i := range offsets {
+		if offsets[i], err = in.uint64(); err != nil {
+			return 0, 0, nil, fmt.Errorf("exr: reading offset table: %v", err)
+		}
+	}
+
+	bytesPerLine := 0
+	for _, c := range h.channels {
+		bytesPerLine += width * c.size()
+	}
+
+	// a tiny file can claim a data window of gigabytes, so the chunks must be able to hold it before it's allocated
+	if err := checkChunks(h, buf, offsets, bytesPerLine); err != nil {
+		return 0, 0, nil, err
+	}
+
+	data := make([]float32, width*height*3)
+	for _, offset := range offsets {
+		in.pos = int(offset)
+		y, err := in.int32()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		size, err := in.int32()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		packed, err := in.bytes(int(size))
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("exr: reading chunk at line %d: %v", y, err)
+		}
+
+		first := int(int64(y) - int64(h.yMin))
+		if first < 0 || first >= height || first%lines != 0 {
+			return 0, 0, nil, fmt.Errorf("exr: chunk line %d outside of data window", y)
+		}
+		numLines := lines
+		if first+numLines > height {
+			numLines = height - first
+		}
+
+		raw := packed
+		if expected := bytesPerLine * numLines; len(packed) < expected {
+			if raw, err = uncompress(h, packed, width, numLines, expected); err != nil {
+				return 0, 0, nil, fmt.Errorf("exr: chunk at line %d: %v", y, err)
+			}
+		} else if len(packed) != expected {
+			return 0, 0, nil, fmt.Errorf("exr: chunk at line %d has %d bytes, expected %d", y, len(packed), expected)
+		}
+
+		readPixels(h, raw, width, first, numLines, rgb, data)
+	}
+	return width, height, data, nil
+}
+
+// maxExpansion is how many times larger than its compressed size a chunk can be when uncompressed. RLE expands 2 bytes
+// into 128, deflate 2 bits into 258 bytes, and PIZ 9 bits into a run of 255 16 bit values.
+func maxExpansion(compression uint8) uint64 {
+	switch compression {
+	case compressionNone:
+		return 1
+	case compressionRLE:
+		return 64
+	case compressionPIZ:
+		return 512
+	}
+	return 1032
+}
+
+// checkChunks checks that every chunk is inside the file and that, together, they are large enough to uncompress into
+// the whole data window
+func checkChunks(h header, buf []byte, offsets []uint64, bytesPerLine int) error {
+	in := &reader{buf: buf}
+	var packed uint64
+	for _, offset := range offsets {
+		if offset > uint64(len(buf)) {
+			return fmt.Errorf("exr: chunk offset %d outside of file", offset)
+		}
+		in.pos = int(offset)
+		if _, err := in.int32(); err != nil {
+			return err
+		}
+		size, err := in.int32()
+		if err != nil {
+			return err
+		}
+		if size < 0 || int(size) > len(buf)-in.pos {
+			return fmt.Errorf("exr: chunk at offset %d has %d bytes, which is past the end of the file", offset, size)
+		}
+		packed += uint64(size)
+	}
+	if needed := uint64(h.height()) * uint64(bytesPerLine); needed > packed*maxExpansion(h.compression) {
+		return fmt.Errorf("exr: image of %dx%d needs %d bytes of pixels, more than the %d bytes of chunks can hold", h.width(), h.height(), needed, packed)
+	}
+	return nil
+}
+
+func uncompress(h header, packed []byte, width, numLines, size int) ([]byte, error) {
+	switch h.compression {
+	case compressionRLE:
+		return unRLE(packed, size)
+	case compressionZIPS, compressionZIP:
+		return unZIP(packed, size)
+	case compressionPIZ:
+		return unPIZ(packed, h.channels, width, numLines, size)
+	}
+	return nil, fmt.Errorf("unsupported compression %s", compressionNames[h.compression])
+}
+
+// readPixels converts the uncompressed scanlines of a chunk, where every line holds the pixels of each channel after
+// each other, into the RGB data
+func readPixels(h header, raw []byte, width, first, numLines int, rgb [3]int, data []float32) {
+	pos := 0
+	for line := 0; line < numLines; line++ {
+		row := data[(first+line)*width*3:]
+		for i, c := range h.channels {
+			for dst := 0; dst < 3; dst++ {
+				if rgb[dst] != i {
+					continue
+				}
+				for x := 0; x < width; x++ {
+					row[x*3+dst] = pixel(c.pixelType, raw[pos+x*c.size():])
+				}
+			}
+			pos += width * c.size()
+		}
+	}
+}
+
+func pixel(pixelType int32, b []byte) float32 {
+	switch pixelType {
+	case pixelHalf:
+		return halfToFloat(binary.LittleEndian.Uint16(b))
+	case pixelFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	return float32(binary.LittleEndian.Uint32(b))
+}
+
+func readHeader(in *reader) (header, error) {
+	var h header
+	magic, err := in.bytes(4)
+	if err != nil || string(magic) != Magic {
+		return h, errors.New("exr: not an OpenEXR file")
+	}
+	version, err := in.uint32()
+	if err != nil {
+		return h, err
+	}
+	if version&0xff != 2 {
+		return h, fmt.Errorf("exr: unsupported version %d", version&0xff)
+	}
+	if version&(flagTiled|flagDeep|flagMultiPart) != 0 {
+		return h, errors.New("exr: only single part scanline images are supported")
+	}
+
+	var hasChannels, hasCompression, hasDataWindow bool
+	for {
+		name, err := in.cstring()
+		if err != nil {
+			return h, err
+		}
+		if name == "" {
+			break
+		}
+		attrType, err := in.cstring()
+		if err != nil {
+			return h, err
+		}
+		size, err := in.int32()
+		if err != nil {
+			return h, err
+		}
+		value, err := in.bytes(int(size))
+		if err != nil {
+			return h, fmt.Errorf("exr: reading attribute %q: %v", name, err)
+		}
+		attr := &reader{buf: value}
+
+		switch {
+		case name == "channels" && attrType == "chlist":
+			if h.channels, err = readChannels(attr); err != nil {
+				return h, err
+			}
+			hasChannels = true
+		case name == "compression" && attrType == "compression":
+			if len(value) != 1 {
+				return h, errors.New("exr: bad compression attribute")
+			}
+			h.compression = value[0]
+			hasCompression = true
+		case name == "dataWindow" && attrType == "box2i":
+			for _, v := range []*int32{&h.xMin, &h.yMin, &h.xMax, &h.yMax} {
+				if *v, err = attr.int32(); err != nil {
+					return h, errors.New("exr: bad dataWindow attribute")
+				}
+			}
+			hasDataWindow = true
+		}
+	}
+
+	if !hasChannels || !hasCompression || !hasDataWindow {
+		return h, errors.New("exr: header is missing channels, compression or dataWindow")
+	}
+	if h.xMax < h.xMin || h.yMax < h.yMin {
+		return h, fmt.Errorf("exr: bad dataWindow (%d,%d) (%d,%d)", h.xMin, h.yMin, h.xMax, h.yMax)
+	}
+	// each side is checked on its own first as the product of two spans can overflow an int64
+	width, height := span(h.xMin, h.xMax), span(h.yMin, h.yMax)
+	if width > 1<<28 || height > 1<<28 || width*height > 1<<28 {
+		return h, fmt.Errorf("exr: image of %dx%d is too large", width, height)
+	}
+	if _, ok := compressionNames[h.compression]; !ok {
+		return h, fmt.Errorf("exr: unknown compression %d", h.compression)
+	}
+	return h, nil
+}
+
+func readChannels(in *reader) ([]channel, error) {
+	var channels []channel
+	for {
+		name, err := in.cstring()
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			break
+		}
+		c := channel{name: name}
+		if c.pixelType, err = in.int32(); err != nil {
+			return nil, err
+		}
+		// pLinear and three reserved bytes
+		if _, err = in.bytes(4); err != nil {
+			return nil, err
+		}
+		if c.xSampling, err = in.int32(); err != nil {
+			return nil, err
+		}
+		if c.ySampling, err = in.int32(); err != nil {
+			return nil, err
+		}
+		if c.pixelType < pixelUint || c.pixelType > pixelFloat {
+			return nil, fmt.Errorf("exr: channel %q has unknown pixel type %d", name, c.pixelType)
+		}
+		if c.xSampling != 1 || c.ySampling != 1 {
+			return nil, fmt.Errorf("exr: channel %q is subsampled, which is not supported", name)
+		}
+		channels = append(channels, c)
+	}
+	if len(channels) == 0 {
+		return nil, errors.New("exr: image has no channels")
+	}
+	return channels, nil
+}
+
+// reader is a little endian cursor over a byte slice
+type reader struct {
+	buf []byte
+	pos int
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) uint16() (uint16, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (r *reader) uint32() (uint32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *reader) int32() (int32, error) {
+	v, err := r.uint32()
+	return int32(v), err
+}
+
+func (r *reader) uint64() (uint64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (r *reader) cstring() (string, error) {
+	end := bytes.IndexByte(r.buf[r.pos:], 0)
+	if end < 0 {
+		return "", io.ErrUnexpectedEOF
+	}
+	s := string(r.buf[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s, nil
+}
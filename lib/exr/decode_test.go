@@ -0,0 +1,197 @@
+package exr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testValue is the value of channel c (0 is red) of the pixel at x, y in the images that internal/exrgen writes
+func testValue(x, y, c int) float32 {
+	if y%5 == 0 {
+		return 1.25
+	}
+	return 1 + float32((x*3+y*5+c*7)%16)/32
+}
+
+func TestDecode(t *testing.T) {
+	const width, height = 16, 44
+	for _, compression := range []string{"none", "zip", "piz"} {
+		for _, pixelType := range []string{"half", "float"} {
+			name := compression + "_" + pixelType
+			t.Run(name, func(t *testing.T) {
+				f, err := os.Open(filepath.Join("testdata", name+".exr"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				w, h, data, err := Decode(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if w != width || h != height {
+					t.Fatalf("got size %dx%d, expected %dx%d", w, h, width, height)
+				}
+				for y := 0; y < height; y++ {
+					for x := 0; x < width; x++ {
+						for c := 0; c < 3; c++ {
+							// the values are exact in half floats
+							if got, expected := data[(y*width+x)*3+c], testValue(x, y, c); got != expected {
+								t.Fatalf("pixel %d,%d channel %d: got %f, expected %f", x, y, c, got, expected)
+							}
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeReference(t *testing.T) {
+	for _, pixelType := range []string{"half", "float"} {
+		golden, err := readGolden(filepath.Join("testdata", "openexr", "grad_"+pixelType+".golden.gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, compression := range []string{"zip", "piz"} {
+			name := pixelType + "_" + compression
+			t.Run(name, func(t *testing.T) {
+				f, err := os.Open(filepath.Join("testdata", "openexr", "grad_"+name+".exr"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				w, h, data, err := Decode(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if w*h != len(golden) {
+					t.Fatalf("got %d pixels, expected %d", w*h, len(golden))
+				}
+				for i, expected := range golden {
+					for c := 0; c < 3; c++ {
+						// the golden values are printed with nine decimals
+						if got := data[i*3+c]; math.Abs(float64(got-expected[c])) > 1e-6 {
+							t.Fatalf("pixel %d,%d channel %d: got %f, expected %f", i%w, i/w, c, got, expected[c])
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// readGolden reads the RGB values from an oiiotool --dumpdata listing of an RGBA image, in the order of the pixels
+func readGolden(name string) ([][3]float32, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var pixels [][3]float32
+	scanner := bufio.NewScanner(zr)
+	// the first line is the channel list
+	scanner.Scan()
+	for scanner.Scan() {
+		var x, y int
+		var p [3]float32
+		var a float32
+		if _, err := fmt.Sscanf(scanner.Text(), " Pixel (%d, %d): %f %f %f %f", &x, &y, &p[0], &p[1], &p[2], &a); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		pixels = append(pixels, p)
+	}
+	return pixels, scanner.Err()
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "piz_half.exr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cutting the file anywhere must give an error rather than a panic
+	for _, size := range []int{0, 3, 20, len(content) / 2, len(content) - 1} {
+		if _, _, _, err := Decode(bytes.NewReader(content[:size])); err == nil {
+			t.Errorf("%d bytes: expected an error", size)
+		}
+	}
+}
+
+func TestDecodeHugeDataWindow(t *testing.T) {
+	// found by fuzzing, yMax-yMin wrapped around in int32 and got past the size check
+	for _, window := range [][4]int32{
+		{0, -0x5ecfcfd0, 0, 0x30303030},
+		{-0x80000000, -0x80000000, 0x7fffffff, 0x7fffffff},
+	} {
+		if _, _, _, err := Decode(bytes.NewReader(withDataWindow(window))); err == nil {
+			t.Errorf("%v: expected an error", window)
+		}
+	}
+}
+
+func TestDecodeDataWindowLargerThanFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		window      [4]int32
+		compression uint8
+	}{
+		// the offset table alone doesn't fit in the file
+		{"tall", [4]int32{0, 0, 15, 1<<28/16 - 1}, compressionNone},
+		{"tall zip", [4]int32{0, 0, 15, 1<<28/16 - 1}, compressionZIP},
+		// a single chunk of a few bytes that claims a line of 32 MiB
+		{"wide", [4]int32{0, 0, 1<<24 - 1, 0}, compressionNone},
+		{"wide zip", [4]int32{0, 0, 1<<24 - 1, 0}, compressionZIP},
+		{"wide piz", [4]int32{0, 0, 1<<24 - 1, 0}, compressionPIZ},
+	}
+	for _, test := range tests {
+		b := exrHeader(test.window, test.compression)
+		// one chunk right after the offset table, with line 0 and four bytes of data
+		for _, v := range []interface{}{uint64(b.Len() + 8), int32(0), int32(4), [4]byte{}} {
+			binary.Write(b, binary.LittleEndian, v)
+		}
+		_, _, _, err := Decode(b)
+		if err == nil || !strings.Contains(err.Error(), "larger than the file") && !strings.Contains(err.Error(), "chunks can hold") {
+			t.Errorf("%s: expected a size error, got %v", test.name, err)
+		}
+	}
+}
+
+// withDataWindow returns an image header with a single R channel and the window xMin, yMin, xMax, yMax, followed by
+// bytes that read as an offset table when the header is accepted
+func withDataWindow(window [4]int32) []byte {
+	b := exrHeader(window, compressionNone)
+	b.Write(bytes.Repeat([]byte("0"), 64))
+	return b.Bytes()
+}
+
+// exrHeader returns an image header with a single half R channel, the compression and the window xMin, yMin, xMax, yMax
+func exrHeader(window [4]int32, compression uint8) *bytes.Buffer {
+	b := &bytes.Buffer{}
+	b.WriteString(Magic)
+	binary.Write(b, binary.LittleEndian, uint32(2))
+	attribute := func(name, attrType string, value ...interface{}) {
+		var v bytes.Buffer
+		for _, x := range value {
+			binary.Write(&v, binary.LittleEndian, x)
+		}
+		b.WriteString(name + "\x00" + attrType + "\x00")
+		binary.Write(b, binary.LittleEndian, int32(v.Len()))
+		b.Write(v.Bytes())
+	}
+	attribute("channels", "chlist", []byte("R\x00"), int32(pixelHalf), [4]byte{}, int32(1), int32(1), byte(0))
+	attribute("compression", "compression", compression)
+	attribute("dataWindow", "box2i", window)
+	b.WriteByte(0)
+	return b
+}
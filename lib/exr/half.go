@@ -0,0 +1,30 @@
+package exr
+
+import "math"
+
+// halfToFloat converts a IEEE 754 half precision float into a float32
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exponent := uint32(h>>10) & 0x1f
+	mantissa := uint32(h) & 0x3ff
+
+	switch exponent {
+	case 0:
+		if mantissa == 0 {
+			return math.Float32frombits(sign)
+		}
+		// denormalized, normalise it for the float
+		exponent = 127 - 15 + 1
+		for mantissa&0x400 == 0 {
+			mantissa <<= 1
+			exponent--
+		}
+		mantissa &= 0x3ff
+	case 0x1f:
+		// infinity or NaN
+		return math.Float32frombits(sign | 0xff<<23 | mantissa<<13)
+	default:
+		exponent += 127 - 15
+	}
+	return math.Float32frombits(sign | exponent<<23 | mantissa<<13)
+}
@@ -0,0 +1,446 @@
+package exr
+
+// PIZ compression is a wavelet transform followed by Huffman coding of the 16 bit words, this is a port of ImfPizCompressor,
+// ImfWav and ImfHuf from the OpenEXR reference implementation
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	bitmapSize  = 1 << 13
+	ushortRange = 1 << 16
+)
+
+// unPIZ decodes a chunk of numLines scanlines
+func unPIZ(packed []byte, channels []channel, width, numLines, size int) ([]byte, error) {
+	in := &reader{buf: packed}
+
+	minNonZero, err := in.uint16()
+	if err != nil {
+		return nil, err
+	}
+	maxNonZero, err := in.uint16()
+	if err != nil {
+		return nil, err
+	}
+	if maxNonZero >= bitmapSize {
+		return nil, errors.New("corrupt PIZ bitmap")
+	}
+	var bitmap [bitmapSize]byte
+	if minNonZero <= maxNonZero {
+		b, err := in.bytes(int(maxNonZero-minNonZero) + 1)
+		if err != nil {
+			return nil, err
+		}
+		copy(bitmap[minNonZero:], b)
+	}
+	lut, maxValue := reverseLutFromBitmap(&bitmap)
+
+	length, err := in.int32()
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := in.bytes(int(length))
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]uint16, size/2)
+	if err := hufUncompress(compressed, words); err != nil {
+		return nil, err
+	}
+
+	// every channel is stored as one block with all its lines and is wavelet transformed on its own
+	offset := 0
+	for _, c := range channels {
+		n := c.size() / 2
+		for j := 0; j < n; j++ {
+			wav2Decode(words[offset+j:], width, n, numLines, width*n, maxValue)
+		}
+		offset += width * numLines * n
+	}
+
+	for i, w := range words {
+		words[i] = lut[w]
+	}
+
+	// interleave the channel blocks back into scanlines
+	out := make([]byte, size)
+	pos := 0
+	starts := make([]int, len(channels))
+	offset = 0
+	for i, c := range channels {
+		starts[i] = offset
+		offset += width * numLines * c.size() / 2
+	}
+	for y := 0; y < numLines; y++ {
+		for i, c := range channels {
+			n := width * c.size() / 2
+			for _, w := range words[starts[i] : starts[i]+n] {
+				binary.LittleEndian.PutUint16(out[pos:], w)
+				pos += 2
+			}
+			starts[i] += n
+		}
+	}
+	return out, nil
+}
+
+// reverseLutFromBitmap returns a table that maps the dense values back to the original 16 bit values that was marked
+// as used in the bitmap, and the largest dense value
+func reverseLutFromBitmap(bitmap *[bitmapSize]byte) ([]uint16, uint16) {
+	lut := make([]uint16, ushortRange)
+	k := 0
+	for i := 0; i < ushortRange; i++ {
+		if i == 0 || bitmap[i>>3]&(1<<uint(i&7)) != 0 {
+			lut[k] = uint16(i)
+			k++
+		}
+	}
+	return lut, uint16(k - 1)
+}
+
+// wav2Decode does an in place inverse 2D haar wavelet transform of nx * ny values, which are ox apart horizontally and
+// oy apart vertically
+func wav2Decode(in []uint16, nx, ox, ny, oy int, mx uint16) {
+	w14 := mx < (1 << 14)
+	dec := wdec16
+	if w14 {
+		dec = wdec14
+	}
+
+	n := ny
+	if nx < n {
+		n = nx
+	}
+	p := 1
+	for p <= n {
+		p <<= 1
+	}
+	p >>= 1
+	p2 := p
+	p >>= 1
+
+	for p >= 1 {
+		py := 0
+		ey := oy * (ny - p2)
+		oy1 := oy * p
+		oy2 := oy * p2
+		ox1 := ox * p
+		ox2 := ox * p2
+
+		for ; py <= ey; py += oy2 {
+			px := py
+			ex := py + ox*(nx-p2)
+			for ; px <= ex; px += ox2 {
+				p01 := px + ox1
+				p10 := px + oy1
+				p11 := p10 + ox1
+
+				i00, i10 := dec(in[px], in[p10])
+				i01, i11 := dec(in[p01], in[p11])
+				in[px], in[p01] = dec(i00, i01)
+				in[p10], in[p11] = dec(i10, i11)
+			}
+
+			// odd column
+			if nx&p != 0 {
+				p10 := px + oy1
+				in[px], in[p10] = dec(in[px], in[p10])
+			}
+		}
+
+		// odd row
+		if ny&p != 0 {
+			px := py
+			ex := py + ox*(nx-p2)
+			for ; px <= ex; px += ox2 {
+				p01 := px + ox1
+				in[px], in[p01] = dec(in[px], in[p01])
+			}
+		}
+
+		p2 = p
+		p >>= 1
+	}
+}
+
+// wdec14 reverses a 14 bit haar step
+func wdec14(l, h uint16) (uint16, uint16) {
+	hi := int(int16(h))
+	ai := int(int16(l)) + (hi & 1) + (hi >> 1)
+	return uint16(int16(ai)), uint16(int16(ai - hi))
+}
+
+// wdec16 reverses a 16 bit haar step, which uses modulo arithmetic to not overflow
+func wdec16(l, h uint16) (uint16, uint16) {
+	const aOffset = 1 << 15
+	m, d := int(l), int(h)
+	b := (m - (d >> 1)) & 0xffff
+	a := (d + b - aOffset) & 0xffff
+	return uint16(a), uint16(b)
+}
+
+const (
+	hufEncBits = 16
+	hufDecBits = 14
+
+	hufEncSize = (1 << hufEncBits) + 1
+	hufDecSize = 1 << hufDecBits
+	hufDecMask = hufDecSize - 1
+
+	shortZeroCodeRun = 59
+	longZeroCodeRun  = 63
+	shortestLongRun  = 2 + longZeroCodeRun - shortZeroCodeRun
+)
+
+// hufDec is an entry in the decoding table, short codes are looked up directly and long codes are searched for in lits
+type hufDec struct {
+	len  int
+	lit  int
+	lits []int
+}
+
+// hufUncompress decodes Huffman coded data into out, which must be exactly the decoded length
+func hufUncompress(compressed []byte, out []uint16) error {
+	if len(compressed) == 0 {
+		if len(out) != 0 {
+			return errors.New("missing Huffman data")
+		}
+		return nil
+	}
+	in := &reader{buf: compressed}
+	im, _ := in.uint32()
+	iM, _ := in.uint32()
+	// table length and a reserved word
+	_, _ = in.uint32()
+	nBits, _ := in.uint32()
+	if _, err := in.bytes(4); err != nil {
+		return errors.New("short Huffman header")
+	}
+	if im >= hufEncSize || iM >= hufEncSize || im > iM {
+		return errors.New("corrupt Huffman header")
+	}
+
+	codes := make([]uint64, hufEncSize)
+	if err := hufUnpackEncTable(in, int(im), int(iM), codes); err != nil {
+		return err
+	}
+	if uint64(nBits) > 8*uint64(len(in.buf)-in.pos) {
+		return errors.New("corrupt Huffman data length")
+	}
+
+	table := make([]hufDec, hufDecSize)
+	if err := hufBuildDecTable(codes, int(im), int(iM), table); err != nil {
+		return err
+	}
+	return hufDecode(codes, table, in.buf[in.pos:], int(nBits), int(iM), out)
+}
+
+func hufLength(code uint64) int {
+	return int(code & 63)
+}
+
+func hufCode(code uint64) uint64 {
+	return code >> 6
+}
+
+// bitReader reads bits most significant first
+type bitReader struct {
+	buf []byte
+	pos int
+	c   uint64
+	lc  int
+}
+
+func (b *bitReader) getChar() bool {
+	if b.pos >= len(b.buf) {
+		return false
+	}
+	b.c = b.c<<8 | uint64(b.buf[b.pos])
+	b.pos++
+	b.lc += 8
+	return true
+}
+
+func (b *bitReader) getBits(n int) (uint64, bool) {
+	for b.lc < n {
+		if !b.getChar() {
+			return 0, false
+		}
+	}
+	b.lc -= n
+	return (b.c >> uint(b.lc)) & (1<<uint(n) - 1), true
+}
+
+// hufUnpackEncTable reads the packed code lengths for the values im to iM and builds the canonical codes from them
+func hufUnpackEncTable(in *reader, im, iM int, codes []uint64) error {
+	br := &bitReader{buf: in.buf, pos: in.pos}
+	for ; im <= iM; im++ {
+		l, ok := br.getBits(6)
+		if !ok {
+			return errors.New("corrupt Huffman table")
+		}
+		codes[im] = l
+
+		zerun := 0
+		if l == longZeroCodeRun {
+			run, ok := br.getBits(8)
+			if !ok {
+				return errors.New("corrupt Huffman table")
+			}
+			zerun = int(run) + shortestLongRun
+		} else if l >= shortZeroCodeRun {
+			zerun = int(l) - shortZeroCodeRun + 2
+		}
+		if zerun > 0 {
+			if im+zerun > iM+1 {
+				return errors.New("corrupt Huffman table")
+			}
+			for ; zerun > 0; zerun-- {
+				codes[im] = 0
+				im++
+			}
+			im--
+		}
+	}
+	in.pos = br.pos
+	hufCanonicalCodeTable(codes)
+	return nil
+}
+
+// hufCanonicalCodeTable turns code lengths into canonical codes, stored as code << 6 | length
+func hufCanonicalCodeTable(codes []uint64) {
+	var n [59]uint64
+	for _, l := range codes {
+		n[l]++
+	}
+	var c uint64
+	for i := 58; i > 0; i-- {
+		nc := (c + n[i]) >> 1
+		n[i] = c
+		c = nc
+	}
+	for i, l := range codes {
+		if l > 0 {
+			codes[i] = l | n[l]<<6
+			n[l]++
+		}
+	}
+}
+
+func hufBuildDecTable(codes []uint64, im, iM int, table []hufDec) error {
+	for ; im <= iM; im++ {
+		c := hufCode(codes[im])
+		l := hufLength(codes[im])
+		if c>>uint(l) != 0 {
+			return errors.New("corrupt Huffman table entry")
+		}
+		if l > hufDecBits {
+			pl := &table[c>>uint(l-hufDecBits)]
+			if pl.len != 0 {
+				return errors.New("corrupt Huffman table entry")
+			}
+			pl.lit++
+			pl.lits = append(pl.lits, im)
+		} else if l > 0 {
+			start := int(c << uint(hufDecBits-l))
+			for i := 0; i < 1<<uint(hufDecBits-l); i++ {
+				pl := &table[start+i]
+				if pl.len != 0 || pl.lits != nil {
+					return errors.New("corrupt Huffman table entry")
+				}
+				pl.len = l
+				pl.lit = im
+			}
+		}
+	}
+	return nil
+}
+
+func hufDecode(codes []uint64, table []hufDec, data []byte, nBits, rlc int, out []uint16) error {
+	br := &bitReader{buf: data[:(nBits+7)/8]}
+	o := 0
+
+	emit := func(po int) error {
+		if po != rlc {
+			if o >= len(out) {
+				return errors.New("Huffman data overrun")
+			}
+			out[o] = uint16(po)
+			o++
+			return nil
+		}
+		// a run of the previous value
+		if br.lc < 8 && !br.getChar() {
+			return errors.New("Huffman data underrun")
+		}
+		br.lc -= 8
+		cs := int((br.c >> uint(br.lc)) & 0xff)
+		if o+cs > len(out) || o < 1 {
+			return errors.New("corrupt Huffman run")
+		}
+		s := out[o-1]
+		for ; cs > 0; cs-- {
+			out[o] = s
+			o++
+		}
+		return nil
+	}
+
+	for br.getChar() {
+		for br.lc >= hufDecBits {
+			pl := table[(br.c>>uint(br.lc-hufDecBits))&hufDecMask]
+			if pl.len != 0 {
+				br.lc -= pl.len
+				if err := emit(pl.lit); err != nil {
+					return err
+				}
+				continue
+			}
+			if pl.lits == nil {
+				return errors.New("corrupt Huffman code")
+			}
+			found := false
+			for _, lit := range pl.lits {
+				l := hufLength(codes[lit])
+				for br.lc < l && br.getChar() {
+				}
+				if br.lc >= l && hufCode(codes[lit]) == (br.c>>uint(br.lc-l))&(1<<uint(l)-1) {
+					br.lc -= l
+					if err := emit(lit); err != nil {
+						return err
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				return errors.New("corrupt Huffman code")
+			}
+		}
+	}
+
+	// the remaining bits are shorter than hufDecBits
+	i := (8 - nBits) & 7
+	br.c >>= uint(i)
+	br.lc -= i
+	for br.lc > 0 {
+		pl := table[(br.c<<uint(hufDecBits-br.lc))&hufDecMask]
+		if pl.len == 0 || pl.len > br.lc {
+			return errors.New("corrupt Huffman code")
+		}
+		br.lc -= pl.len
+		if err := emit(pl.lit); err != nil {
+			return err
+		}
+	}
+
+	if o != len(out) {
+		return fmt.Errorf("Huffman data decoded to %d values, expected %d", o, len(out))
+	}
+	return nil
+}
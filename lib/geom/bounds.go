@@ -0,0 +1,32 @@
+package geom
+
+import "math"
+
+// BoundingVolume is an axis aligned box and a sphere around the vertices of a mesh, in the space of the mesh
+type BoundingVolume struct {
+	Min, Max [3]float32
+	// Center is the middle of the box, Radius the distance from it to the vertex that is furthest away
+	Center [3]float32
+	Radius float32
+}
+
+// BoundingVolumeOf returns the box and sphere around the vertices, the volume of no vertices is a point at the origin
+func BoundingVolumeOf(vertices []Vertex) BoundingVolume {
+	var b BoundingVolume
+	b.Min, b.Max = Bounds(vertices)
+	b.Center = scale(add(b.Min, b.Max), 0.5)
+	var maxSq float32
+	for _, v := range vertices {
+		d := sub(v.Position, b.Center)
+		if sq := dot(d, d); sq > maxSq {
+			maxSq = sq
+		}
+	}
+	b.Radius = float32(math.Sqrt(float64(maxSq)))
+	return b
+}
+
+// Extents returns the half size of the box along each axis
+func (b BoundingVolume) Extents() [3]float32 {
+	return scale(sub(b.Max, b.Min), 0.5)
+}
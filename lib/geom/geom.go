@@ -0,0 +1,136 @@
+package geom
+
+import (
+	"fmt"
+)
+
+// Vertex is the interleaved vertex layout that is uploaded into the vertex buffers
+type Vertex struct {
+	Position  [3]float32
+	Normal    [3]float32
+	TexCoords [2]float32
+	// Tangent is the direction of increasing U, the w component is the handedness of the tangent frame so that the
+	// bitangent is cross(Normal, Tangent.xyz) * w
+	Tangent [4]float32
+	// Color and TexCoords2 are optional, they are only uploaded for meshes that have the attribute
+	Color      [4]float32
+	TexCoords2 [2]float32
+}
+
+// Attributes are the optional vertex attributes that a mesh has
+type Attributes uint32
+
+const (
+	// Colors is a linear RGBA colour per vertex, that multiplies the albedo
+	Colors Attributes = 1 << iota
+	// TexCoords2 is a second UV set, for light maps and detail textures
+	TexCoords2
+)
+
+// AttributesOf returns the optional attributes that are in use by the vertices, colours that aren't all white and
+// second texture coordinates that aren't all zero
+func AttributesOf(vertices []Vertex) Attributes {
+	var a Attributes
+	for _, v := range vertices {
+		if v.Color != [4]float32{1, 1, 1, 1} {
+			a |= Colors
+		}
+		if v.TexCoords2 != [2]float32{} {
+			a |= TexCoords2
+		}
+	}
+	return a
+}
+
+// SetColors copies RGBA colours, four floats per vertex, into the vertices
+func SetColors(vertices []Vertex, colors []float32) error {
+	if len(colors) != len(vertices)*4 {
+		return fmt.Errorf("got %d colour values for %d vertices, want four per vertex", len(colors), len(vertices))
+	}
+	for i := range vertices {
+		copy(vertices[i].Color[:], colors[i*4:i*4+4])
+	}
+	return nil
+}
+
+// SetTexCoords2 copies the second UV set, two floats per vertex, into the vertices
+func SetTexCoords2(vertices []Vertex, texCoords []float32) error {
+	if len(texCoords) != len(vertices)*2 {
+		return fmt.Errorf("got %d texture coordinates for %d vertices, want two per vertex", len(texCoords), len(vertices))
+	}
+	for i := range vertices {
+		copy(vertices[i].TexCoords2[:], texCoords[i*2:i*2+2])
+	}
+	return nil
+}
+
+// Triangles converts a triangle list of [3]Pos, [3]Normals, [2]TexCoords into vertices and calculates the tangents
+func Triangles(meshdata []float32) ([]Vertex, error) {
+	if len(meshdata)%(stride*3) != 0 {
+		return nil, fmt.Errorf("the mesh data is not a multiple of 3*8, want triangles of [3]Pos, [3]Normals, [2]TexCoords")
+	}
+	vertices := toVertices(meshdata)
+	Tangents(vertices)
+	return vertices, nil
+}
+
+// Vertices converts line or point data of [3]Pos, [3]Normals, [2]TexCoords into vertices, the tangents are left at
+// zero since there are no surfaces to map
+func Vertices(meshdata []float32) ([]Vertex, error) {
+	if len(meshdata)%stride != 0 {
+		return nil, fmt.Errorf("the mesh data is not a multiple of 8, want vertices of [3]Pos, [3]Normals, [2]TexCoords")
+	}
+	return toVertices(meshdata), nil
+}
+
+// stride is the number of floats per vertex in the mesh data
+const stride = 8
+
+func toVertices(meshdata []float32) []Vertex {
+	var vertices []Vertex
+	for i := 0; i < len(meshdata); i += stride {
+		vertex := Vertex{Color: [4]float32{1, 1, 1, 1}}
+		copy(vertex.Position[:], meshdata[i:i+3])
+		copy(vertex.Normal[:], meshdata[i+3:i+6])
+		copy(vertex.TexCoords[:], meshdata[i+6:i+8])
+		vertices = append(vertices, vertex)
+	}
+	return vertices
+}
+
+// Index removes duplicated vertices and returns the unique vertices together with the indices that will rebuild
+// the original triangle list
+func Index(vertices []Vertex) ([]Vertex, []uint32) {
+	seen := make(map[Vertex]uint32, len(vertices))
+	unique := make([]Vertex, 0, len(vertices))
+	indices := make([]uint32, len(vertices))
+	for i, v := range vertices {
+		idx, found := seen[v]
+		if !found {
+			idx = uint32(len(unique))
+			seen[v] = idx
+			unique = append(unique, v)
+		}
+		indices[i] = idx
+	}
+	return unique, indices
+}
+
+// Bounds returns the minimum and maximum corners of the axis aligned box around the vertices
+func Bounds(vertices []Vertex) (min, max [3]float32) {
+	if len(vertices) == 0 {
+		return min, max
+	}
+	min, max = vertices[0].Position, vertices[0].Position
+	for _, v := range vertices[1:] {
+		for i := 0; i < 3; i++ {
+			if v.Position[i] < min[i] {
+				min[i] = v.Position[i]
+			}
+			if v.Position[i] > max[i] {
+				max[i] = v.Position[i]
+			}
+		}
+	}
+	return min, max
+}
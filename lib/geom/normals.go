@@ -0,0 +1,45 @@
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// NewellNormal returns the normal of a polygon with Newell's method, its length is twice the area of the polygon
+func NewellNormal(points [][3]float64) [3]float64 {
+	var n [3]float64
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		n[0] += (a[1] - b[1]) * (a[2] + b[2])
+		n[1] += (a[2] - b[2]) * (a[0] + b[0])
+		n[2] += (a[0] - b[0]) * (a[1] + b[1])
+	}
+	return n
+}
+
+// FlatNormals sets the normals of a triangle list of [3]Pos, [3]Normals, [2]TexCoords to the normal of each triangle.
+// Degenerate triangles get a zero normal, like OBJ faces without normals do.
+func FlatNormals(meshdata []float32) error {
+	if len(meshdata)%(stride*3) != 0 {
+		return fmt.Errorf("the mesh data is not a multiple of 3*8, want triangles of [3]Pos, [3]Normals, [2]TexCoords")
+	}
+	for i := 0; i < len(meshdata); i += stride * 3 {
+		var points [3][3]float64
+		for c := range points {
+			for k := range points[c] {
+				points[c][k] = float64(meshdata[i+c*stride+k])
+			}
+		}
+		n := NewellNormal(points[:])
+		l := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+		if l == 0 || math.IsNaN(l) || math.IsInf(l, 0) {
+			l, n = 1, [3]float64{}
+		}
+		for c := 0; c < 3; c++ {
+			for k := 0; k < 3; k++ {
+				meshdata[i+c*stride+3+k] = float32(n[k] / l)
+			}
+		}
+	}
+	return nil
+}
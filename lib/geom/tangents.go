@@ -0,0 +1,163 @@
+package geom
+
+import "math"
+
+// degenerateUV is the smallest UV area, relative to the triangle, that gives a usable tangent
+const degenerateUV = 1e-12
+
+// cornerKey identifies the corners that share a tangent frame. Like MikkTSpace, corners are only shared when the
+// position, normal and texture coordinates are the same and the UV mapping has the same handedness, so that mirrored
+// UVs get their own frame.
+type cornerKey struct {
+	position  [3]float32
+	normal    [3]float32
+	texCoords [2]float32
+	flipped   bool
+}
+
+// Tangents calculates smooth tangents for a triangle list. The tangent of every triangle is projected onto the plane
+// of each corner normal and summed, weighted by the angle of the corner, over all the corners sharing a vertex.
+// Triangles without a usable UV mapping don't contribute, and vertices without any tangent get one that is
+// perpendicular to the normal.
+func Tangents(vertices []Vertex) {
+	type frame struct {
+		tangent [3]float32
+		flipped bool
+		valid   bool
+	}
+	frames := make([]frame, len(vertices))
+	sums := make(map[cornerKey][3]float32)
+
+	for i := 0; i+2 < len(vertices); i += 3 {
+		tri := vertices[i : i+3]
+		tangent, bitangent, ok := faceTangent(tri[0], tri[1], tri[2])
+		if !ok {
+			continue
+		}
+		for c := range tri {
+			n := tri[c].Normal
+			t := normalise(sub(tangent, scale(n, dot(n, tangent))))
+			if !finite(t) {
+				continue
+			}
+			// the handedness is left-handed when the bitangent points against cross(n, t)
+			flipped := dot(cross(n, t), bitangent) < 0
+			key := cornerKey{tri[c].Position, n, tri[c].TexCoords, flipped}
+			sums[key] = add(sums[key], scale(t, cornerAngle(tri, c)))
+			frames[i+c] = frame{flipped: flipped, valid: true}
+		}
+	}
+
+	for i := range vertices {
+		v := &vertices[i]
+		f := frames[i]
+		if f.valid {
+			f.tangent = normalise(sums[cornerKey{v.Position, v.Normal, v.TexCoords, f.flipped}])
+		} else {
+			// the triangle had no UV mapping, try to share the frame of a neighbour with the same vertex
+			for _, flipped := range []bool{false, true} {
+				if sum, ok := sums[cornerKey{v.Position, v.Normal, v.TexCoords, flipped}]; ok {
+					f.tangent, f.flipped = normalise(sum), flipped
+					break
+				}
+			}
+		}
+		if !finite(f.tangent) || f.tangent == [3]float32{} {
+			f.tangent, f.flipped = perpendicular(v.Normal), false
+		}
+		w := float32(1)
+		if f.flipped {
+			w = -1
+		}
+		v.Tangent = [4]float32{f.tangent[0], f.tangent[1], f.tangent[2], w}
+	}
+}
+
+// faceTangent returns the unnormalised directions of increasing U and V over the triangle, ok is false when the UVs
+// are degenerate
+func faceTangent(v0, v1, v2 Vertex) (tangent, bitangent [3]float32, ok bool) {
+	edge1 := sub(v1.Position, v0.Position)
+	edge2 := sub(v2.Position, v0.Position)
+
+	deltaU1 := v1.TexCoords[0] - v0.TexCoords[0]
+	deltaV1 := v1.TexCoords[1] - v0.TexCoords[1]
+	deltaU2 := v2.TexCoords[0] - v0.TexCoords[0]
+	deltaV2 := v2.TexCoords[1] - v0.TexCoords[1]
+
+	det := deltaU1*deltaV2 - deltaU2*deltaV1
+	area := length(cross(edge1, edge2))
+	if math.Abs(float64(det)) <= degenerateUV*float64(area) || det == 0 {
+		return tangent, bitangent, false
+	}
+	f := 1 / det
+	tangent = scale(sub(scale(edge1, deltaV2), scale(edge2, deltaV1)), f)
+	bitangent = scale(sub(scale(edge2, deltaU1), scale(edge1, deltaU2)), f)
+	return tangent, bitangent, finite(tangent) && finite(bitangent)
+}
+
+// cornerAngle returns the angle of the triangle at corner c
+func cornerAngle(tri []Vertex, c int) float32 {
+	a := normalise(sub(tri[(c+1)%3].Position, tri[c].Position))
+	b := normalise(sub(tri[(c+2)%3].Position, tri[c].Position))
+	if !finite(a) || !finite(b) {
+		return 0
+	}
+	d := math.Max(-1, math.Min(1, float64(dot(a, b))))
+	return float32(math.Acos(d))
+}
+
+// perpendicular returns a unit vector perpendicular to n, built from the axis that is least aligned with it
+func perpendicular(n [3]float32) [3]float32 {
+	axis := [3]float32{1, 0, 0}
+	if math.Abs(float64(n[0])) > 0.9 {
+		axis = [3]float32{0, 1, 0}
+	}
+	t := normalise(sub(axis, scale(n, dot(n, axis))))
+	if !finite(t) {
+		return axis
+	}
+	return t
+}
+
+func add(a, b [3]float32) [3]float32 {
+	return [3]float32{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func sub(a, b [3]float32) [3]float32 {
+	return [3]float32{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func scale(a [3]float32, s float32) [3]float32 {
+	return [3]float32{a[0] * s, a[1] * s, a[2] * s}
+}
+
+func dot(a, b [3]float32) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func cross(a, b [3]float32) [3]float32 {
+	return [3]float32{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+func length(a [3]float32) float32 {
+	return float32(math.Sqrt(float64(dot(a, a))))
+}
+
+// normalise returns the unit vector, zero length vectors turn into NaN and are caught with finite
+func normalise(vec [3]float32) [3]float32 {
+	l := 1.0 / length(vec)
+	return [3]float32{vec[0] * l, vec[1] * l, vec[2] * l}
+}
+
+func finite(a [3]float32) bool {
+	for _, v := range a {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return false
+		}
+	}
+	return true
+}
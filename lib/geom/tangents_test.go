@@ -0,0 +1,128 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+var up = [3]float32{0, 0, 1}
+
+// vertex returns a vertex in the XY plane, facing up
+func vertex(x, y, u, v float32) Vertex {
+	return Vertex{Position: [3]float32{x, y, 0}, Normal: up, TexCoords: [2]float32{u, v}}
+}
+
+func TestTangents(t *testing.T) {
+	// the direction half way between the X axis and the diagonal, 22.5 degrees
+	halfway := [4]float32{float32(math.Cos(math.Pi / 8)), float32(math.Sin(math.Pi / 8)), 0, 1}
+	diagonal := [4]float32{float32(math.Sqrt2 / 2), float32(math.Sqrt2 / 2), 0, 1}
+
+	tests := []struct {
+		name     string
+		vertices []Vertex
+		expected [][4]float32
+	}{
+		{
+			name: "quad",
+			vertices: []Vertex{
+				vertex(0, 0, 0, 0), vertex(1, 0, 1, 0), vertex(1, 1, 1, 1),
+				vertex(0, 0, 0, 0), vertex(1, 1, 1, 1), vertex(0, 1, 0, 1),
+			},
+			expected: [][4]float32{{1, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}},
+		},
+		{
+			// U runs against X, so the frame is left-handed
+			name: "mirrored quad",
+			vertices: []Vertex{
+				vertex(0, 0, 1, 0), vertex(1, 0, 0, 0), vertex(1, 1, 0, 1),
+				vertex(0, 0, 1, 0), vertex(1, 1, 0, 1), vertex(0, 1, 1, 1),
+			},
+			expected: [][4]float32{{-1, 0, 0, -1}, {-1, 0, 0, -1}, {-1, 0, 0, -1}, {-1, 0, 0, -1}, {-1, 0, 0, -1}, {-1, 0, 0, -1}},
+		},
+		{
+			// the right half mirrors the left one around x = 0, the corners on the seam have the same position, normal
+			// and UV but aren't averaged as their handedness differs
+			name: "mirror seam",
+			vertices: []Vertex{
+				vertex(-1, 0, 0, 0), vertex(0, 0, 1, 0), vertex(0, 1, 1, 1),
+				vertex(0, 0, 1, 0), vertex(1, 0, 0, 0), vertex(0, 1, 1, 1),
+			},
+			expected: [][4]float32{{1, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}, {-1, 0, 0, -1}, {-1, 0, 0, -1}, {-1, 0, 0, -1}},
+		},
+		{
+			// no UV mapping at all, the tangent is perpendicular to the normal
+			name: "zero UV area",
+			vertices: []Vertex{
+				vertex(0, 0, 0.5, 0.5), vertex(1, 0, 0.5, 0.5), vertex(0, 1, 0.5, 0.5),
+			},
+			expected: [][4]float32{{1, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}},
+		},
+		{
+			name: "zero UV area facing X",
+			vertices: []Vertex{
+				{Position: [3]float32{0, 0, 0}, Normal: [3]float32{1, 0, 0}},
+				{Position: [3]float32{0, 1, 0}, Normal: [3]float32{1, 0, 0}},
+				{Position: [3]float32{0, 0, 1}, Normal: [3]float32{1, 0, 0}},
+			},
+			expected: [][4]float32{{0, 1, 0, 1}, {0, 1, 0, 1}, {0, 1, 0, 1}},
+		},
+		{
+			// the UVs are on a line, so the triangle has no usable tangent
+			name: "collinear UVs",
+			vertices: []Vertex{
+				vertex(0, 0, 0, 0), vertex(1, 0, 1, 1), vertex(0, 1, 2, 2),
+			},
+			expected: [][4]float32{{1, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}},
+		},
+		{
+			// the first triangle maps U along Y, the second has no UV mapping and its corner at the origin takes the
+			// frame of the first triangle's corner there
+			name: "zero UV area next to a mapped triangle",
+			vertices: []Vertex{
+				vertex(0, 0, 0, 0), vertex(1, 0, 0, 1), vertex(0, 1, 1, 0),
+				vertex(0, 0, 0, 0), vertex(-1, 0, 0, 0), vertex(0, -1, 0, 0),
+			},
+			expected: [][4]float32{{0, 1, 0, -1}, {0, 1, 0, -1}, {0, 1, 0, -1}, {0, 1, 0, -1}, {1, 0, 0, 1}, {1, 0, 0, 1}},
+		},
+		{
+			// the triangles share the vertices at (0, 0) and (0, 1), the first has its tangent along X and the second
+			// along the diagonal, with the same corner angles on the shared vertices
+			name: "shared vertices",
+			vertices: []Vertex{
+				vertex(0, 0, 0, 0), vertex(1, 0, 1, 0), vertex(0, 1, 0, 1),
+				vertex(0, 0, 0, 0), vertex(0, 1, 0, 1), vertex(-1, 0, -1, 1),
+			},
+			expected: [][4]float32{halfway, {1, 0, 0, 1}, halfway, halfway, halfway, diagonal},
+		},
+		{
+			// the same triangles with a hard edge, corners with different normals aren't shared. The second triangle
+			// is tilted around its diagonal tangent, so that the tangent stays the same.
+			name: "hard edge",
+			vertices: func() []Vertex {
+				vertices := []Vertex{
+					vertex(0, 0, 0, 0), vertex(1, 0, 1, 0), vertex(0, 1, 0, 1),
+					vertex(0, 0, 0, 0), vertex(0, 1, 0, 1), vertex(-1, 0, -1, 1),
+				}
+				for i := 3; i < 6; i++ {
+					vertices[i].Normal = [3]float32{-0.5, 0.5, float32(math.Sqrt2 / 2)}
+				}
+				return vertices
+			}(),
+			expected: [][4]float32{{1, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 0, 1}, diagonal, diagonal, diagonal},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Tangents(test.vertices)
+			for i, v := range test.vertices {
+				for c := range v.Tangent {
+					if math.Abs(float64(v.Tangent[c]-test.expected[i][c])) > 1e-5 {
+						t.Errorf("vertex %d: got tangent %v, expected %v", i, v.Tangent, test.expected[i])
+						break
+					}
+				}
+			}
+		})
+	}
+}
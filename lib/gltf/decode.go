@@ -0,0 +1,434 @@
+package gltf
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	glbMagic     = 0x46546C67 // "glTF"
+	glbChunkJSON = 0x4E4F534A // "JSON"
+	glbChunkBIN  = 0x004E4942 // "BIN\0"
+)
+
+// Open loads a .gltf or .glb file together with all the buffers it references
+func Open(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := Decode(f, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return doc, nil
+}
+
+// OpenFS is Open for a file in fsys, the buffers and images it references are read from fsys too
+func OpenFS(fsys fs.FS, name string) (*Document, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := decode(f, &Document{dir: path.Dir(name), fsys: fsys})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return doc, nil
+}
+
+// Decode reads a glTF JSON or binary glTF (.glb) asset. External buffers and images are resolved relative to dir.
+func Decode(r io.Reader, dir string) (*Document, error) {
+	return decode(r, &Document{dir: dir})
+}
+
+func decode(r io.Reader, doc *Document) (*Document, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var bin []byte
+	if len(content) >= 12 && binary.LittleEndian.Uint32(content) == glbMagic {
+		content, bin, err = readGLB(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(content, doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.Asset.Version, "2.") {
+		return nil, fmt.Errorf("unsupported glTF version %q", doc.Asset.Version)
+	}
+
+	if doc.Scene != nil && (*doc.Scene < 0 || *doc.Scene >= len(doc.Scenes)) {
+		return nil, fmt.Errorf("default scene %d does not exist", *doc.Scene)
+	}
+
+	for i, buffer := range doc.Buffers {
+		if buffer.ByteLength < 0 {
+			return nil, fmt.Errorf("buffer %d has a negative length", i)
+		}
+		var data []byte
+		switch {
+		case buffer.URI == "" && i == 0 && bin != nil:
+			data = bin
+		case buffer.URI == "":
+			return nil, fmt.Errorf("buffer %d has no uri", i)
+		default:
+			if data, err = doc.readURI(buffer.URI); err != nil {
+				return nil, fmt.Errorf("buffer %d: %v", i, err)
+			}
+		}
+		if len(data) < buffer.ByteLength {
+			return nil, fmt.Errorf("buffer %d is %d bytes, expected %d", i, len(data), buffer.ByteLength)
+		}
+		doc.data = append(doc.data, data)
+	}
+
+	for i, view := range doc.BufferViews {
+		if view.Buffer < 0 || view.Buffer >= len(doc.data) {
+			return nil, fmt.Errorf("buffer view %d references missing buffer %d", i, view.Buffer)
+		}
+		if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 {
+			return nil, fmt.Errorf("buffer view %d has a negative offset, length or stride", i)
+		}
+		if size := len(doc.data[view.Buffer]); view.ByteOffset > size || view.ByteLength > size-view.ByteOffset {
+			return nil, fmt.Errorf("buffer view %d is out of bounds", i)
+		}
+	}
+	for i, acc := range doc.Accessors {
+		if acc.Count < 0 || acc.ByteOffset < 0 {
+			return nil, fmt.Errorf("accessor %d has a negative count or offset", i)
+		}
+	}
+	return doc, nil
+}
+
+// readGLB splits a binary glTF into the JSON chunk and the optional binary chunk
+func readGLB(content []byte) ([]byte, []byte, error) {
+	version := binary.LittleEndian.Uint32(content[4:])
+	if version != 2 {
+		return nil, nil, fmt.Errorf("unsupported glb version %d", version)
+	}
+	length := int(binary.LittleEndian.Uint32(content[8:]))
+	if length > len(content) {
+		return nil, nil, fmt.Errorf("glb is truncated, got %d bytes, expected %d", len(content), length)
+	}
+
+	var jsonChunk, binChunk []byte
+	for offset := 12; offset+8 <= length; {
+		chunkLength := int(binary.LittleEndian.Uint32(content[offset:]))
+		chunkType := binary.LittleEndian.Uint32(content[offset+4:])
+		offset += 8
+		if offset+chunkLength > length {
+			return nil, nil, fmt.Errorf("glb chunk 0x%x is out of bounds", chunkType)
+		}
+		switch chunkType {
+		case glbChunkJSON:
+			jsonChunk = content[offset : offset+chunkLength]
+		case glbChunkBIN:
+			binChunk = content[offset : offset+chunkLength]
+		}
+		offset += chunkLength
+	}
+	if jsonChunk == nil {
+		return nil, nil, fmt.Errorf("glb has no JSON chunk")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+// readURI returns the content of a base64 data uri or a file relative to the document
+func (d *Document) readURI(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		i := strings.Index(uri, ";base64,")
+		if i < 0 {
+			return nil, fmt.Errorf("only base64 encoded data uris are supported")
+		}
+		return base64.StdEncoding.DecodeString(uri[i+len(";base64,"):])
+	}
+	if d.fsys != nil {
+		return fs.ReadFile(d.fsys, d.uriPath(uri))
+	}
+	return ioutil.ReadFile(d.uriPath(uri))
+}
+
+// uriPath returns the path of a file uri, in fsys when the document was opened with OpenFS
+func (d *Document) uriPath(uri string) string {
+	if unescaped, err := url.PathUnescape(uri); err == nil {
+		uri = unescaped
+	}
+	if d.fsys != nil {
+		return path.Join(d.dir, uri)
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(uri))
+}
+
+// ImageData returns the encoded (png or jpeg) content of an image
+func (d *Document) ImageData(index int) ([]byte, error) {
+	if index < 0 || index >= len(d.Images) {
+		return nil, fmt.Errorf("image %d does not exist", index)
+	}
+	img := d.Images[index]
+	if img.BufferView != nil {
+		return d.bufferView(*img.BufferView)
+	}
+	return d.readURI(img.URI)
+}
+
+// TextureImage returns the encoded image used by a texture
+func (d *Document) TextureImage(index int) ([]byte, error) {
+	if index < 0 || index >= len(d.Textures) || d.Textures[index].Source == nil {
+		return nil, fmt.Errorf("texture %d has no image", index)
+	}
+	return d.ImageData(*d.Textures[index].Source)
+}
+
+// TextureFile returns the path of the image file used by a texture, in fsys when the document was opened with OpenFS.
+// It is false for images that are embedded in a buffer or a data uri.
+func (d *Document) TextureFile(index int) (string, bool) {
+	if index < 0 || index >= len(d.Textures) || d.Textures[index].Source == nil {
+		return "", false
+	}
+	source := *d.Textures[index].Source
+	if source < 0 || source >= len(d.Images) {
+		return "", false
+	}
+	img := d.Images[source]
+	if img.BufferView != nil || img.URI == "" || strings.HasPrefix(img.URI, "data:") {
+		return "", false
+	}
+	return d.uriPath(img.URI), true
+}
+
+func (d *Document) bufferView(index int) ([]byte, error) {
+	if index < 0 || index >= len(d.BufferViews) {
+		return nil, fmt.Errorf("buffer view %d does not exist", index)
+	}
+	view := d.BufferViews[index]
+	return d.data[view.Buffer][view.ByteOffset : view.ByteOffset+view.ByteLength], nil
+}
+
+var numComponents = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+	"MAT2":   4,
+	"MAT3":   9,
+	"MAT4":   16,
+}
+
+var componentSize = map[int]int{
+	Byte:          1,
+	UnsignedByte:  1,
+	Short:         2,
+	UnsignedShort: 2,
+	UnsignedInt:   4,
+	Float:         4,
+}
+
+// maxSparseCount is the most elements an accessor without a buffer view can have, it's only there to catch broken files
+const maxSparseCount = 1 << 24
+
+// elements is the layout of the data of an accessor
+type elements struct {
+	Accessor
+	// data is the buffer view of the accessor, an accessor without one is all zeros
+	data       []byte
+	stride     int
+	components int
+	size       int
+}
+
+// component returns the bytes of component c of element i
+func (e elements) component(i, c int) []byte {
+	return e.data[e.ByteOffset+i*e.stride+c*e.size:]
+}
+
+// elements checks that the data of an accessor is within its buffer view and returns its layout
+func (d *Document) elements(index int) (elements, error) {
+	if index < 0 || index >= len(d.Accessors) {
+		return elements{}, fmt.Errorf("accessor %d does not exist", index)
+	}
+	e := elements{Accessor: d.Accessors[index]}
+	var ok bool
+	if e.components, ok = numComponents[e.Type]; !ok {
+		return e, fmt.Errorf("accessor %d has unknown type %q", index, e.Type)
+	}
+	if e.size, ok = componentSize[e.ComponentType]; !ok {
+		return e, fmt.Errorf("accessor %d has unknown component type %d", index, e.ComponentType)
+	}
+
+	if e.BufferView == nil {
+		if e.Count > maxSparseCount {
+			return e, fmt.Errorf("accessor %d has %d elements without a buffer view", index, e.Count)
+		}
+		return e, nil
+	}
+
+	var err error
+	if e.data, err = d.bufferView(*e.BufferView); err != nil {
+		return e, err
+	}
+	e.stride = d.BufferViews[*e.BufferView].ByteStride
+	if e.stride == 0 {
+		e.stride = e.size * e.components
+	}
+	// the last element has to fit in the rest of the view, which also keeps the count small enough to allocate
+	if e.Count > 0 && (e.ByteOffset > len(e.data) || e.Count-1 > (len(e.data)-e.ByteOffset)/e.stride ||
+		e.ByteOffset+(e.Count-1)*e.stride+e.size*e.components > len(e.data)) {
+		return e, fmt.Errorf("accessor %d is out of bounds", index)
+	}
+	return e, nil
+}
+
+// ReadFloats returns the accessor data as floats together with the number of components per element. Normalized
+// integer components are converted into the [0, 1] or [-1, 1] range.
+func (d *Document) ReadFloats(index int) ([]float32, int, error) {
+	e, err := d.elements(index)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]float32, e.Count*e.components)
+	if e.BufferView == nil {
+		return result, e.components, nil
+	}
+	for i := 0; i < e.Count; i++ {
+		for c := 0; c < e.components; c++ {
+			result[i*e.components+c] = readComponent(e.component(i, c), e.ComponentType, e.Normalized)
+		}
+	}
+	return result, e.components, nil
+}
+
+// ReadIndices returns the accessor data as unsigned integers, they are read as integers since a float32 can't hold
+// every index above 1<<24
+func (d *Document) ReadIndices(index int) ([]uint32, error) {
+	e, err := d.elements(index)
+	if err != nil {
+		return nil, err
+	}
+	if e.components != 1 {
+		return nil, fmt.Errorf("accessor %d is not a scalar", index)
+	}
+	if e.ComponentType != UnsignedByte && e.ComponentType != UnsignedShort && e.ComponentType != UnsignedInt {
+		return nil, fmt.Errorf("accessor %d is not an unsigned integer type", index)
+	}
+	result := make([]uint32, e.Count)
+	if e.BufferView == nil {
+		return result, nil
+	}
+	for i := range result {
+		b := e.component(i, 0)
+		switch e.ComponentType {
+		case UnsignedByte:
+			result[i] = uint32(b[0])
+		case UnsignedShort:
+			result[i] = uint32(binary.LittleEndian.Uint16(b))
+		default:
+			result[i] = binary.LittleEndian.Uint32(b)
+		}
+	}
+	return result, nil
+}
+
+func readComponent(b []byte, componentType int, normalized bool) float32 {
+	switch componentType {
+	case Byte:
+		v := float32(int8(b[0]))
+		if normalized {
+			return float32(math.Max(float64(v)/127, -1))
+		}
+		return v
+	case UnsignedByte:
+		v := float32(b[0])
+		if normalized {
+			return v / 255
+		}
+		return v
+	case Short:
+		v := float32(int16(binary.LittleEndian.Uint16(b)))
+		if normalized {
+			return float32(math.Max(float64(v)/32767, -1))
+		}
+		return v
+	case UnsignedShort:
+		v := float32(binary.LittleEndian.Uint16(b))
+		if normalized {
+			return v / 65535
+		}
+		return v
+	case UnsignedInt:
+		return float32(binary.LittleEndian.Uint32(b))
+	default:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+}
+
+// LocalTransform returns the nodes column major transform relative to its parent
+func (n Node) LocalTransform() [16]float32 {
+	if n.Matrix != nil {
+		return *n.Matrix
+	}
+	t := [3]float32{0, 0, 0}
+	if n.Translation != nil {
+		t = *n.Translation
+	}
+	q := [4]float32{0, 0, 0, 1}
+	if n.Rotation != nil {
+		q = *n.Rotation
+	}
+	s := [3]float32{1, 1, 1}
+	if n.Scale != nil {
+		s = *n.Scale
+	}
+
+	x, y, z, w := q[0], q[1], q[2], q[3]
+	// T * R * S
+	return [16]float32{
+		(1 - 2*(y*y+z*z)) * s[0], (2 * (x*y + z*w)) * s[0], (2 * (x*z - y*w)) * s[0], 0,
+		(2 * (x*y - z*w)) * s[1], (1 - 2*(x*x+z*z)) * s[1], (2 * (y*z + x*w)) * s[1], 0,
+		(2 * (x*z + y*w)) * s[2], (2 * (y*z - x*w)) * s[2], (1 - 2*(x*x+y*y)) * s[2], 0,
+		t[0], t[1], t[2], 1,
+	}
+}
+
+// RootNodes returns the top level nodes of the default scene, or all nodes without parents if there are no scenes
+func (d *Document) RootNodes() []int {
+	if len(d.Scenes) > 0 {
+		scene := 0
+		if d.Scene != nil && *d.Scene >= 0 && *d.Scene < len(d.Scenes) {
+			scene = *d.Scene
+		}
+		return d.Scenes[scene].Nodes
+	}
+	isChild := make(map[int]bool)
+	for _, node := range d.Nodes {
+		for _, child := range node.Children {
+			isChild[child] = true
+		}
+	}
+	var roots []int
+	for i := range d.Nodes {
+		if !isChild[i] {
+			roots = append(roots, i)
+		}
+	}
+	return roots
+}
@@ -0,0 +1,198 @@
+package gltf
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+var trianglePositions = []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}
+
+func TestOpenBuffers(t *testing.T) {
+	open := map[string]func() (*Document, error){
+		"external buffer": func() (*Document, error) { return Open("testdata/triangle.gltf") },
+		"external buffer in fs": func() (*Document, error) {
+			return OpenFS(os.DirFS("testdata"), "triangle.gltf")
+		},
+		"data uri": func() (*Document, error) { return Open("testdata/data_uri.gltf") },
+		"glb":      func() (*Document, error) { return Open("testdata/triangle.glb") },
+	}
+	for name, open := range open {
+		t.Run(name, func(t *testing.T) {
+			doc, err := open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if roots := doc.RootNodes(); len(roots) != 1 || doc.Nodes[roots[0]].Name != "triangle" {
+				t.Fatalf("got root nodes %v", roots)
+			}
+			positions, components, err := doc.ReadFloats(doc.Meshes[0].Primitives[0].Attributes["POSITION"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if components != 3 {
+				t.Fatalf("got %d components, expected 3", components)
+			}
+			expectFloats(t, positions, trianglePositions)
+			indices, err := doc.ReadIndices(*doc.Meshes[0].Primitives[0].Indices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(indices) != 3 || indices[0] != 0 || indices[1] != 1 || indices[2] != 2 {
+				t.Fatalf("got indices %v, expected [0 1 2]", indices)
+			}
+		})
+	}
+}
+
+func TestReadFloatsStrideAndNormalized(t *testing.T) {
+	doc, err := Open("testdata/interleaved.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attributes := doc.Meshes[0].Primitives[0].Attributes
+	tests := map[string]struct {
+		components int
+		expected   []float32
+	}{
+		// floats interleaved with the colours, 16 bytes apart
+		"POSITION": {3, trianglePositions},
+		// unsigned bytes that are mapped to [0, 1]
+		"COLOR_0": {4, []float32{1, 0, 128.0 / 255, 1, 0, 1, 0, 0.2, 0, 0, 1, 0}},
+		// shorts that are mapped to [-1, 1], with -32768 clamped to -1
+		"NORMAL": {3, []float32{0, 0, 1, 0, -1, 0, 16384.0 / 32767, 0, 0}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			values, components, err := doc.ReadFloats(attributes[name])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if components != test.components {
+				t.Fatalf("got %d components, expected %d", components, test.components)
+			}
+			expectFloats(t, values, test.expected)
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	// a 12 byte buffer
+	const buffer = `"buffers": [{"byteLength": 12, "uri": "data:application/octet-stream;base64,AAAAAAAAAAAAAAAA"}]`
+	tests := map[string]string{
+		"version":                `{"asset": {"version": "1.0"}}`,
+		"negative scene":         `{"asset": {"version": "2.0"}, "scene": -1, "scenes": [{"nodes": []}]}`,
+		"missing scene":          `{"asset": {"version": "2.0"}, "scene": 1, "scenes": [{"nodes": []}]}`,
+		"missing buffer":         `{"asset": {"version": "2.0"}, "bufferViews": [{"buffer": 1, "byteLength": 4}], ` + buffer + `}`,
+		"view out of bounds":     `{"asset": {"version": "2.0"}, "bufferViews": [{"buffer": 0, "byteOffset": 4, "byteLength": 12}], ` + buffer + `}`,
+		"negative view offset":   `{"asset": {"version": "2.0"}, "bufferViews": [{"buffer": 0, "byteOffset": -4, "byteLength": 8}], ` + buffer + `}`,
+		"negative view length":   `{"asset": {"version": "2.0"}, "bufferViews": [{"buffer": 0, "byteOffset": 8, "byteLength": -4}], ` + buffer + `}`,
+		"negative stride":        `{"asset": {"version": "2.0"}, "bufferViews": [{"buffer": 0, "byteLength": 12, "byteStride": -4}], ` + buffer + `}`,
+		"negative buffer length": `{"asset": {"version": "2.0"}, "buffers": [{"byteLength": -1, "uri": "data:application/octet-stream;base64,"}]}`,
+		"negative count":         `{"asset": {"version": "2.0"}, "accessors": [{"componentType": 5126, "count": -1, "type": "SCALAR"}]}`,
+		"negative accessor offset": `{"asset": {"version": "2.0"}, "accessors": [{"bufferView": 0, "byteOffset": -4, "componentType": 5126, "count": 1, "type": "SCALAR"}], ` +
+			`"bufferViews": [{"buffer": 0, "byteLength": 12}], ` + buffer + `}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(content), "."); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestRootNodesSceneOutOfRange(t *testing.T) {
+	for _, scene := range []int{-1, 2} {
+		scene := scene
+		doc := &Document{Scene: &scene, Scenes: []Scene{{Nodes: []int{1}}, {Nodes: []int{2}}}}
+		if roots := doc.RootNodes(); len(roots) != 1 || roots[0] != 1 {
+			t.Errorf("scene %d: got root nodes %v, expected the first scene", scene, roots)
+		}
+	}
+}
+
+func TestReadIndices(t *testing.T) {
+	// 1<<24+1 as an unsigned int, 65535 as an unsigned short and 255 as an unsigned byte, and a float
+	content := `{"asset": {"version": "2.0"}, "accessors": [
+		{"bufferView": 0, "componentType": 5125, "count": 1, "type": "SCALAR"},
+		{"bufferView": 0, "byteOffset": 4, "componentType": 5123, "count": 1, "type": "SCALAR"},
+		{"bufferView": 0, "byteOffset": 6, "componentType": 5121, "count": 1, "type": "SCALAR"},
+		{"bufferView": 0, "componentType": 5126, "count": 1, "type": "SCALAR"}
+	], "bufferViews": [{"buffer": 0, "byteLength": 8}],
+	"buffers": [{"byteLength": 8, "uri": "data:application/octet-stream;base64,AQAAAf///wA="}]}`
+	doc, err := Decode(strings.NewReader(content), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []uint32{1<<24 + 1, 65535, 255} {
+		indices, err := doc.ReadIndices(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(indices) != 1 || indices[0] != expected {
+			t.Errorf("accessor %d: got %v, expected [%d]", i, indices, expected)
+		}
+	}
+	if _, err := doc.ReadIndices(3); err == nil {
+		t.Error("float accessor: expected an error")
+	}
+}
+
+func TestReadFloatsOutOfBounds(t *testing.T) {
+	content := `{"asset": {"version": "2.0"}, "accessors": [
+		{"bufferView": 0, "componentType": 5126, "count": 4, "type": "SCALAR"},
+		{"bufferView": 0, "byteOffset": 4, "componentType": 5126, "count": 1, "type": "VEC3"},
+		{"bufferView": 1, "componentType": 5126, "count": 2, "type": "VEC2"},
+		{"componentType": 5126, "count": 1000000000000, "type": "SCALAR"}
+	], "bufferViews": [{"buffer": 0, "byteLength": 12}, {"buffer": 0, "byteLength": 12, "byteStride": 8}],
+	"buffers": [{"byteLength": 12, "uri": "data:application/octet-stream;base64,AAAAAAAAAAAAAAAA"}]}`
+	doc, err := Decode(strings.NewReader(content), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range doc.Accessors {
+		if _, _, err := doc.ReadFloats(i); err == nil {
+			t.Errorf("accessor %d: expected an error", i)
+		}
+	}
+}
+
+func TestPrimitiveVertices(t *testing.T) {
+	doc, err := Open("testdata/triangle.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertices, indices, hasTexCoords2, err := doc.PrimitiveVertices(doc.Meshes[0].Primitives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vertices) != 3 || len(indices) != 3 || hasTexCoords2 {
+		t.Fatalf("got %d vertices, %d indices and a second UV set %v", len(vertices), len(indices), hasTexCoords2)
+	}
+	for i, index := range indices {
+		v := vertices[index]
+		expectFloats(t, v.Position[:], trianglePositions[i*3:i*3+3])
+		// the triangle has no NORMAL attribute, so it gets the flat normal of its counter clockwise winding
+		expectFloats(t, v.Normal[:], []float32{0, 0, 1})
+	}
+
+	lines := Primitive{Attributes: doc.Meshes[0].Primitives[0].Attributes, Mode: new(int)}
+	*lines.Mode = Lines
+	if _, _, _, err := doc.PrimitiveVertices(lines); err == nil {
+		t.Error("lines: expected an error")
+	}
+}
+
+func expectFloats(t *testing.T, got, expected []float32) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("got %d values, expected %d", len(got), len(expected))
+	}
+	for i := range got {
+		if math.Abs(float64(got[i]-expected[i])) > 1e-6 {
+			t.Fatalf("value %d: got %f, expected %f", i, got[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package gltf
+
+import "io/fs"
+
+// https://github.com/KhronosGroup/glTF/tree/master/specification/2.0
+
+// Document is the JSON part of a glTF 2.0 asset
+type Document struct {
+	Asset       Asset        `json:"asset"`
+	Scene       *int         `json:"scene"`
+	Scenes      []Scene      `json:"scenes"`
+	Nodes       []Node       `json:"nodes"`
+	Meshes      []Mesh       `json:"meshes"`
+	Materials   []Material   `json:"materials"`
+	Textures    []Texture    `json:"textures"`
+	Images      []Image      `json:"images"`
+	Accessors   []Accessor   `json:"accessors"`
+	BufferViews []BufferView `json:"bufferViews"`
+	Buffers     []Buffer     `json:"buffers"`
+
+	// dir is where external buffers and images are resolved from, in fsys when it's set
+	dir  string
+	fsys fs.FS
+	// data is the loaded content of each of the Buffers
+	data [][]byte
+}
+
+type Asset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type Scene struct {
+	Name  string `json:"name"`
+	Nodes []int  `json:"nodes"`
+}
+
+type Node struct {
+	Name        string       `json:"name"`
+	Children    []int        `json:"children"`
+	Mesh        *int         `json:"mesh"`
+	Matrix      *[16]float32 `json:"matrix"`
+	Translation *[3]float32  `json:"translation"`
+	Rotation    *[4]float32  `json:"rotation"`
+	Scale       *[3]float32  `json:"scale"`
+}
+
+type Mesh struct {
+	Name       string      `json:"name"`
+	Primitives []Primitive `json:"primitives"`
+}
+
+// Primitive modes
+const (
+	Points        = 0
+	Lines         = 1
+	LineLoop      = 2
+	LineStrip     = 3
+	Triangles     = 4
+	TriangleStrip = 5
+	TriangleFan   = 6
+)
+
+type Primitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+}
+
+// DrawMode returns the primitive topology, which defaults to Triangles
+func (p Primitive) DrawMode() int {
+	if p.Mode == nil {
+		return Triangles
+	}
+	return *p.Mode
+}
+
+type Material struct {
+	Name                 string                `json:"name"`
+	PBRMetallicRoughness *PBRMetallicRoughness `json:"pbrMetallicRoughness"`
+	NormalTexture        *TextureInfo          `json:"normalTexture"`
+	OcclusionTexture     *TextureInfo          `json:"occlusionTexture"`
+	EmissiveTexture      *TextureInfo          `json:"emissiveTexture"`
+	EmissiveFactor       [3]float32            `json:"emissiveFactor"`
+}
+
+type PBRMetallicRoughness struct {
+	BaseColorFactor          *[4]float32  `json:"baseColorFactor"`
+	BaseColorTexture         *TextureInfo `json:"baseColorTexture"`
+	MetallicFactor           *float32     `json:"metallicFactor"`
+	RoughnessFactor          *float32     `json:"roughnessFactor"`
+	MetallicRoughnessTexture *TextureInfo `json:"metallicRoughnessTexture"`
+}
+
+// BaseColor returns the base color factor, which defaults to white
+func (m Material) BaseColor() [4]float32 {
+	if m.PBRMetallicRoughness == nil || m.PBRMetallicRoughness.BaseColorFactor == nil {
+		return [4]float32{1, 1, 1, 1}
+	}
+	return *m.PBRMetallicRoughness.BaseColorFactor
+}
+
+// Metallic returns the metallic factor, which defaults to 1
+func (m Material) Metallic() float32 {
+	if m.PBRMetallicRoughness == nil || m.PBRMetallicRoughness.MetallicFactor == nil {
+		return 1
+	}
+	return *m.PBRMetallicRoughness.MetallicFactor
+}
+
+// Roughness returns the roughness factor, which defaults to 1
+func (m Material) Roughness() float32 {
+	if m.PBRMetallicRoughness == nil || m.PBRMetallicRoughness.RoughnessFactor == nil {
+		return 1
+	}
+	return *m.PBRMetallicRoughness.RoughnessFactor
+}
+
+type TextureInfo struct {
+	Index    int     `json:"index"`
+	TexCoord int     `json:"texCoord"`
+	Scale    float32 `json:"scale"`
+}
+
+type Texture struct {
+	Sampler *int `json:"sampler"`
+	Source  *int `json:"source"`
+}
+
+type Image struct {
+	Name       string `json:"name"`
+	URI        string `json:"uri"`
+	MimeType   string `json:"mimeType"`
+	BufferView *int   `json:"bufferView"`
+}
+
+// Accessor component types
+const (
+	Byte          = 5120
+	UnsignedByte  = 5121
+	Short         = 5122
+	UnsignedShort = 5123
+	UnsignedInt   = 5125
+	Float         = 5126
+)
+
+type Accessor struct {
+	BufferView    *int   `json:"bufferView"`
+	ByteOffset    int    `json:"byteOffset"`
+	ComponentType int    `json:"componentType"`
+	Normalized    bool   `json:"normalized"`
+	Count         int    `json:"count"`
+	Type          string `json:"type"`
+}
+
+type BufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type Buffer struct {
+	URI        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
@@ -0,0 +1,140 @@
+package gltf
+
+import (
+	"fmt"
+
+	"github.com/stojg/cspace/lib/geom"
+)
+
+// PrimitiveVertices returns the indexed vertices of a triangle primitive with tangents calculated, and whether the
+// primitive has a second UV set. Texture coordinates are flipped into the bottom left origin that the OBJ loader uses,
+// and primitives without normals get flat normals.
+func (d *Document) PrimitiveVertices(p Primitive) ([]geom.Vertex, []uint32, bool, error) {
+	if p.DrawMode() != Triangles {
+		return nil, nil, false, fmt.Errorf("primitive mode %d is not supported", p.DrawMode())
+	}
+	positions, err := d.attribute(p, "POSITION", 3, true)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	// glTF makes normals optional, flat normals are used for primitives without them
+	normals, err := d.attribute(p, "NORMAL", 3, false)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	uvs, err := d.attribute(p, "TEXCOORD_0", 2, false)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	uvs2, err := d.attribute(p, "TEXCOORD_1", 2, false)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	colors, colorComponents, err := d.colors(p)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	count := len(positions) / 3
+	if (normals != nil && len(normals)/3 != count) || (uvs != nil && len(uvs)/2 != count) ||
+		(uvs2 != nil && len(uvs2)/2 != count) || (colors != nil && len(colors)/colorComponents != count) {
+		return nil, nil, false, fmt.Errorf("attributes have different lengths")
+	}
+
+	var indices []uint32
+	if p.Indices != nil {
+		if indices, err = d.ReadIndices(*p.Indices); err != nil {
+			return nil, nil, false, err
+		}
+	} else {
+		for i := 0; i < count; i++ {
+			indices = append(indices, uint32(i))
+		}
+	}
+
+	// expand into the same triangle list that the OBJ loader produces so tangents can be calculated
+	data := make([]float32, 0, len(indices)*8)
+	var vertexColors, texCoords2 []float32
+	for _, i := range indices {
+		if int(i) >= count {
+			return nil, nil, false, fmt.Errorf("index %d is out of bounds", i)
+		}
+		data = append(data, positions[i*3:i*3+3]...)
+		if normals != nil {
+			data = append(data, normals[i*3:i*3+3]...)
+		} else {
+			data = append(data, 0, 0, 0)
+		}
+		if uvs != nil {
+			// glTF has the texture origin in the top left corner
+			data = append(data, uvs[i*2], 1-uvs[i*2+1])
+		} else {
+			data = append(data, 0, 0)
+		}
+		if colors != nil {
+			c := colors[int(i)*colorComponents : int(i+1)*colorComponents]
+			alpha := float32(1)
+			if colorComponents == 4 {
+				alpha = c[3]
+			}
+			vertexColors = append(vertexColors, c[0], c[1], c[2], alpha)
+		}
+		if uvs2 != nil {
+			texCoords2 = append(texCoords2, uvs2[i*2], 1-uvs2[i*2+1])
+		}
+	}
+	if normals == nil {
+		if err := geom.FlatNormals(data); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	triangles, err := geom.Triangles(data)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if vertexColors != nil {
+		if err := geom.SetColors(triangles, vertexColors); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if texCoords2 != nil {
+		if err := geom.SetTexCoords2(triangles, texCoords2); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	vertices, vertexIndices := geom.Index(triangles)
+	return vertices, vertexIndices, uvs2 != nil, nil
+}
+
+// colors returns the COLOR_0 attribute, which is either RGB or RGBA
+func (d *Document) colors(p Primitive) ([]float32, int, error) {
+	accessor, found := p.Attributes["COLOR_0"]
+	if !found {
+		return nil, 0, nil
+	}
+	values, n, err := d.ReadFloats(accessor)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n != 3 && n != 4 {
+		return nil, 0, fmt.Errorf("COLOR_0 attribute has %d components, expected 3 or 4", n)
+	}
+	return values, n, nil
+}
+
+func (d *Document) attribute(p Primitive, name string, components int, required bool) ([]float32, error) {
+	accessor, found := p.Attributes[name]
+	if !found {
+		if required {
+			return nil, fmt.Errorf("missing %s attribute", name)
+		}
+		return nil, nil
+	}
+	values, n, err := d.ReadFloats(accessor)
+	if err != nil {
+		return nil, err
+	}
+	if n != components {
+		return nil, fmt.Errorf("%s attribute has %d components, expected %d", name, n, components)
+	}
+	return values, nil
+}
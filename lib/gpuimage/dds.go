@@ -0,0 +1,160 @@
+package gpuimage
+
+// https://docs.microsoft.com/en-us/windows/win32/direct3ddds/dx-graphics-dds-pguide
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	ddsdMipMapCount = 0x20000
+
+	ddpfFourCC    = 0x4
+	ddpfRGB       = 0x40
+	ddpfLuminance = 0x20000
+
+	ddsCaps2Cubemap = 0x200
+	ddsCaps2Volume  = 0x200000
+)
+
+type ddsPixelFormat struct {
+	Size        uint32
+	Flags       uint32
+	FourCC      [4]byte
+	RGBBitCount uint32
+	RBitMask    uint32
+	GBitMask    uint32
+	BBitMask    uint32
+	ABitMask    uint32
+}
+
+type ddsHeader struct {
+	Size              uint32
+	Flags             uint32
+	Height            uint32
+	Width             uint32
+	PitchOrLinearSize uint32
+	Depth             uint32
+	MipMapCount       uint32
+	Reserved1         [11]uint32
+	PixelFormat       ddsPixelFormat
+	Caps              uint32
+	Caps2             uint32
+	Caps3             uint32
+	Caps4             uint32
+	Reserved2         uint32
+}
+
+type ddsHeaderDX10 struct {
+	DXGIFormat        uint32
+	ResourceDimension uint32
+	MiscFlag          uint32
+	ArraySize         uint32
+	MiscFlags2        uint32
+}
+
+type dxgiFormat struct {
+	format Format
+	srgb   bool
+}
+
+var dxgiFormats = map[uint32]dxgiFormat{
+	2:  {RGBA32F, false},
+	10: {RGBA16F, false},
+	28: {RGBA8, false},
+	29: {RGBA8, true},
+	49: {RG8, false},
+	61: {R8, false},
+	71: {BC1, false},
+	72: {BC1, true},
+	77: {BC3, false},
+	78: {BC3, true},
+	83: {BC5, false},
+	87: {BGRA8, false},
+	91: {BGRA8, true},
+	98: {BC7, false},
+	99: {BC7, true},
+}
+
+// DecodeDDS reads a 2D DirectDraw Surface file. DDS images are stored top down.
+func DecodeDDS(r io.Reader) (*Image, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || string(magic[:]) != ddsMagic {
+		return nil, errors.New("dds: not a DDS file")
+	}
+	var h ddsHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return nil, fmt.Errorf("dds: reading header: %v", err)
+	}
+	if h.Size != 124 || h.PixelFormat.Size != 32 {
+		return nil, errors.New("dds: bad header size")
+	}
+	if h.Caps2&(ddsCaps2Cubemap|ddsCaps2Volume) != 0 {
+		return nil, errors.New("dds: only 2D textures are supported")
+	}
+
+	img := &Image{
+		Width:  int(h.Width),
+		Height: int(h.Height),
+	}
+
+	pf := h.PixelFormat
+	switch {
+	case pf.Flags&ddpfFourCC != 0:
+		switch string(pf.FourCC[:]) {
+		case "DXT1":
+			img.Format = BC1
+		case "DXT5":
+			img.Format = BC3
+		case "ATI2", "BC5U":
+			img.Format = BC5
+		case "DX10":
+			var dx10 ddsHeaderDX10
+			if err := binary.Read(r, binary.LittleEndian, &dx10); err != nil {
+				return nil, fmt.Errorf("dds: reading DX10 header: %v", err)
+			}
+			if dx10.ArraySize > 1 || dx10.ResourceDimension != 3 {
+				return nil, errors.New("dds: only 2D textures are supported")
+			}
+			f, ok := dxgiFormats[dx10.DXGIFormat]
+			if !ok {
+				return nil, fmt.Errorf("dds: unsupported DXGI format %d", dx10.DXGIFormat)
+			}
+			img.Format, img.SRGB = f.format, f.srgb
+		default:
+			return nil, fmt.Errorf("dds: unsupported format %q", pf.FourCC[:])
+		}
+	case pf.Flags&ddpfRGB != 0 && pf.RGBBitCount == 32:
+		switch {
+		case pf.RBitMask == 0xff && pf.GBitMask == 0xff00 && pf.BBitMask == 0xff0000:
+			img.Format = RGBA8
+		case pf.RBitMask == 0xff0000 && pf.GBitMask == 0xff00 && pf.BBitMask == 0xff:
+			img.Format = BGRA8
+		}
+	case pf.Flags&ddpfLuminance != 0 && pf.RGBBitCount == 8:
+		img.Format = R8
+	}
+	if img.Format == Unknown {
+		return nil, errors.New("dds: unsupported pixel format")
+	}
+
+	levels := 1
+	if h.Flags&ddsdMipMapCount != 0 && h.MipMapCount > 1 {
+		levels = int(h.MipMapCount)
+	}
+	if err := img.checkDimensions(levels); err != nil {
+		return nil, err
+	}
+	for level := 0; level < levels; level++ {
+		w, h := img.LevelDimensions(level)
+		data := make([]byte, img.Format.LevelSize(w, h))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("dds: reading mip level %d: %v", level, err)
+		}
+		img.Levels = append(img.Levels, data)
+	}
+	return img, img.checkLevels()
+}
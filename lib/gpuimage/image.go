@@ -0,0 +1,239 @@
+// Package gpuimage reads DDS and KTX texture containers that store GPU ready, optionally block compressed, images
+// together with their prebuilt mip chains, and writes KTX files.
+package gpuimage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Format is the pixel layout of the image data
+type Format int
+
+const (
+	Unknown Format = iota
+	R8
+	RG8
+	RGBA8
+	BGRA8
+	RGBA16F
+	RGBA32F
+	BC1
+	BC3
+	BC5
+	BC7
+)
+
+var formatNames = map[Format]string{
+	Unknown: "unknown",
+	R8:      "R8",
+	RG8:     "RG8",
+	RGBA8:   "RGBA8",
+	BGRA8:   "BGRA8",
+	RGBA16F: "RGBA16F",
+	RGBA32F: "RGBA32F",
+	BC1:     "BC1",
+	BC3:     "BC3",
+	BC5:     "BC5",
+	BC7:     "BC7",
+}
+
+func (f Format) String() string {
+	return formatNames[f]
+}
+
+// Compressed returns true for the 4x4 block compressed formats
+func (f Format) Compressed() bool {
+	return f.BlockSize() > 0
+}
+
+// BlockSize returns the number of bytes in a 4x4 block, or 0 for uncompressed formats
+func (f Format) BlockSize() int {
+	switch f {
+	case BC1:
+		return 8
+	case BC3, BC5, BC7:
+		return 16
+	}
+	return 0
+}
+
+// PixelSize returns the number of bytes per pixel, or 0 for compressed formats
+func (f Format) PixelSize() int {
+	switch f {
+	case R8:
+		return 1
+	case RG8:
+		return 2
+	case RGBA8, BGRA8:
+		return 4
+	case RGBA16F:
+		return 8
+	case RGBA32F:
+		return 16
+	}
+	return 0
+}
+
+// LevelSize returns the number of bytes of a width x height image in this format
+func (f Format) LevelSize(width, height int) int {
+	if f.Compressed() {
+		return ((width + 3) / 4) * ((height + 3) / 4) * f.BlockSize()
+	}
+	return width * height * f.PixelSize()
+}
+
+// Image is a texture with one or more mip levels, Levels[0] is the full size image
+type Image struct {
+	Format Format
+	// SRGB is set when the container declares the colour data as sRGB encoded
+	SRGB   bool
+	Width  int
+	Height int
+	Levels [][]byte
+	// BottomUp is set when the first row in the data is the bottom of the image, as OpenGL expects it
+	BottomUp bool
+}
+
+// LevelDimensions returns the width and height of a mip level
+func (i *Image) LevelDimensions(level int) (int, int) {
+	w, h := i.Width>>uint(level), i.Height>>uint(level)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// Magic numbers for the supported containers
+const (
+	ddsMagic  = "DDS "
+	ktx1Magic = "\xabKTX 11\xbb\r\n\x1a\n"
+	ktx2Magic = "\xabKTX 20\xbb\r\n\x1a\n"
+)
+
+// Decode reads a DDS, KTX or KTX2 file, detected by its magic number
+func Decode(r io.Reader) (*Image, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(ktx1Magic))
+	switch {
+	case bytes.HasPrefix(magic, []byte(ddsMagic)):
+		return DecodeDDS(br)
+	case string(magic) == ktx1Magic, string(magic) == ktx2Magic:
+		return DecodeKTX(br)
+	}
+	return nil, errors.New("gpuimage: unknown container format")
+}
+
+// checkDimensions guards against corrupt headers before any image data is allocated
+func (i *Image) checkDimensions(levels int) error {
+	if i.Width < 1 || i.Height < 1 || i.Width > 1<<15 || i.Height > 1<<15 {
+		return fmt.Errorf("gpuimage: bad image dimensions %dx%d", i.Width, i.Height)
+	}
+	if levels < 1 || levels > 16 {
+		return fmt.Errorf("gpuimage: bad mip level count %d", levels)
+	}
+	return nil
+}
+
+// checkLevels validates that every mip level has the expected size
+func (i *Image) checkLevels() error {
+	if len(i.Levels) == 0 {
+		return errors.New("gpuimage: image has no data")
+	}
+	for level, data := range i.Levels {
+		w, h := i.LevelDimensions(level)
+		if expected := i.Format.LevelSize(w, h); len(data) != expected {
+			return fmt.Errorf("gpuimage: mip level %d is %d bytes, expected %d", level, len(data), expected)
+		}
+	}
+	return nil
+}
+
+// FlipVertical turns a top down image into a bottom up one, or the other way around. Only BC7 images cannot be flipped
+// since the layout of its blocks depends on their mode.
+func (i *Image) FlipVertical() error {
+	if i.Format == BC7 {
+		return errors.New("gpuimage: BC7 images cannot be flipped, store them bottom up")
+	}
+	for level, data := range i.Levels {
+		w, h := i.LevelDimensions(level)
+		if !i.Format.Compressed() {
+			flipRows(data, w*i.Format.PixelSize(), h)
+			continue
+		}
+		if h > 4 && h%4 != 0 {
+			return fmt.Errorf("gpuimage: a compressed image height of %d is not a multiple of 4", h)
+		}
+		rowSize := ((w + 3) / 4) * i.Format.BlockSize()
+		flipRows(data, rowSize, (h+3)/4)
+		for b := 0; b < len(data); b += i.Format.BlockSize() {
+			flipBlock(i.Format, data[b:b+i.Format.BlockSize()], h)
+		}
+	}
+	i.BottomUp = !i.BottomUp
+	return nil
+}
+
+func flipRows(data []byte, rowSize, rows int) {
+	tmp := make([]byte, rowSize)
+	for top, bottom := 0, rows-1; top < bottom; top, bottom = top+1, bottom-1 {
+		a := data[top*rowSize : (top+1)*rowSize]
+		b := data[bottom*rowSize : (bottom+1)*rowSize]
+		copy(tmp, a)
+		copy(a, b)
+		copy(b, tmp)
+	}
+}
+
+// flipBlock reverses the pixel rows inside a block, for images shorter than a block only the used rows are flipped
+func flipBlock(f Format, block []byte, height int) {
+	rows := 4
+	if height < 4 {
+		rows = height
+	}
+	switch f {
+	case BC1:
+		flipColorBlock(block, rows)
+	case BC3:
+		flipAlphaBlock(block[:8], rows)
+		flipColorBlock(block[8:], rows)
+	case BC5:
+		flipAlphaBlock(block[:8], rows)
+		flipAlphaBlock(block[8:], rows)
+	}
+}
+
+// flipColorBlock flips the BC1 colour indices, stored as one byte per row after the two endpoints
+func flipColorBlock(block []byte, rows int) {
+	for top, bottom := 4, 4+rows-1; top < bottom; top, bottom = top+1, bottom-1 {
+		block[top], block[bottom] = block[bottom], block[top]
+	}
+}
+
+// flipAlphaBlock flips the BC3/BC4 indices, stored as 12 bits per row in a 48 bit number after the two endpoints
+func flipAlphaBlock(block []byte, rows int) {
+	var bits uint64
+	for i := 0; i < 6; i++ {
+		bits |= uint64(block[2+i]) << uint(8*i)
+	}
+	var row [4]uint64
+	for r := range row {
+		row[r] = (bits >> uint(12*r)) & 0xfff
+	}
+	for top, bottom := 0, rows-1; top < bottom; top, bottom = top+1, bottom-1 {
+		row[top], row[bottom] = row[bottom], row[top]
+	}
+	bits = 0
+	for r := range row {
+		bits |= row[r] << uint(12*r)
+	}
+	for i := 0; i < 6; i++ {
+		block[2+i] = byte(bits >> uint(8*i))
+	}
+}
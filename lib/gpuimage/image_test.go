@@ -0,0 +1,305 @@
+package gpuimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// testImage returns an image with a full mip chain filled with a counting pattern, so that every byte is different
+func testImage(f Format, srgb bool, width, height int, bottomUp bool) *Image {
+	img := &Image{Format: f, SRGB: srgb, Width: width, Height: height, BottomUp: bottomUp}
+	n := byte(0)
+	for level := 0; ; level++ {
+		w, h := img.LevelDimensions(level)
+		data := make([]byte, f.LevelSize(w, h))
+		for i := range data {
+			data[i] = n
+			n++
+		}
+		img.Levels = append(img.Levels, data)
+		if w == 1 && h == 1 {
+			return img
+		}
+	}
+}
+
+func TestKTXRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *Image
+	}{
+		// rows that aren't a multiple of 4 bytes are padded in the file
+		{"R8 odd width", testImage(R8, false, 5, 3, true)},
+		{"RG8", testImage(RG8, false, 6, 4, true)},
+		{"RGBA8 sRGB", testImage(RGBA8, true, 8, 8, true)},
+		{"RGBA16F", testImage(RGBA16F, false, 4, 2, true)},
+		{"BC1", testImage(BC1, false, 16, 8, true)},
+		{"BC3 sRGB", testImage(BC3, true, 8, 8, true)},
+		{"BC5 top down", testImage(BC5, false, 8, 16, false)},
+		{"BC7 top down", testImage(BC7, true, 12, 4, false)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeKTX(&buf, test.img); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, test.img) {
+				t.Errorf("got %s %dx%d srgb=%v bottomUp=%v with %d levels, expected %s %dx%d srgb=%v bottomUp=%v with %d levels",
+					decoded.Format, decoded.Width, decoded.Height, decoded.SRGB, decoded.BottomUp, len(decoded.Levels),
+					test.img.Format, test.img.Width, test.img.Height, test.img.SRGB, test.img.BottomUp, len(test.img.Levels))
+			}
+		})
+	}
+}
+
+func TestEncodeKTXUnsupported(t *testing.T) {
+	if err := EncodeKTX(&bytes.Buffer{}, testImage(BGRA8, false, 4, 4, true)); err == nil {
+		t.Error("expected an error for a format that KTX 1 can't describe")
+	}
+}
+
+// ktx2File returns a KTX 2 file without key value data, so it has the default top down orientation
+func ktx2File(vkFormat uint32, img *Image) []byte {
+	header := ktx2Header{
+		VkFormat:    vkFormat,
+		TypeSize:    1,
+		PixelWidth:  uint32(img.Width),
+		PixelHeight: uint32(img.Height),
+		FaceCount:   1,
+		LevelCount:  uint32(len(img.Levels)),
+	}
+	// the smallest mip level is stored first
+	offset := len(ktx2Magic) + binary.Size(header) + binary.Size(ktx2Level{})*len(img.Levels)
+	index := make([]ktx2Level, len(img.Levels))
+	var data []byte
+	for level := len(img.Levels) - 1; level >= 0; level-- {
+		size := uint64(len(img.Levels[level]))
+		index[level] = ktx2Level{ByteOffset: uint64(offset + len(data)), ByteLength: size, UncompressedByteLength: size}
+		data = append(data, img.Levels[level]...)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(ktx2Magic)
+	binary.Write(&buf, binary.LittleEndian, header)
+	binary.Write(&buf, binary.LittleEndian, index)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestDecodeKTX2TopDown(t *testing.T) {
+	img := testImage(BC7, true, 8, 8, false)
+	decoded, err := Decode(bytes.NewReader(ktx2File(146, img)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, img) {
+		t.Errorf("got %s srgb=%v bottomUp=%v, expected a top down sRGB BC7 image with the same levels", decoded.Format, decoded.SRGB, decoded.BottomUp)
+	}
+}
+
+// ddsFile returns a DDS file of the image, with a DX10 header when fourCC is "DX10"
+func ddsFile(fourCC string, dxgiFormat uint32, img *Image) []byte {
+	header := ddsHeader{
+		Size:        124,
+		Flags:       ddsdMipMapCount,
+		Height:      uint32(img.Height),
+		Width:       uint32(img.Width),
+		MipMapCount: uint32(len(img.Levels)),
+		PixelFormat: ddsPixelFormat{Size: 32, Flags: ddpfFourCC},
+	}
+	copy(header.PixelFormat.FourCC[:], fourCC)
+	var buf bytes.Buffer
+	buf.WriteString(ddsMagic)
+	binary.Write(&buf, binary.LittleEndian, header)
+	if fourCC == "DX10" {
+		binary.Write(&buf, binary.LittleEndian, ddsHeaderDX10{DXGIFormat: dxgiFormat, ResourceDimension: 3, ArraySize: 1})
+	}
+	for _, level := range img.Levels {
+		buf.Write(level)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeDDS(t *testing.T) {
+	tests := []struct {
+		name       string
+		fourCC     string
+		dxgiFormat uint32
+		img        *Image
+	}{
+		{"DXT1", "DXT1", 0, testImage(BC1, false, 8, 4, false)},
+		{"DXT5", "DXT5", 0, testImage(BC3, false, 4, 8, false)},
+		{"ATI2", "ATI2", 0, testImage(BC5, false, 8, 8, false)},
+		{"DX10 BC7 sRGB", "DX10", 99, testImage(BC7, true, 16, 16, false)},
+		{"DX10 RGBA8", "DX10", 28, testImage(RGBA8, false, 3, 5, false)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := Decode(bytes.NewReader(ddsFile(test.fourCC, test.dxgiFormat, test.img)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, test.img) {
+				t.Errorf("got %s %dx%d srgb=%v bottomUp=%v with %d levels, expected %s %dx%d srgb=%v top down with %d levels",
+					decoded.Format, decoded.Width, decoded.Height, decoded.SRGB, decoded.BottomUp, len(decoded.Levels),
+					test.img.Format, test.img.Width, test.img.Height, test.img.SRGB, len(test.img.Levels))
+			}
+		})
+	}
+}
+
+func TestDecodeDDSBadHeader(t *testing.T) {
+	valid := ddsFile("DXT1", 0, testImage(BC1, false, 8, 8, false))
+	corrupt := map[string]func([]byte){
+		"header size": func(b []byte) { binary.LittleEndian.PutUint32(b[4:], 100) },
+		// Caps2 follows the pixel format and Caps
+		"cubemap":   func(b []byte) { binary.LittleEndian.PutUint32(b[4+108:], ddsCaps2Cubemap) },
+		"no width":  func(b []byte) { binary.LittleEndian.PutUint32(b[4+12:], 0) },
+		"huge":      func(b []byte) { binary.LittleEndian.PutUint32(b[4+8:], 1<<20) },
+		"format":    func(b []byte) { copy(b[4+80:], "DXT3") },
+		"truncated": nil,
+	}
+	for name, corrupt := range corrupt {
+		b := append([]byte(nil), valid...)
+		if corrupt != nil {
+			corrupt(b)
+		} else {
+			b = b[:len(b)-1]
+		}
+		if _, err := DecodeDDS(bytes.NewReader(b)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// colorBlock returns a BC1 block with the endpoints and one byte of 2 bit indices for each of the 4 rows
+func colorBlock(endpoints uint32, rows [4]byte) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint32(b, endpoints)
+	copy(b[4:], rows[:])
+	return b
+}
+
+// alphaBlock returns a BC3 alpha or BC4/BC5 channel block with the endpoints and 12 bits of 3 bit indices per row
+func alphaBlock(a0, a1 byte, rows [4]uint16) []byte {
+	var bits uint64
+	for r, row := range rows {
+		bits |= uint64(row&0xfff) << uint(12*r)
+	}
+	b := []byte{a0, a1, 0, 0, 0, 0, 0, 0}
+	for i := 0; i < 6; i++ {
+		b[2+i] = byte(bits >> uint(8*i))
+	}
+	return b
+}
+
+func concat(blocks ...[]byte) []byte {
+	var b []byte
+	for _, block := range blocks {
+		b = append(b, block...)
+	}
+	return b
+}
+
+func TestFlipVertical(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        Format
+		width, height int
+		data, flipped []byte
+	}{
+		{
+			name:   "BC1 one block",
+			format: BC1, width: 4, height: 4,
+			data:    colorBlock(0x12345678, [4]byte{0x00, 0x55, 0xaa, 0xff}),
+			flipped: colorBlock(0x12345678, [4]byte{0xff, 0xaa, 0x55, 0x00}),
+		},
+		{
+			// the block rows are swapped and the pixel rows inside every block are reversed
+			name:   "BC1 two block rows",
+			format: BC1, width: 4, height: 8,
+			data: concat(
+				colorBlock(1, [4]byte{1, 2, 3, 4}),
+				colorBlock(2, [4]byte{5, 6, 7, 8}),
+			),
+			flipped: concat(
+				colorBlock(2, [4]byte{8, 7, 6, 5}),
+				colorBlock(1, [4]byte{4, 3, 2, 1}),
+			),
+		},
+		{
+			// only the two rows that are part of the image are flipped
+			name:   "BC1 shorter than a block",
+			format: BC1, width: 4, height: 2,
+			data:    colorBlock(7, [4]byte{1, 2, 3, 4}),
+			flipped: colorBlock(7, [4]byte{2, 1, 3, 4}),
+		},
+		{
+			name:   "BC3",
+			format: BC3, width: 4, height: 4,
+			data: concat(
+				alphaBlock(255, 0, [4]uint16{0x001, 0x023, 0x456, 0xfff}),
+				colorBlock(9, [4]byte{1, 2, 3, 4}),
+			),
+			flipped: concat(
+				alphaBlock(255, 0, [4]uint16{0xfff, 0x456, 0x023, 0x001}),
+				colorBlock(9, [4]byte{4, 3, 2, 1}),
+			),
+		},
+		{
+			name:   "BC5",
+			format: BC5, width: 8, height: 4,
+			data: concat(
+				alphaBlock(1, 2, [4]uint16{0x111, 0x222, 0x333, 0x444}),
+				alphaBlock(3, 4, [4]uint16{0x555, 0x666, 0x777, 0x888}),
+				alphaBlock(5, 6, [4]uint16{0xabc, 0, 0, 0xdef}),
+				alphaBlock(7, 8, [4]uint16{1, 2, 3, 4}),
+			),
+			flipped: concat(
+				alphaBlock(1, 2, [4]uint16{0x444, 0x333, 0x222, 0x111}),
+				alphaBlock(3, 4, [4]uint16{0x888, 0x777, 0x666, 0x555}),
+				alphaBlock(5, 6, [4]uint16{0xdef, 0, 0, 0xabc}),
+				alphaBlock(7, 8, [4]uint16{4, 3, 2, 1}),
+			),
+		},
+		{
+			name:   "RGBA8",
+			format: RGBA8, width: 1, height: 3,
+			data:    []byte{1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3},
+			flipped: []byte{3, 3, 3, 3, 2, 2, 2, 2, 1, 1, 1, 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := &Image{Format: test.format, Width: test.width, Height: test.height, Levels: [][]byte{append([]byte(nil), test.data...)}}
+			if err := img.FlipVertical(); err != nil {
+				t.Fatal(err)
+			}
+			if !img.BottomUp {
+				t.Error("a top down image is still top down after flipping it")
+			}
+			if !bytes.Equal(img.Levels[0], test.flipped) {
+				t.Errorf("got\n%x, expected\n%x", img.Levels[0], test.flipped)
+			}
+			if err := img.FlipVertical(); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(img.Levels[0], test.data) || img.BottomUp {
+				t.Error("flipping twice doesn't give back the image")
+			}
+		})
+	}
+}
+
+func TestFlipVerticalBC7(t *testing.T) {
+	img := testImage(BC7, false, 4, 4, false)
+	if err := img.FlipVertical(); err == nil {
+		t.Error("expected an error, BC7 blocks can't be flipped")
+	}
+}
@@ -0,0 +1,395 @@
+package gpuimage
+
+// https://registry.khronos.org/KTX/specs/1.0/ktxspec.v1.html
+// https://registry.khronos.org/KTX/specs/2.0/ktxspec.v2.html
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type ktxFormat struct {
+	format Format
+	srgb   bool
+}
+
+// glFormats maps the KTX 1 glInternalFormat to a Format
+var glFormats = map[uint32]ktxFormat{
+	0x8229: {R8, false},
+	0x822B: {RG8, false},
+	0x8058: {RGBA8, false},
+	0x8C43: {RGBA8, true},
+	0x881A: {RGBA16F, false},
+	0x8814: {RGBA32F, false},
+	0x83F0: {BC1, false},
+	0x83F1: {BC1, false},
+	0x8C4C: {BC1, true},
+	0x8C4D: {BC1, true},
+	0x83F3: {BC3, false},
+	0x8C4F: {BC3, true},
+	0x8DBD: {BC5, false},
+	0x8E8C: {BC7, false},
+	0x8E8D: {BC7, true},
+}
+
+// vkFormats maps the KTX 2 vkFormat to a Format
+var vkFormats = map[uint32]ktxFormat{
+	9:   {R8, false},
+	16:  {RG8, false},
+	37:  {RGBA8, false},
+	43:  {RGBA8, true},
+	44:  {BGRA8, false},
+	50:  {BGRA8, true},
+	97:  {RGBA16F, false},
+	109: {RGBA32F, false},
+	131: {BC1, false},
+	132: {BC1, true},
+	133: {BC1, false},
+	134: {BC1, true},
+	137: {BC3, false},
+	138: {BC3, true},
+	141: {BC5, false},
+	145: {BC7, false},
+	146: {BC7, true},
+}
+
+type ktx1Header struct {
+	Endianness            uint32
+	GLType                uint32
+	GLTypeSize            uint32
+	GLFormat              uint32
+	GLInternalFormat      uint32
+	GLBaseInternalFormat  uint32
+	PixelWidth            uint32
+	PixelHeight           uint32
+	PixelDepth            uint32
+	NumberOfArrayElements uint32
+	NumberOfFaces         uint32
+	NumberOfMipmapLevels  uint32
+	BytesOfKeyValueData   uint32
+}
+
+type ktx2Header struct {
+	VkFormat               uint32
+	TypeSize               uint32
+	PixelWidth             uint32
+	PixelHeight            uint32
+	PixelDepth             uint32
+	LayerCount             uint32
+	FaceCount              uint32
+	LevelCount             uint32
+	SupercompressionScheme uint32
+	DFDByteOffset          uint32
+	DFDByteLength          uint32
+	KVDByteOffset          uint32
+	KVDByteLength          uint32
+	SGDByteOffset          uint64
+	SGDByteLength          uint64
+}
+
+type ktx2Level struct {
+	ByteOffset             uint64
+	ByteLength             uint64
+	UncompressedByteLength uint64
+}
+
+// maxKeyValueData limits how much metadata will be read from a file
+const maxKeyValueData = 1 << 20
+
+// DecodeKTX reads a 2D KTX or KTX2 file. The KTXorientation metadata decides if the image is stored top down or
+// bottom up, without it KTX 1 files are bottom up and KTX 2 files are top down.
+func DecodeKTX(r io.Reader) (*Image, error) {
+	magic := make([]byte, len(ktx1Magic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, errors.New("ktx: not a KTX file")
+	}
+	switch string(magic) {
+	case ktx1Magic:
+		return decodeKTX1(r)
+	case ktx2Magic:
+		// the level index uses offsets from the start of the file, so keep track of what has been read
+		return decodeKTX2(&countingReader{r: r, n: int64(len(magic))})
+	}
+	return nil, errors.New("ktx: not a KTX file")
+}
+
+func decodeKTX1(r io.Reader) (*Image, error) {
+	var h ktx1Header
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return nil, fmt.Errorf("ktx: reading header: %v", err)
+	}
+	if h.Endianness != 0x04030201 {
+		return nil, errors.New("ktx: only little endian files are supported")
+	}
+	if h.PixelDepth > 1 || h.NumberOfArrayElements > 0 || h.NumberOfFaces > 1 {
+		return nil, errors.New("ktx: only 2D textures are supported")
+	}
+	f, ok := glFormats[h.GLInternalFormat]
+	if !ok {
+		return nil, fmt.Errorf("ktx: unsupported internal format 0x%x", h.GLInternalFormat)
+	}
+	img := &Image{
+		Format:   f.format,
+		SRGB:     f.srgb,
+		Width:    int(h.PixelWidth),
+		Height:   int(h.PixelHeight),
+		BottomUp: true,
+	}
+	levels := int(h.NumberOfMipmapLevels)
+	if levels == 0 {
+		levels = 1
+	}
+	if err := img.checkDimensions(levels); err != nil {
+		return nil, err
+	}
+
+	if h.BytesOfKeyValueData > maxKeyValueData {
+		return nil, errors.New("ktx: too much key value data")
+	}
+	kvd := make([]byte, h.BytesOfKeyValueData)
+	if _, err := io.ReadFull(r, kvd); err != nil {
+		return nil, fmt.Errorf("ktx: reading key value data: %v", err)
+	}
+	if orientation, ok := keyValues(kvd)["KTXorientation"]; ok {
+		img.BottomUp = !strings.Contains(orientation, "T=d")
+	}
+
+	for level := 0; level < levels; level++ {
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("ktx: reading mip level %d: %v", level, err)
+		}
+		w, h := img.LevelDimensions(level)
+		rowSize, paddedRowSize, rows := ktx1Rows(img.Format, w, h)
+		if int(size) != paddedRowSize*rows {
+			return nil, fmt.Errorf("ktx: mip level %d is %d bytes, expected %d", level, size, paddedRowSize*rows)
+		}
+		// levels are padded to 4 bytes
+		data := make([]byte, (size+3)&^3)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("ktx: reading mip level %d: %v", level, err)
+		}
+		if rowSize != paddedRowSize {
+			for y := 0; y < rows; y++ {
+				copy(data[y*rowSize:], data[y*paddedRowSize:y*paddedRowSize+rowSize])
+			}
+		}
+		img.Levels = append(img.Levels, data[:img.Format.LevelSize(w, h)])
+	}
+	return img, img.checkLevels()
+}
+
+// ktx1Rows returns the size of a row, its size in a KTX 1 file and the number of rows of a mip level. The rows of
+// uncompressed images are padded to 4 bytes in the file, rows of compressed blocks never need it.
+func ktx1Rows(f Format, width, height int) (int, int, int) {
+	if f.Compressed() {
+		size := f.LevelSize(width, 4)
+		return size, size, (height + 3) / 4
+	}
+	size := f.PixelSize() * width
+	return size, (size + 3) &^ 3, height
+}
+
+func decodeKTX2(r *countingReader) (*Image, error) {
+	var h ktx2Header
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return nil, fmt.Errorf("ktx: reading header: %v", err)
+	}
+	if h.PixelDepth > 1 || h.LayerCount > 1 || h.FaceCount > 1 {
+		return nil, errors.New("ktx: only 2D textures are supported")
+	}
+	if h.SupercompressionScheme != 0 {
+		return nil, fmt.Errorf("ktx: supercompression scheme %d is not supported", h.SupercompressionScheme)
+	}
+	f, ok := vkFormats[h.VkFormat]
+	if !ok {
+		return nil, fmt.Errorf("ktx: unsupported vkFormat %d", h.VkFormat)
+	}
+	img := &Image{
+		Format: f.format,
+		SRGB:   f.srgb,
+		Width:  int(h.PixelWidth),
+		Height: int(h.PixelHeight),
+	}
+	levels := int(h.LevelCount)
+	if levels == 0 {
+		levels = 1
+	}
+	if err := img.checkDimensions(levels); err != nil {
+		return nil, err
+	}
+
+	index := make([]ktx2Level, levels)
+	if err := binary.Read(r, binary.LittleEndian, index); err != nil {
+		return nil, fmt.Errorf("ktx: reading level index: %v", err)
+	}
+
+	// the sections are stored in the order data format descriptor, key value data, supercompression data, and
+	// the mip levels from the smallest to the largest
+	if h.KVDByteLength > maxKeyValueData {
+		return nil, errors.New("ktx: too much key value data")
+	}
+	if h.KVDByteLength > 0 {
+		if err := r.skipTo(int64(h.KVDByteOffset)); err != nil {
+			return nil, fmt.Errorf("ktx: seeking to key value data: %v", err)
+		}
+		kvd := make([]byte, h.KVDByteLength)
+		if _, err := io.ReadFull(r, kvd); err != nil {
+			return nil, fmt.Errorf("ktx: reading key value data: %v", err)
+		}
+		if orientation, ok := keyValues(kvd)["KTXorientation"]; ok {
+			img.BottomUp = len(orientation) > 1 && orientation[1] == 'u'
+		}
+	}
+
+	img.Levels = make([][]byte, levels)
+	for level := levels - 1; level >= 0; level-- {
+		w, h := img.LevelDimensions(level)
+		if int(index[level].ByteLength) != img.Format.LevelSize(w, h) {
+			return nil, fmt.Errorf("ktx: mip level %d is %d bytes, expected %d", level, index[level].ByteLength, img.Format.LevelSize(w, h))
+		}
+		if err := r.skipTo(int64(index[level].ByteOffset)); err != nil {
+			return nil, fmt.Errorf("ktx: seeking to mip level %d: %v", level, err)
+		}
+		img.Levels[level] = make([]byte, index[level].ByteLength)
+		if _, err := io.ReadFull(r, img.Levels[level]); err != nil {
+			return nil, fmt.Errorf("ktx: reading mip level %d: %v", level, err)
+		}
+	}
+	return img, img.checkLevels()
+}
+
+// keyValues parses the key value metadata, where every entry is a length followed by a null terminated key and the
+// value, padded to 4 bytes
+func keyValues(data []byte) map[string]string {
+	kv := make(map[string]string)
+	for len(data) >= 4 {
+		size := int(binary.LittleEndian.Uint32(data))
+		data = data[4:]
+		if size > len(data) {
+			break
+		}
+		entry := data[:size]
+		if i := strings.IndexByte(string(entry), 0); i >= 0 {
+			kv[string(entry[:i])] = strings.TrimRight(string(entry[i+1:]), "\x00")
+		}
+		padded := (size + 3) &^ 3
+		if padded > len(data) {
+			break
+		}
+		data = data[padded:]
+	}
+	return kv
+}
+
+// countingReader tracks the position in the stream so that sections can be found by their file offset
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// skipTo discards data up to the offset, it's an error to seek backwards
+func (c *countingReader) skipTo(offset int64) error {
+	if offset < c.n {
+		return fmt.Errorf("offset %d is before the current position %d", offset, c.n)
+	}
+	_, err := io.CopyN(io.Discard, c, offset-c.n)
+	return err
+}
+
+// ktxGLTypes are the glType, glTypeSize and glFormat written for uncompressed formats
+var ktxGLTypes = map[Format][3]uint32{
+	R8:      {0x1401, 1, 0x1903},
+	RG8:     {0x1401, 1, 0x8227},
+	RGBA8:   {0x1401, 1, 0x1908},
+	RGBA16F: {0x140B, 2, 0x1908},
+	RGBA32F: {0x1406, 4, 0x1908},
+}
+
+// EncodeKTX writes the image and its mip levels as a KTX 1 file, the orientation is recorded in the KTXorientation
+// metadata
+func EncodeKTX(w io.Writer, img *Image) error {
+	internalFormat, baseFormat := uint32(0), uint32(0)
+	for glFormat, f := range glFormats {
+		// pick the lowest matching enum, so that BC1 is written as RGB rather than RGBA with alpha
+		if f.format == img.Format && f.srgb == img.SRGB && (internalFormat == 0 || glFormat < internalFormat) {
+			internalFormat = glFormat
+		}
+	}
+	if internalFormat == 0 {
+		return fmt.Errorf("ktx: can't write %s images", img.Format)
+	}
+	if err := img.checkDimensions(len(img.Levels)); err != nil {
+		return err
+	}
+	if err := img.checkLevels(); err != nil {
+		return err
+	}
+
+	header := ktx1Header{
+		Endianness:           0x04030201,
+		GLTypeSize:           1,
+		GLInternalFormat:     internalFormat,
+		PixelWidth:           uint32(img.Width),
+		PixelHeight:          uint32(img.Height),
+		NumberOfFaces:        1,
+		NumberOfMipmapLevels: uint32(len(img.Levels)),
+	}
+	if t, ok := ktxGLTypes[img.Format]; ok {
+		header.GLType, header.GLTypeSize, header.GLFormat = t[0], t[1], t[2]
+		baseFormat = t[2]
+	} else {
+		// the base format of compressed textures is the number of channels the blocks decode to
+		switch img.Format {
+		case BC5:
+			baseFormat = 0x8227
+		case BC1:
+			baseFormat = 0x1907
+		default:
+			baseFormat = 0x1908
+		}
+	}
+	header.GLBaseInternalFormat = baseFormat
+
+	orientation := "S=r,T=d"
+	if img.BottomUp {
+		orientation = "S=r,T=u"
+	}
+	entry := append([]byte("KTXorientation\x00"+orientation), 0)
+	kvd := make([]byte, 4, 4+len(entry)+3)
+	binary.LittleEndian.PutUint32(kvd, uint32(len(entry)))
+	kvd = append(kvd, entry...)
+	for len(kvd)%4 != 0 {
+		kvd = append(kvd, 0)
+	}
+	header.BytesOfKeyValueData = uint32(len(kvd))
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(ktx1Magic)
+	binary.Write(bw, binary.LittleEndian, header)
+	bw.Write(kvd)
+	var padding [3]byte
+	for level, data := range img.Levels {
+		width, height := img.LevelDimensions(level)
+		rowSize, paddedRowSize, rows := ktx1Rows(img.Format, width, height)
+		size := rows * paddedRowSize
+		binary.Write(bw, binary.LittleEndian, uint32(size))
+		for len(data) > 0 {
+			bw.Write(data[:rowSize])
+			bw.Write(padding[:paddedRowSize-rowSize])
+			data = data[rowSize:]
+		}
+		bw.Write(padding[:(4-size%4)%4])
+	}
+	return bw.Flush()
+}
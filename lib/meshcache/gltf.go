@@ -0,0 +1,151 @@
+package meshcache
+
+import (
+	"fmt"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stojg/cspace/lib/geom"
+	"github.com/stojg/cspace/lib/gltf"
+	"github.com/stojg/cspace/lib/obj"
+)
+
+// FromGLTF flattens the default scene of a glTF document into one mesh per primitive of each node. The cache has no
+// node hierarchy, so the node transforms are baked into the vertices. Materials keep their factors, and the base
+// colour, normal and emissive textures when they are image files of their own that use the first UV set. Embedded
+// images, the packed metallic-roughness and occlusion textures and the second UV set can't be referenced from the
+// cache and are left out, load the glTF file itself to keep them.
+func FromGLTF(doc *gltf.Document) ([]*Mesh, error) {
+	f := &gltfFlattener{doc: doc, visited: make(map[int]bool)}
+	for _, root := range doc.RootNodes() {
+		if err := f.node(root, mgl32.Ident4()); err != nil {
+			return nil, err
+		}
+	}
+	return f.meshes, nil
+}
+
+type gltfFlattener struct {
+	doc     *gltf.Document
+	visited map[int]bool
+	meshes  []*Mesh
+}
+
+func (f *gltfFlattener) node(index int, parent mgl32.Mat4) error {
+	if index < 0 || index >= len(f.doc.Nodes) {
+		return fmt.Errorf("node %d does not exist", index)
+	}
+	if f.visited[index] {
+		return fmt.Errorf("node %d is part of a cycle", index)
+	}
+	f.visited[index] = true
+	defer delete(f.visited, index)
+
+	node := f.doc.Nodes[index]
+	world := parent.Mul4(mgl32.Mat4(node.LocalTransform()))
+	if node.Mesh != nil {
+		if *node.Mesh < 0 || *node.Mesh >= len(f.doc.Meshes) {
+			return fmt.Errorf("mesh %d does not exist", *node.Mesh)
+		}
+		mesh := f.doc.Meshes[*node.Mesh]
+		for i, p := range mesh.Primitives {
+			name := fmt.Sprintf("%s_%d", mesh.Name, i)
+			vertices, indices, _, err := f.doc.PrimitiveVertices(p)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			mat, err := f.material(p, name)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			transform(vertices, indices, world)
+			f.meshes = append(f.meshes, &Mesh{
+				Name:      name,
+				Primitive: obj.Triangles,
+				Vertices:  vertices,
+				Indices:   indices,
+				Material:  mat,
+				Bounds:    geom.BoundingVolumeOf(vertices),
+			})
+		}
+	}
+	for _, child := range node.Children {
+		if err := f.node(child, world); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// material maps the glTF material of the primitive onto the OBJ material that the cache stores
+func (f *gltfFlattener) material(p gltf.Primitive, name string) (*obj.Material, error) {
+	mat := obj.NewMaterial()
+	mat.Name = name
+	if p.Material == nil {
+		return mat, nil
+	}
+	if *p.Material < 0 || *p.Material >= len(f.doc.Materials) {
+		return nil, fmt.Errorf("material %d does not exist", *p.Material)
+	}
+	m := f.doc.Materials[*p.Material]
+	if m.Name != "" {
+		mat.Name = m.Name
+	}
+	baseColor := m.BaseColor()
+	mat.Diffuse = [3]float32{baseColor[0], baseColor[1], baseColor[2]}
+	mat.Transparency = baseColor[3]
+	mat.Metallic = m.Metallic()
+	mat.Roughness = m.Roughness()
+	mat.Emissive = m.EmissiveFactor
+	if m.PBRMetallicRoughness != nil {
+		mat.DiffuseMap = f.textureFile(m.PBRMetallicRoughness.BaseColorTexture)
+	}
+	mat.NormalMap = f.textureFile(m.NormalTexture)
+	mat.EmissiveMap = f.textureFile(m.EmissiveTexture)
+	return mat, nil
+}
+
+// textureFile returns the image file of a texture that uses the first UV set, or an empty string
+func (f *gltfFlattener) textureFile(info *gltf.TextureInfo) string {
+	if info == nil || info.TexCoord != 0 {
+		return ""
+	}
+	file, _ := f.doc.TextureFile(info.Index)
+	return file
+}
+
+// transform moves the vertices from the space of the mesh into the space of the scene. A mirroring transform also
+// flips the winding of the triangles and the handedness of the tangents.
+func transform(vertices []geom.Vertex, indices []uint32, m mgl32.Mat4) {
+	if m == mgl32.Ident4() {
+		return
+	}
+	linear := m.Mat3()
+	normalMatrix := linear.Inv().Transpose()
+	mirrored := linear.Det() < 0
+	for i := range vertices {
+		v := &vertices[i]
+		p := m.Mul4x1(mgl32.Vec3(v.Position).Vec4(1))
+		v.Position = [3]float32{p[0], p[1], p[2]}
+		n := normalMatrix.Mul3x1(v.Normal)
+		if n.Len() > 0 {
+			n = n.Normalize()
+		}
+		v.Normal = n
+		// keep the tangent perpendicular to the normal when the transform doesn't scale uniformly
+		t := linear.Mul3x1(mgl32.Vec3{v.Tangent[0], v.Tangent[1], v.Tangent[2]})
+		t = t.Sub(n.Mul(n.Dot(t)))
+		if t.Len() > 0 {
+			t = t.Normalize()
+		}
+		w := v.Tangent[3]
+		if mirrored {
+			w = -w
+		}
+		v.Tangent = [4]float32{t[0], t[1], t[2], w}
+	}
+	if mirrored {
+		for i := 0; i+2 < len(indices); i += 3 {
+			indices[i+1], indices[i+2] = indices[i+2], indices[i+1]
+		}
+	}
+}
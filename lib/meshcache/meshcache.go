@@ -0,0 +1,315 @@
+package meshcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/stojg/cspace/lib/geom"
+	"github.com/stojg/cspace/lib/obj"
+)
+
+// Extension is the file extension of cached meshes
+const Extension = ".mesh"
+
+const (
+	magic   = "CSPM"
+	version = 6
+)
+
+// Mesh is the processed geometry and material reference for one object, ready to be uploaded
+type Mesh struct {
+	Name      string
+	Primitive obj.Primitive
+	Vertices  []geom.Vertex
+	Indices   []uint32
+	Material  *obj.Material
+	// Bounds are around the vertices, so that they don't have to be calculated when the mesh is loaded
+	Bounds geom.BoundingVolume
+}
+
+// FromObjects calculates tangents for and indexes the objects loaded from an OBJ file
+func FromObjects(objects []*obj.Object) ([]*Mesh, error) {
+	var result []*Mesh
+	for _, object := range objects {
+		var vertices []geom.Vertex
+		var err error
+		if object.Primitive == obj.Triangles {
+			vertices, err = geom.Triangles(object.Data)
+		} else {
+			vertices, err = geom.Vertices(object.Data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", object.Name, err)
+		}
+		m := &Mesh{
+			Name:      object.Name,
+			Primitive: object.Primitive,
+			Material:  object.Mtr,
+		}
+		if object.Colors != nil {
+			if err := geom.SetColors(vertices, object.Colors); err != nil {
+				return nil, fmt.Errorf("%s: %v", object.Name, err)
+			}
+		}
+		m.Vertices, m.Indices = geom.Index(vertices)
+		m.Bounds = geom.BoundingVolumeOf(m.Vertices)
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// IsFresh returns true if the cache file exists and is newer than all of the source files
+func IsFresh(cache string, sources ...string) bool {
+	return isFresh(os.Stat, cache, sources...)
+}
+
+// IsFreshFS is IsFresh for files in fsys. Files without a modification time, like the ones in an embed.FS, are
+// considered to be as old as each other.
+func IsFreshFS(fsys fs.FS, cache string, sources ...string) bool {
+	return isFresh(func(name string) (fs.FileInfo, error) { return fs.Stat(fsys, name) }, cache, sources...)
+}
+
+func isFresh(stat func(string) (fs.FileInfo, error), cache string, sources ...string) bool {
+	info, err := stat(cache)
+	if err != nil {
+		return false
+	}
+	for _, source := range sources {
+		sourceInfo, err := stat(source)
+		if err == nil && sourceInfo.ModTime().After(info.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteFile writes the meshes into a cache file, texture map paths are stored relative to the cache file
+func WriteFile(path string, meshes []*Mesh) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := Encode(w, meshes, filepath.Dir(path)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFile reads the meshes from a cache file
+func ReadFile(path string) ([]*Mesh, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{r: bytes.NewReader(data), dir: filepath.Dir(path), join: filepath.Join}
+	meshes, err := d.decode()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return meshes, nil
+}
+
+// ReadFileFS reads the meshes from a cache file in fsys, texture map paths are resolved to paths in fsys
+func ReadFileFS(fsys fs.FS, name string) ([]*Mesh, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{r: bytes.NewReader(data), dir: path.Dir(name), join: path.Join}
+	meshes, err := d.decode()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return meshes, nil
+}
+
+// Encode writes the meshes in the binary cache format. Texture map paths are made relative to dir.
+func Encode(w io.Writer, meshes []*Mesh, dir string) error {
+	e := &encoder{w: w, dir: dir}
+	e.write([]byte(magic))
+	e.write(uint32(version))
+	e.write(uint32(len(meshes)))
+	for _, m := range meshes {
+		e.string(m.Name)
+		e.write(uint32(m.Primitive))
+		e.material(m.Material)
+		e.write(m.Bounds)
+		e.write(uint32(len(m.Vertices)))
+		e.write(m.Vertices)
+		e.write(uint32(len(m.Indices)))
+		e.write(m.Indices)
+	}
+	return e.err
+}
+
+// Decode reads meshes in the binary cache format. Texture map paths are resolved against dir.
+func Decode(r io.Reader, dir string) ([]*Mesh, error) {
+	// the whole file is read, so that lengths can be checked against what's left of it before allocating
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{r: bytes.NewReader(data), dir: dir, join: filepath.Join}
+	return d.decode()
+}
+
+func (d *decoder) decode() ([]*Mesh, error) {
+	header := make([]byte, len(magic))
+	d.read(header)
+	if d.err == nil && string(header) != magic {
+		return nil, errors.New("not a mesh cache file")
+	}
+	var v, count uint32
+	d.read(&v)
+	if d.err == nil && v != version {
+		return nil, fmt.Errorf("unsupported mesh cache version %d", v)
+	}
+	d.read(&count)
+
+	var meshes []*Mesh
+	for i := uint32(0); i < count && d.err == nil; i++ {
+		m := &Mesh{}
+		m.Name = d.string()
+		var primitive uint32
+		d.read(&primitive)
+		m.Primitive = obj.Primitive(primitive)
+		if d.err == nil && m.Primitive != obj.Triangles && m.Primitive != obj.Lines && m.Primitive != obj.Points {
+			return nil, fmt.Errorf("%s: unknown primitive %d", m.Name, primitive)
+		}
+		m.Material = d.material()
+		d.read(&m.Bounds)
+		m.Vertices = make([]geom.Vertex, d.length(vertexSize))
+		d.read(m.Vertices)
+		m.Indices = make([]uint32, d.length(4))
+		d.read(m.Indices)
+		if d.err != nil {
+			break
+		}
+		for _, index := range m.Indices {
+			if int(index) >= len(m.Vertices) {
+				return nil, fmt.Errorf("%s: index %d is out of range for %d vertices", m.Name, index, len(m.Vertices))
+			}
+		}
+		meshes = append(meshes, m)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return meshes, nil
+}
+
+// vertexSize is the number of bytes that a vertex takes up in the file
+var vertexSize = binary.Size(geom.Vertex{})
+
+type encoder struct {
+	w   io.Writer
+	dir string
+	err error
+}
+
+func (e *encoder) write(data interface{}) {
+	if e.err == nil {
+		e.err = binary.Write(e.w, binary.LittleEndian, data)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.write(uint32(len(s)))
+	e.write([]byte(s))
+}
+
+func (e *encoder) path(p string) {
+	if p != "" {
+		dir, dirErr := filepath.Abs(e.dir)
+		abs, absErr := filepath.Abs(p)
+		if dirErr == nil && absErr == nil {
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				p = filepath.ToSlash(rel)
+			}
+		}
+	}
+	e.string(p)
+}
+
+func (e *encoder) material(m *obj.Material) {
+	if m == nil {
+		m = obj.NewMaterial()
+	}
+	e.string(m.Name)
+	e.write([][3]float32{m.Ambient, m.Diffuse, m.Specular, m.Emissive})
+	e.write([]float32{m.Transparency, m.SpecularExp, m.Roughness, m.Metallic})
+	for _, p := range []string{m.DiffuseMap, m.SpecularMap, m.NormalMap, m.RoughnessMap, m.MetallicMap, m.EmissiveMap, m.AOMap} {
+		e.path(p)
+	}
+}
+
+type decoder struct {
+	r   *bytes.Reader
+	dir string
+	// join is filepath.Join for files on disk and path.Join for files in an fs.FS
+	join func(elem ...string) string
+	err  error
+}
+
+func (d *decoder) read(data interface{}) {
+	if d.err == nil {
+		d.err = binary.Read(d.r, binary.LittleEndian, data)
+	}
+}
+
+// length reads the number of elements of size bytes that follow, a corrupt file can't ask for more than is left of it
+func (d *decoder) length(size int) int {
+	var l uint32
+	d.read(&l)
+	if d.err != nil {
+		return 0
+	}
+	if uint64(l)*uint64(size) > uint64(d.r.Len()) {
+		d.err = fmt.Errorf("length %d is larger than the rest of the file", l)
+		return 0
+	}
+	return int(l)
+}
+
+func (d *decoder) string() string {
+	b := make([]byte, d.length(1))
+	d.read(b)
+	return string(b)
+}
+
+func (d *decoder) path() string {
+	p := d.string()
+	if p == "" || filepath.IsAbs(p) || path.IsAbs(p) {
+		return p
+	}
+	return d.join(d.dir, p)
+}
+
+func (d *decoder) material() *obj.Material {
+	m := &obj.Material{}
+	m.Name = d.string()
+	colors := make([][3]float32, 4)
+	d.read(colors)
+	m.Ambient, m.Diffuse, m.Specular, m.Emissive = colors[0], colors[1], colors[2], colors[3]
+	scalars := make([]float32, 4)
+	d.read(scalars)
+	m.Transparency, m.SpecularExp, m.Roughness, m.Metallic = scalars[0], scalars[1], scalars[2], scalars[3]
+	for _, p := range []*string{&m.DiffuseMap, &m.SpecularMap, &m.NormalMap, &m.RoughnessMap, &m.MetallicMap, &m.EmissiveMap, &m.AOMap} {
+		*p = d.path()
+	}
+	return m
+}
@@ -0,0 +1,129 @@
+package meshcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stojg/cspace/lib/geom"
+	"github.com/stojg/cspace/lib/obj"
+)
+
+func testMeshes() []*Mesh {
+	mat := obj.NewMaterial()
+	mat.Name = "bricks"
+	mat.Metallic = 0.25
+	mat.DiffuseMap = filepath.Join("assets", "textures", "bricks.png")
+	mat.NormalMap = filepath.Join("assets", "textures", "bricks_normal.png")
+	vertices := []geom.Vertex{
+		{Position: [3]float32{0, 0, 0}, Normal: [3]float32{0, 0, 1}, TexCoords: [2]float32{0, 0}, Tangent: [4]float32{1, 0, 0, 1}},
+		{Position: [3]float32{1, 0, 0}, Normal: [3]float32{0, 0, 1}, TexCoords: [2]float32{1, 0}, Tangent: [4]float32{1, 0, 0, 1}},
+		{Position: [3]float32{0, 1, 0}, Normal: [3]float32{0, 0, 1}, TexCoords: [2]float32{0, 1}, Color: [4]float32{1, 0, 0, 1}},
+	}
+	return []*Mesh{
+		{
+			Name:      "wall",
+			Primitive: obj.Triangles,
+			Vertices:  vertices,
+			Indices:   []uint32{0, 1, 2},
+			Material:  mat,
+			Bounds:    geom.BoundingVolumeOf(vertices),
+		},
+		{
+			Name:      "edge",
+			Primitive: obj.Lines,
+			Vertices:  vertices[:2],
+			Indices:   []uint32{0, 1},
+			Material:  obj.NewMaterial(),
+			Bounds:    geom.BoundingVolumeOf(vertices[:2]),
+		},
+	}
+}
+
+func encode(t *testing.T, meshes []*Mesh) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, meshes, "assets"); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	meshes := testMeshes()
+	decoded, err := Decode(bytes.NewReader(encode(t, meshes)), "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, meshes) {
+		t.Errorf("got %+v, expected %+v", decoded, meshes)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	data := encode(t, testMeshes())
+	for _, n := range []int{0, 3, len(magic) + 4, len(data) / 2, len(data) - 1} {
+		if _, err := Decode(bytes.NewReader(data[:n]), "assets"); err == nil {
+			t.Errorf("%d of %d bytes: expected an error", n, len(data))
+		}
+	}
+}
+
+func TestDecodeBadIndex(t *testing.T) {
+	meshes := testMeshes()
+	meshes[0].Indices[2] = uint32(len(meshes[0].Vertices))
+	_, err := Decode(bytes.NewReader(encode(t, meshes)), "assets")
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("expected an out of range error, got %v", err)
+	}
+}
+
+func TestDecodeUnknownPrimitive(t *testing.T) {
+	meshes := testMeshes()
+	meshes[1].Primitive = obj.Points + 1
+	if _, err := Decode(bytes.NewReader(encode(t, meshes)), "assets"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestDecodeLengthLargerThanFile(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	// the version, one mesh and a name that is longer than the file
+	for _, v := range []uint32{version, 1, 1 << 30} {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := Decode(&buf, "assets")
+	if err == nil || !strings.Contains(err.Error(), "larger than the rest of the file") {
+		t.Errorf("expected a length error, got %v", err)
+	}
+}
+
+func TestIsFreshFS(t *testing.T) {
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		fresh bool
+	}{
+		{"cache newer", fstest.MapFS{"m.mesh": {ModTime: newer}, "m.obj": {ModTime: older}, "m.mtl": {ModTime: older}}, true},
+		{"source newer", fstest.MapFS{"m.mesh": {ModTime: older}, "m.obj": {ModTime: older}, "m.mtl": {ModTime: newer}}, false},
+		{"same time", fstest.MapFS{"m.mesh": {ModTime: older}, "m.obj": {ModTime: older}, "m.mtl": {ModTime: older}}, true},
+		{"no mod times", fstest.MapFS{"m.mesh": {}, "m.obj": {}, "m.mtl": {}}, true},
+		{"missing source", fstest.MapFS{"m.mesh": {ModTime: older}, "m.obj": {ModTime: older}}, true},
+		{"missing cache", fstest.MapFS{"m.obj": {ModTime: older}, "m.mtl": {ModTime: older}}, false},
+	}
+	for _, test := range tests {
+		if fresh := IsFreshFS(test.files, "m.mesh", "m.obj", "m.mtl"); fresh != test.fresh {
+			t.Errorf("%s: got %v, expected %v", test.name, fresh, test.fresh)
+		}
+	}
+}
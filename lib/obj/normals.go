@@ -0,0 +1,115 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/jonnenauha/obj-simplify/objectfile"
+	"github.com/stojg/cspace/lib/geom"
+)
+
+// face is a polygon of an object together with what's needed to work out its vertex normals
+type face struct {
+	decls []*objectfile.Declaration
+	// normal is the area weighted normal of the polygon
+	normal [3]float64
+	group  string
+}
+
+// faceNormals returns the normal of every corner of the faces of the object, indexed like VertexData and its
+// Declarations. Normals from the file are kept unless a crease angle is given, then all of them are recomputed.
+func faceNormals(object *objectfile.Object, groups smoothingGroups, creaseAngle float64) [][][3]float32 {
+	recompute := creaseAngle > 0
+	minCos := math.Cos(creaseAngle * math.Pi / 180)
+
+	faces := make([]*face, len(object.VertexData))
+	// the faces around each position, only needed for smooth normals
+	var around map[*objectfile.GeometryValue][]*face
+	for i, vert := range object.VertexData {
+		if vert.Type != objectfile.Face {
+			continue
+		}
+		f := &face{decls: vert.Declarations, normal: polygonNormal(vert.Declarations), group: groups[vert]}
+		faces[i] = f
+		if f.group == "" && !recompute {
+			continue
+		}
+		if around == nil {
+			around = make(map[*objectfile.GeometryValue][]*face)
+		}
+		for _, d := range f.decls {
+			around[d.RefVertex] = append(around[d.RefVertex], f)
+		}
+	}
+
+	result := make([][][3]float32, len(object.VertexData))
+	for i, f := range faces {
+		if f == nil {
+			continue
+		}
+		flat := normalise(f.normal)
+		result[i] = make([][3]float32, len(f.decls))
+		for j, d := range f.decls {
+			switch {
+			case d.RefNormal != nil && !recompute:
+				result[i][j] = [3]float32{float32(d.RefNormal.X), float32(d.RefNormal.Y), float32(d.RefNormal.Z)}
+			case f.group == "" && !recompute:
+				result[i][j] = toFloat32s(flat)
+			default:
+				result[i][j] = toFloat32s(smoothNormal(f, flat, around[d.RefVertex], recompute, minCos))
+			}
+		}
+	}
+	return result
+}
+
+// smoothNormal sums the normals of the faces around a corner that are in the same smoothing group, and within the
+// crease angle when one is given
+func smoothNormal(f *face, flat [3]float64, around []*face, crease bool, minCos float64) [3]float64 {
+	var sum [3]float64
+	for _, other := range around {
+		if other.group != f.group {
+			continue
+		}
+		if crease && other != f && dot(flat, normalise(other.normal)) < minCos {
+			continue
+		}
+		for k := range sum {
+			sum[k] += other.normal[k]
+		}
+	}
+	n := normalise(sum)
+	if n == [3]float64{} {
+		return flat
+	}
+	return n
+}
+
+// polygonNormal returns the normal of a polygon with Newell's method, its length is twice the area of the polygon
+func polygonNormal(decls []*objectfile.Declaration) [3]float64 {
+	return geom.NewellNormal(positions(decls))
+}
+
+func positions(decls []*objectfile.Declaration) [][3]float64 {
+	points := make([][3]float64, len(decls))
+	for i, d := range decls {
+		points[i] = [3]float64{d.RefVertex.X, d.RefVertex.Y, d.RefVertex.Z}
+	}
+	return points
+}
+
+// normalise returns the unit vector, or a zero vector for degenerate input
+func normalise(v [3]float64) [3]float64 {
+	l := math.Sqrt(dot(v, v))
+	if l == 0 || math.IsNaN(l) || math.IsInf(l, 0) {
+		return [3]float64{}
+	}
+	return [3]float64{v[0] / l, v[1] / l, v[2] / l}
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func toFloat32s(v [3]float64) [3]float32 {
+	return [3]float32{float32(v[0]), float32(v[1]), float32(v[2])}
+}
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime/debug"
 	"strconv"
@@ -27,6 +29,7 @@ func NewMaterial() *Material {
 		Specular:     [3]float32{0.5, 0.5, 0.5},
 		SpecularExp:  32,
 		Transparency: 1,
+		Roughness:    0.5,
 	}
 }
 
@@ -35,27 +38,110 @@ type Material struct {
 	Ambient      [3]float32
 	Diffuse      [3]float32
 	Specular     [3]float32
+	Emissive     [3]float32
 	Transparency float32
 	SpecularExp  float32
+	// PBR extensions
+	Roughness float32
+	Metallic  float32
+
+	// texture maps, relative paths are resolved against the material library directory
+	DiffuseMap   string
+	SpecularMap  string
+	NormalMap    string
+	RoughnessMap string
+	MetallicMap  string
+	EmissiveMap  string
+	AOMap        string
 }
 
+// Primitive is how the vertices of an object are put together
+type Primitive int
+
+const (
+	// Triangles are three vertices per triangle
+	Triangles Primitive = iota
+	// Lines are two vertices per line segment, polylines are split into segments
+	Lines
+	// Points are one vertex per point
+	Points
+)
+
+func (p Primitive) String() string {
+	switch p {
+	case Triangles:
+		return "triangles"
+	case Lines:
+		return "lines"
+	case Points:
+		return "points"
+	}
+	return fmt.Sprintf("Primitive(%d)", int(p))
+}
+
+// Object is the vertex data of one primitive type in an object or group of an OBJ file, [3]Pos, [3]Normals,
+// [2]TexCoords per vertex. Lines and points have zero normals.
 type Object struct {
 	Name string
 	Data []float32
-	Mtr  *Material
+	// Colors are the RGBA vertex colours, four per vertex in Data, from the "v x y z r g b" extension. It's nil when the
+	// file doesn't have any vertex colours, vertices without a colour in a file that has them are white.
+	Colors    []float32
+	Mtr       *Material
+	Primitive Primitive
 }
 
+// LoadObject will load and return the objects in an OBJ file and panic if the file could not be loaded
 func LoadObject(filename string) []*Object {
-	obj, num, err := ParseFile(filename)
+	objects, err := Load(filename)
 	if err != nil {
-		fmt.Println("error at object line: ", num)
 		panic(err)
 	}
+	return objects
+}
+
+// Options controls how the faces of an OBJ file are turned into triangles
+type Options struct {
+	// CreaseAngle, in degrees, recomputes the normals of every face when it's above zero. Neighbouring faces that
+	// meet at a sharper angle, or are in different smoothing groups, get a hard edge between them.
+	CreaseAngle float64
+}
+
+// Load parses an OBJ file and its material libraries into triangulated objects, polygons are split by ear clipping so
+// concave faces keep their shape. Faces without normals get flat normals, or smooth normals within their smoothing
+// group. The line and point elements of an object are returned as separate objects with the Lines and Points
+// primitive.
+func Load(filename string) ([]*Object, error) {
+	return LoadOptions(filename, Options{})
+}
+
+// LoadOptions is Load with options for how the faces are triangulated
+func LoadOptions(filename string, options Options) ([]*Object, error) {
+	objects, err := LoadFS(osFS{}, filepath.ToSlash(filename), options)
+	if err != nil {
+		return nil, err
+	}
+	localized := make(map[*Material]bool)
+	for _, object := range objects {
+		if !localized[object.Mtr] {
+			localized[object.Mtr] = true
+			localPaths(object.Mtr)
+		}
+	}
+	return objects, nil
+}
+
+// LoadFS is LoadOptions for a file in fsys, the material libraries and texture maps are resolved to paths in fsys
+func LoadFS(fsys fs.FS, filename string, options Options) ([]*Object, error) {
+	obj, ext, _, err := parseFile(fsys, filename)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
 
 	materials := make(map[string]*Material)
 
 	for _, mtrlib := range obj.MaterialLibraries {
-		ms, err := ParseMtr(filepath.Join(filepath.Dir(filename), mtrlib))
+		ms, err := ParseMtrFS(fsys, path.Join(path.Dir(filename), mtrlib))
 		if err != nil {
 			fmt.Printf("warning: material parse: %s\n", err)
 		}
@@ -67,16 +153,34 @@ func LoadObject(filename string) []*Object {
 	var objects []*Object
 
 	for _, object := range obj.Objects {
-		var data []float32
-		// convert the face data into actual data ready for openGL loading
 		for _, vert := range object.VertexData {
-			data = add(data, vert.Declarations[0])
-			data = add(data, vert.Declarations[1])
-			data = add(data, vert.Declarations[2])
-			for i := 3; i < len(vert.Declarations); i++ {
-				data = add(data, vert.Declarations[i-3])
-				data = add(data, vert.Declarations[i-1])
-				data = add(data, vert.Declarations[i])
+			if e, ok := elements[vert.Type]; ok && len(vert.Declarations) < e.min {
+				return nil, fmt.Errorf("%s: object %q has a %s with %d vertices", filename, object.Name, e.name, len(vert.Declarations))
+			}
+		}
+		normals := faceNormals(object, ext.groups, options.CreaseAngle)
+
+		faces, lines, points := ext.newBuilder(), ext.newBuilder(), ext.newBuilder()
+		// convert the element data into actual data ready for openGL loading
+		for i, vert := range object.VertexData {
+			d := vert.Declarations
+			switch vert.Type {
+			case objectfile.Face:
+				n := normals[i]
+				for _, t := range triangulate(positions(d)) {
+					faces.add(d[t[0]], n[t[0]])
+					faces.add(d[t[1]], n[t[1]])
+					faces.add(d[t[2]], n[t[2]])
+				}
+			case objectfile.Line:
+				for j := 1; j < len(d); j++ {
+					lines.add(d[j-1], [3]float32{})
+					lines.add(d[j], [3]float32{})
+				}
+			case objectfile.Point:
+				for j := range d {
+					points.add(d[j], [3]float32{})
+				}
 			}
 		}
 
@@ -85,24 +189,63 @@ func LoadObject(filename string) []*Object {
 			mat = NewMaterial()
 		}
 
-		objects = append(objects, &Object{
-			Name: object.Name,
-			Data: data,
-			Mtr:  mat,
-		})
+		for _, p := range []struct {
+			primitive Primitive
+			vertices  *builder
+		}{{Triangles, faces}, {Lines, lines}, {Points, points}} {
+			// an object with only lines or points shouldn't get an empty triangle mesh
+			if len(p.vertices.data) == 0 && (p.primitive != Triangles || len(lines.data)+len(points.data) > 0) {
+				continue
+			}
+			objects = append(objects, &Object{
+				Name:      object.Name,
+				Data:      p.vertices.data,
+				Colors:    p.vertices.colors,
+				Mtr:       mat,
+				Primitive: p.primitive,
+			})
+		}
 	}
-	return objects
+	return objects, nil
+}
+
+// elements are the names and the smallest number of vertices of the element types
+var elements = map[objectfile.Type]struct {
+	name string
+	min  int
+}{
+	objectfile.Face:  {"polygon", 3},
+	objectfile.Line:  {"line", 2},
+	objectfile.Point: {"point", 1},
+}
+
+// builder collects the vertex data of one primitive type
+type builder struct {
+	data []float32
+	// colors is only filled in when the file has vertex colours
+	colors       []float32
+	vertexColors map[*objectfile.GeometryValue][4]float32
+}
+
+func (e *extensions) newBuilder() *builder {
+	return &builder{vertexColors: e.colors}
 }
 
-func add(data []float32, in *objectfile.Declaration) []float32 {
-	data = appendValues(data, in.RefVertex, 3)
-	data = appendValues(data, in.RefNormal, 3)
+func (b *builder) add(in *objectfile.Declaration, normal [3]float32) {
+	b.data = appendValues(b.data, in.RefVertex, 3)
+	b.data = append(b.data, normal[:]...)
 	if in.RefUV != nil {
-		data = appendValues(data, in.RefUV, 2)
+		b.data = appendValues(b.data, in.RefUV, 2)
 	} else {
-		data = append(data, 0, 0)
+		b.data = append(b.data, 0, 0)
+	}
+	if len(b.vertexColors) > 0 {
+		color, ok := b.vertexColors[in.RefVertex]
+		if !ok {
+			color = [4]float32{1, 1, 1, 1}
+		}
+		b.colors = append(b.colors, color[:]...)
 	}
-	return data
 }
 
 func appendValues(data []float32, in *objectfile.GeometryValue, count int) []float32 {
@@ -110,17 +253,34 @@ func appendValues(data []float32, in *objectfile.GeometryValue, count int) []flo
 }
 
 func ParseFile(path string) (*objectfile.OBJ, int, error) {
-	f, err := os.Open(path)
+	obj, _, linenum, err := parseFile(osFS{}, filepath.ToSlash(path))
+	return obj, linenum, err
+}
+
+// smoothingGroups records the smoothing group of the faces that are in one
+type smoothingGroups map[*objectfile.VertexData]string
+
+// extensions is what's parsed from an OBJ file that objectfile doesn't keep track of
+type extensions struct {
+	groups smoothingGroups
+	// colors are the vertex colours of the positions that have one
+	colors map[*objectfile.GeometryValue][4]float32
+}
+
+func parseFile(fsys fs.FS, name string) (*objectfile.OBJ, *extensions, int, error) {
+	f, err := fsys.Open(name)
 	if err != nil {
-		return nil, -1, err
+		return nil, nil, -1, err
 	}
 	defer f.Close()
 	return parse(f)
 }
 
-func parse(src io.Reader) (*objectfile.OBJ, int, error) {
+func parse(src io.Reader) (*objectfile.OBJ, *extensions, int, error) {
 	dest := objectfile.NewOBJ()
 	geom := dest.Geometry
+	groups := make(smoothingGroups)
+	ext := &extensions{groups: groups, colors: make(map[*objectfile.GeometryValue][4]float32)}
 
 	scanner := bufio.NewScanner(src)
 	linenum := 0
@@ -165,7 +325,7 @@ func parse(src io.Reader) (*objectfile.OBJ, int, error) {
 		if linenum%1000000 == 0 {
 			rt := time.Now()
 			debug.FreeOSMemory()
-			fmt.Println("%s lines parsed - Forced GC took %s", rt)
+			fmt.Printf("%d lines parsed - Forced GC took %s\n", linenum, time.Since(rt))
 		}
 
 		switch t {
@@ -188,8 +348,21 @@ func parse(src io.Reader) (*objectfile.OBJ, int, error) {
 
 			// geometry
 		case objectfile.Vertex, objectfile.Normal, objectfile.UV, objectfile.Param:
-			if _, err := geom.ReadValue(t, value, true); err != nil {
-				return nil, linenum, wrapErrorLine(err, linenum)
+			var color []float32
+			if t == objectfile.Vertex {
+				var err error
+				if value, color, err = splitVertexColor(value); err != nil {
+					return nil, nil, linenum, wrapErrorLine(err, linenum)
+				}
+			}
+			gv, err := geom.ReadValue(t, value, true)
+			if err != nil {
+				return nil, nil, linenum, wrapErrorLine(err, linenum)
+			}
+			if color != nil {
+				c := [4]float32{1, 1, 1, 1}
+				copy(c[:], color)
+				ext.colors[gv] = c
 			}
 
 			// object, group
@@ -247,30 +420,50 @@ func parse(src io.Reader) (*objectfile.OBJ, int, error) {
 			}
 			vd, vdErr := currentObject.ReadVertexData(t, value, true)
 			if vdErr != nil {
-				return nil, linenum, wrapErrorLine(vdErr, linenum)
+				return nil, nil, linenum, wrapErrorLine(vdErr, linenum)
 			}
-			// attach current smooth group and reset it
+			// attach the current smooth group, it applies to every face until the next s statement
 			if len(currentSmoothGroup) > 0 {
 				vd.SetMeta(objectfile.SmoothingGroup, currentSmoothGroup)
-				currentSmoothGroup = ""
+				groups[vd] = currentSmoothGroup
 			}
 
 		case objectfile.SmoothingGroup:
 			// smooth group can change mid vertex data declaration
 			// so it is attched to the vertex data instead of current object directly
 			currentSmoothGroup = value
+			if value == "off" || value == "0" {
+				currentSmoothGroup = ""
+			}
 
 			// unknown
 		case objectfile.Unkown:
-			return nil, linenum, wrapErrorLine(fmt.Errorf("Unsupported line %q\n\nPlease submit a bug report. If you can, provide this file as an attachement.\n> %s\n", line, "https://github.com/jonnenauha/obj-simplify//issues"), linenum)
+			return nil, nil, linenum, wrapErrorLine(fmt.Errorf("Unsupported line %q\n\nPlease submit a bug report. If you can, provide this file as an attachement.\n> %s\n", line, "https://github.com/jonnenauha/obj-simplify//issues"), linenum)
 		default:
-			return nil, linenum, wrapErrorLine(fmt.Errorf("Unsupported line %q\n\nPlease submit a bug report. If you can, provide this file as an attachement.\n> %s\n", line, "https://github.com/jonnenauha/obj-simplify//issues"), linenum)
+			return nil, nil, linenum, wrapErrorLine(fmt.Errorf("Unsupported line %q\n\nPlease submit a bug report. If you can, provide this file as an attachement.\n> %s\n", line, "https://github.com/jonnenauha/obj-simplify//issues"), linenum)
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, linenum, err
+		return nil, nil, linenum, err
+	}
+	return dest, ext, linenum, nil
+}
+
+// splitVertexColor removes the colour from a vertex declared as "x y z r g b" or "x y z r g b a"
+func splitVertexColor(value string) (string, []float32, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 6 && len(fields) != 7 {
+		return value, nil, nil
+	}
+	color := make([]float32, len(fields)-3)
+	for i, f := range fields[3:] {
+		c, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("vertex colour: %v", err)
+		}
+		color[i] = float32(c)
 	}
-	return dest, linenum, nil
+	return strings.Join(fields[:3], " "), color, nil
 }
 
 func wrapErrorLine(err error, linenum int) error {
@@ -314,52 +507,169 @@ func strContainsAny(str string, parts []string, cs caseSensitivity) bool {
 }
 
 func ParseMtr(path string) (map[string]*Material, error) {
-	f, err := os.Open(path)
+	result, err := ParseMtrFS(osFS{}, filepath.ToSlash(path))
+	for _, m := range result {
+		localPaths(m)
+	}
+	return result, err
+}
+
+// ParseMtrFS parses a material library in fsys, relative texture map paths are resolved to paths in fsys
+func ParseMtrFS(fsys fs.FS, name string) (map[string]*Material, error) {
+	f, err := fsys.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	return parseMtr(f)
+	result, err := parseMtr(f)
+	if err != nil {
+		return result, err
+	}
+	dir := path.Dir(name)
+	for _, m := range result {
+		for _, file := range m.maps() {
+			if *file != "" && !path.IsAbs(*file) && !filepath.IsAbs(*file) {
+				*file = path.Join(dir, filepath.ToSlash(*file))
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *Material) maps() []*string {
+	return []*string{&m.DiffuseMap, &m.SpecularMap, &m.NormalMap, &m.RoughnessMap, &m.MetallicMap, &m.EmissiveMap, &m.AOMap}
+}
+
+// localPaths converts the texture map paths of a material loaded through osFS to the operating system's separators
+func localPaths(m *Material) {
+	for _, file := range m.maps() {
+		*file = filepath.FromSlash(*file)
+	}
+}
+
+// osFS opens slash separated paths, relative to the working directory or absolute, in the operating system's file
+// system. Unlike os.DirFS it accepts any path, so that the OS entry points can share the fs.FS code.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
 }
 
 func parseMtr(r io.Reader) (map[string]*Material, error) {
 	result := make(map[string]*Material)
-	current := ""
+	var current *Material
 	var f1, f2, f3 float32
-	reader := bufio.NewReader(r)
-	line, e1 := reader.ReadString('\n')
-	for ; e1 == nil; line, e1 = reader.ReadString('\n') {
-		tokens := strings.Split(line, " ")
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		tokens := strings.Fields(line)
+		if len(tokens) == 0 || strings.HasPrefix(tokens[0], "#") {
+			continue
+		}
+		if tokens[0] == "newmtl" {
+			if len(tokens) < 2 {
+				return result, fmt.Errorf("missing material name")
+			}
+			name := strings.Join(tokens[1:], " ")
+			current = &Material{
+				Name:      name,
+				Roughness: 0.5,
+			}
+			result[name] = current
+			continue
+		}
+		if current == nil {
+			return result, fmt.Errorf("%q declared before newmtl", tokens[0])
+		}
 		switch tokens[0] {
 		case "Ka": // ambient
 			if _, e := fmt.Sscanf(line, "Ka %f %f %f", &f1, &f2, &f3); e != nil {
 				return result, fmt.Errorf("could not parse ambient values %s", e)
 			}
-			result[current].Ambient = [3]float32{f1, f2, f3}
+			current.Ambient = [3]float32{f1, f2, f3}
 		case "Kd": // diffuse
 			if _, e := fmt.Sscanf(line, "Kd %f %f %f", &f1, &f2, &f3); e != nil {
 				return result, fmt.Errorf("could not parse diffuse values %s", e)
 			}
-			result[current].Diffuse = [3]float32{f1, f2, f3}
+			current.Diffuse = [3]float32{f1, f2, f3}
 		case "Ks": // specular
 			if _, e := fmt.Sscanf(line, "Ks %f %f %f", &f1, &f2, &f3); e != nil {
 				return result, fmt.Errorf("could not parse specular values %s", e)
 			}
-			result[current].Specular = [3]float32{f1, f2, f3}
+			current.Specular = [3]float32{f1, f2, f3}
+		case "Ke": // emissive
+			if _, e := fmt.Sscanf(line, "Ke %f %f %f", &f1, &f2, &f3); e != nil {
+				return result, fmt.Errorf("could not parse emissive values %s", e)
+			}
+			current.Emissive = [3]float32{f1, f2, f3}
 		case "d": // transparency
-			a, _ := strconv.ParseFloat(strings.TrimSpace(tokens[1]), 32)
-			result[current].Transparency = float32(a)
+			current.Transparency = parseScalar(tokens)
 		case "Ns": // specular exponent
-			ns, _ := strconv.ParseFloat(strings.TrimSpace(tokens[1]), 32)
-			result[current].SpecularExp = float32(ns)
-		case "newmtl": // material name
-			current = strings.TrimSpace(tokens[1])
-			result[current] = &Material{
-				Name: tokens[1],
-			}
+			current.SpecularExp = parseScalar(tokens)
+		case "Pr": // roughness
+			current.Roughness = parseScalar(tokens)
+		case "Pm": // metallic
+			current.Metallic = parseScalar(tokens)
+		case "map_Kd":
+			current.DiffuseMap = mapFile(tokens[1:])
+		case "map_Ks":
+			current.SpecularMap = mapFile(tokens[1:])
+		case "norm", "map_Bump", "map_bump", "bump":
+			current.NormalMap = mapFile(tokens[1:])
+		case "map_Pr":
+			current.RoughnessMap = mapFile(tokens[1:])
+		case "map_Pm":
+			current.MetallicMap = mapFile(tokens[1:])
+		case "map_Ke":
+			current.EmissiveMap = mapFile(tokens[1:])
+		case "map_ao": // baked ambient occlusion, not part of the MTL spec but written by some exporters
+			current.AOMap = mapFile(tokens[1:])
 		case "Ni": // optical density - scaler. Ignored for now.
 		case "illum": // illumination model - int. Ignored for now.
 		}
 	}
-	return result, nil
+	return result, scanner.Err()
+}
+
+func parseScalar(tokens []string) float32 {
+	if len(tokens) < 2 {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(tokens[1], 32)
+	return float32(v)
+}
+
+// mtlOptionArgs is the number of arguments each texture map option takes, -o, -s and -t takes one to three numbers
+var mtlOptionArgs = map[string]int{
+	"-blendu":  1,
+	"-blendv":  1,
+	"-boost":   1,
+	"-bm":      1,
+	"-cc":      1,
+	"-clamp":   1,
+	"-imfchan": 1,
+	"-texres":  1,
+	"-type":    1,
+	"-mm":      2,
+	"-o":       3,
+	"-s":       3,
+	"-t":       3,
+}
+
+// mapFile skips the options in a texture map statement, e.g. `map_Bump -bm 0.5 normal.png` and returns the file name
+func mapFile(args []string) string {
+	for len(args) > 0 {
+		num, isOption := mtlOptionArgs[args[0]]
+		if !isOption {
+			break
+		}
+		args = args[1:]
+		for i := 0; i < num && len(args) > 1; i++ {
+			if _, err := strconv.ParseFloat(args[0], 32); num == 3 && i > 0 && err != nil {
+				break
+			}
+			args = args[1:]
+		}
+	}
+	return strings.Join(args, " ")
 }
@@ -0,0 +1,103 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/stojg/cspace/lib/geom"
+)
+
+// triangulate splits a simple polygon, that may be concave, into triangles by projecting it onto its plane and
+// clipping ears. It returns indices into points, the triangles keep the winding of the polygon.
+func triangulate(points [][3]float64) [][3]int {
+	n := len(points)
+	if n < 3 {
+		return nil
+	}
+	if n == 3 {
+		return [][3]int{{0, 1, 2}}
+	}
+
+	// project onto the plane of the largest component of the normal, flipping an axis when needed so that the
+	// polygon is counter clockwise in 2D
+	normal := geom.NewellNormal(points)
+	axis := 0
+	for i := 1; i < 3; i++ {
+		if math.Abs(normal[i]) > math.Abs(normal[axis]) {
+			axis = i
+		}
+	}
+	u, v := (axis+1)%3, (axis+2)%3
+	flip := normal[axis] < 0
+	projected := make([][2]float64, n)
+	for i, p := range points {
+		projected[i] = [2]float64{p[u], p[v]}
+		if flip {
+			projected[i][1] = -p[v]
+		}
+	}
+
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	triangles := make([][3]int, 0, n-2)
+	for len(remaining) > 3 {
+		ear := findEar(projected, remaining, false)
+		if ear < 0 {
+			// only collinear or overlapping corners are left, clip a flat one so that the rest can make progress
+			ear = findEar(projected, remaining, true)
+		}
+		if ear < 0 {
+			break
+		}
+		m := len(remaining)
+		triangles = append(triangles, [3]int{remaining[(ear+m-1)%m], remaining[ear], remaining[(ear+1)%m]})
+		remaining = append(remaining[:ear], remaining[ear+1:]...)
+	}
+	// a self intersecting polygon can leave corners that aren't ears, fall back to a fan for those
+	for i := 1; i+1 < len(remaining); i++ {
+		triangles = append(triangles, [3]int{remaining[0], remaining[i], remaining[i+1]})
+	}
+	return triangles
+}
+
+// findEar returns the position in remaining of a corner that can be clipped, or -1. A corner is an ear when it's
+// convex and no other corner lies inside the triangle it forms with its neighbours. With flat set, corners whose
+// triangle has no area are accepted too.
+func findEar(points [][2]float64, remaining []int, flat bool) int {
+	m := len(remaining)
+	for i := range remaining {
+		a, b, c := points[remaining[(i+m-1)%m]], points[remaining[i]], points[remaining[(i+1)%m]]
+		area := cross2(a, b, c)
+		if area < 0 || (area == 0 && !flat) {
+			continue
+		}
+		if area == 0 {
+			return i
+		}
+		inside := false
+		for j := range remaining {
+			if j == i || j == (i+m-1)%m || j == (i+1)%m {
+				continue
+			}
+			p := points[remaining[j]]
+			// corners that coincide with the triangle's own corners don't block it
+			if p == a || p == b || p == c {
+				continue
+			}
+			if cross2(a, b, p) >= 0 && cross2(b, c, p) >= 0 && cross2(c, a, p) >= 0 {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return i
+		}
+	}
+	return -1
+}
+
+// cross2 is twice the signed area of the triangle a, b, c, positive when it's counter clockwise
+func cross2(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
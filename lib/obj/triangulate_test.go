@@ -0,0 +1,150 @@
+package obj
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stojg/cspace/lib/geom"
+)
+
+// polygons in the XY plane, counter clockwise
+var concavePolygons = map[string][][2]float64{
+	"L":       {{0, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 3}, {0, 3}},
+	"chevron": {{0, 0}, {2, 1}, {4, 0}, {2, 3}},
+	"arrow":   {{0, 1}, {3, 1}, {3, 0}, {5, 2}, {3, 4}, {3, 3}, {0, 3}},
+	"comb":    {{0, 0}, {5, 0}, {5, 3}, {4, 3}, {4, 1}, {3, 1}, {3, 3}, {2, 3}, {2, 1}, {1, 1}, {1, 3}, {0, 3}},
+	"star": {
+		{0, 3}, {-0.7, 1}, {-2.9, 0.9}, {-1.1, -0.4}, {-1.8, -2.4},
+		{0, -1.2}, {1.8, -2.4}, {1.1, -0.4}, {2.9, 0.9}, {0.7, 1},
+	},
+	"reflex start": {{1, 1}, {0, 3}, {0, 0}, {3, 0}, {3, 3}},
+	"collinear":    {{0, 0}, {1, 0}, {2, 0}, {2, 2}, {1, 1}, {0, 2}},
+}
+
+func TestTriangulateConcave(t *testing.T) {
+	for name, polygon := range concavePolygons {
+		t.Run(name, func(t *testing.T) {
+			checkTriangulation(t, polygon, embed(polygon, func(p [2]float64) [3]float64 { return [3]float64{p[0], p[1], 0} }))
+		})
+	}
+}
+
+// TestTriangulateOrientation places the polygons in other planes and reverses them, the triangles should keep the
+// winding of the polygon in 3D
+func TestTriangulateOrientation(t *testing.T) {
+	planes := map[string]func(p [2]float64) [3]float64{
+		"xz":     func(p [2]float64) [3]float64 { return [3]float64{p[0], 2, p[1]} },
+		"yz":     func(p [2]float64) [3]float64 { return [3]float64{-1, p[0], p[1]} },
+		"tilted": func(p [2]float64) [3]float64 { return [3]float64{p[0], p[1] * 0.6, p[1]*0.8 + p[0]*0.1} },
+	}
+	for name, polygon := range concavePolygons {
+		for plane, to3D := range planes {
+			for _, reversed := range []bool{false, true} {
+				p := polygon
+				if reversed {
+					p = reverse(polygon)
+				}
+				t.Run(fmt.Sprintf("%s %s reversed=%v", name, plane, reversed), func(t *testing.T) {
+					points := embed(p, to3D)
+					triangles := checkTriangulation(t, p, points)
+					want := normalise(geom.NewellNormal(points))
+					for _, tri := range triangles {
+						n := geom.NewellNormal([][3]float64{points[tri[0]], points[tri[1]], points[tri[2]]})
+						if dot(n, want) <= 0 {
+							t.Errorf("triangle %v faces %v, the polygon faces %v", tri, n, want)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestTriangulateSmall(t *testing.T) {
+	if got := triangulate([][3]float64{{0, 0, 0}, {1, 0, 0}}); len(got) != 0 {
+		t.Errorf("two points gave %v", got)
+	}
+	if got := triangulate([][3]float64{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}); len(got) != 1 || got[0] != [3]int{0, 1, 2} {
+		t.Errorf("a triangle gave %v", got)
+	}
+}
+
+// checkTriangulation triangulates the polygon and checks that every corner is used, that the triangles have the
+// winding of the polygon in its own 2D space and that they cover exactly the polygon
+func checkTriangulation(t *testing.T, polygon [][2]float64, points [][3]float64) [][3]int {
+	t.Helper()
+	triangles := triangulate(points)
+	if len(triangles) != len(polygon)-2 {
+		t.Fatalf("got %d triangles, want %d: %v", len(triangles), len(polygon)-2, triangles)
+	}
+
+	used := make([]bool, len(polygon))
+	sign := math.Copysign(1, shoelace(polygon))
+	var sum float64
+	for _, tri := range triangles {
+		for _, i := range tri {
+			if i < 0 || i >= len(polygon) {
+				t.Fatalf("index %d out of range in %v", i, tri)
+			}
+			used[i] = true
+		}
+		a, b, c := polygon[tri[0]], polygon[tri[1]], polygon[tri[2]]
+		area := cross2(a, b, c) / 2
+		if area*sign < 0 {
+			t.Errorf("triangle %v is wound against the polygon", tri)
+		}
+		sum += math.Abs(area)
+		centroid := [2]float64{(a[0] + b[0] + c[0]) / 3, (a[1] + b[1] + c[1]) / 3}
+		if area != 0 && !inside(polygon, centroid) {
+			t.Errorf("triangle %v is outside the polygon", tri)
+		}
+	}
+	for i, ok := range used {
+		if !ok {
+			t.Errorf("corner %d isn't used", i)
+		}
+	}
+	if want := math.Abs(shoelace(polygon)); math.Abs(sum-want) > 1e-9 {
+		t.Errorf("the triangles cover %g, the polygon %g", sum, want)
+	}
+	return triangles
+}
+
+func embed(polygon [][2]float64, to3D func(p [2]float64) [3]float64) [][3]float64 {
+	points := make([][3]float64, len(polygon))
+	for i, p := range polygon {
+		points[i] = to3D(p)
+	}
+	return points
+}
+
+func reverse(polygon [][2]float64) [][2]float64 {
+	r := make([][2]float64, len(polygon))
+	for i, p := range polygon {
+		r[len(polygon)-1-i] = p
+	}
+	return r
+}
+
+// shoelace returns the signed area of the polygon
+func shoelace(polygon [][2]float64) float64 {
+	var area float64
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+	return area / 2
+}
+
+// inside tests if p is inside the polygon by counting edge crossings
+func inside(polygon [][2]float64, p [2]float64) bool {
+	in := false
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < a[0]+(p[1]-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+			in = !in
+		}
+	}
+	return in
+}
@@ -0,0 +1,134 @@
+// Package overlayfs stacks file systems on top of each other, so that files in the upper layers replace the files
+// with the same name in the lower ones.
+package overlayfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// FS is a read only union of file systems, a file is opened from the first layer that has it and the entries of a
+// directory are merged from every layer
+type FS struct {
+	layers []fs.FS
+}
+
+// New returns the union of the layers, the first layer is the top one. Nil layers are skipped.
+func New(layers ...fs.FS) *FS {
+	o := &FS{}
+	for _, layer := range layers {
+		if layer != nil {
+			o.layers = append(o.layers, layer)
+		}
+	}
+	return o
+}
+
+// Open opens the named file from the top most layer that has it, directories list the merged entries of every layer
+func (o *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, layer := range o.layers {
+		f, err := layer.Open(name)
+		if err == nil {
+			if info, err := f.Stat(); err == nil && info.IsDir() {
+				return &dir{File: f, fsys: o, name: name}, nil
+			}
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Stat returns the file info of the named file from the top most layer that has it
+func (o *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, layer := range o.layers {
+		info, err := fs.Stat(layer, name)
+		if err == nil {
+			return info, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir returns the entries of the named directory in every layer sorted by name, an entry in an upper layer hides
+// the entries with the same name below it
+func (o *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	var firstErr error
+	found := false
+	for _, layer := range o.layers {
+		layerEntries, err := fs.ReadDir(layer, name)
+		if err != nil {
+			// the directory might only exist in some of the layers, or be a file in one of them
+			if firstErr == nil && !errors.Is(err, fs.ErrNotExist) {
+				firstErr = err
+			}
+			continue
+		}
+		found = true
+		for _, entry := range layerEntries {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if !found {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// dir is a directory opened from one of the layers, that lists the entries of all of them
+type dir struct {
+	fs.File
+	fsys    *FS
+	name    string
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -9,32 +9,102 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"strconv"
+	"strings"
 )
 
+// Decode reads a Radiance RGBE image and returns the width, height and the RGB float pixels, starting at the top left
+// corner. Images stored in any of the eight orientations are flipped and transposed into that layout and pixels are
+// divided by the EXPOSURE declared in the header.
 func Decode(r io.Reader) (int, int, []float32, error) {
 	br := bufio.NewReader(r)
 
-	width, height, err := readHeader(br)
+	h, err := readHeader(br)
 	if err != nil {
 		return 0, 0, nil, err
 	}
-	data := make([]float32, width*height*3)
-	if err := readPixels_RLE(br, width, height, data); err != nil {
+	data := make([]float32, h.scanlines*h.scanlineWidth*3)
+	if err := readPixels_RLE(br, h.scanlineWidth, h.scanlines, data); err != nil {
 		return 0, 0, nil, err
 	}
 
-	return width, height, data, nil
+	if h.exposure != 1 {
+		for i := range data {
+			data[i] /= h.exposure
+		}
+	}
+
+	width, height := h.size()
+	return width, height, h.orient(data), nil
+}
+
+// header describes how the scanlines are stored, the resolution string "-Y 512 +X 1024" means 512 scanlines that
+// runs from the top to the bottom, each with 1024 pixels stored left to right
+type header struct {
+	major, minor  axis
+	scanlines     int
+	scanlineWidth int
+	exposure      float32
+}
+
+type axis struct {
+	name     byte // 'X' or 'Y'
+	negative bool
+}
+
+// size returns the width and height of the image
+func (h header) size() (int, int) {
+	if h.major.name == 'Y' {
+		return h.scanlineWidth, h.scanlines
+	}
+	return h.scanlines, h.scanlineWidth
+}
+
+// standard returns true if the pixels already are stored top to bottom, left to right
+func (h header) standard() bool {
+	return h.major == axis{'Y', true} && h.minor == axis{'X', false}
+}
+
+// orient moves the pixels from the stored order into the top to bottom, left to right order
+func (h header) orient(src []float32) []float32 {
+	if h.standard() {
+		return src
+	}
+	width, height := h.size()
+	dst := make([]float32, len(src))
+	for s := 0; s < h.scanlines; s++ {
+		for p := 0; p < h.scanlineWidth; p++ {
+			var x, y int
+			if h.major.name == 'Y' {
+				y, x = position(h.major, s, height), position(h.minor, p, width)
+			} else {
+				x, y = position(h.major, s, width), position(h.minor, p, height)
+			}
+			copy(dst[(y*width+x)*3:(y*width+x)*3+3], src[(s*h.scanlineWidth+p)*3:(s*h.scanlineWidth+p)*3+3])
+		}
+	}
+	return dst
 }
 
-func readHeader(r *bufio.Reader) (int, int, error) {
+// position converts the i'th step along an axis into an image coordinate, X grows to the right and Y grows downwards
+func position(a axis, i, size int) int {
+	// -Y runs from the top, +Y from the bottom, +X from the left and -X from the right
+	if a.negative == (a.name == 'Y') {
+		return i
+	}
+	return size - 1 - i
+}
+
+func readHeader(r *bufio.Reader) (header, error) {
+	h := header{exposure: 1}
 	line, err := r.ReadString('\n')
 
 	if err != nil {
-		return 0, 0, newError(ReadError, err.Error())
+		return h, newError(ReadError, err.Error())
 	}
 
-	if line[0] != '#' || line[1] != '?' {
-		return 0, 0, newError(FormatError, "Bad initial token.")
+	if len(line) < 2 || line[0] != '#' || line[1] != '?' {
+		return h, newError(FormatError, "Bad initial token.")
 	}
 
 	formatSpecifier := false
@@ -43,33 +113,56 @@ func readHeader(r *bufio.Reader) (int, int, error) {
 		line, err = r.ReadString('\n')
 
 		if err != nil {
-			return 0, 0, newError(ReadError, err.Error())
+			return h, newError(ReadError, err.Error())
 		}
 
-		if line[0] == 0 || line[0] == '\n' {
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) == 0 || line[0] == 0 {
 			// blank lines signifies end of meta data header
 			break
-		} else if line == "FORMAT=32-bit_rle_rgbe\n" {
+		} else if line == "FORMAT=32-bit_rle_rgbe" {
 			formatSpecifier = true
+		} else if strings.HasPrefix(line, "FORMAT=") {
+			return h, newError(FormatError, "Unsupported "+line)
+		} else if strings.HasPrefix(line, "EXPOSURE=") {
+			exposure, err := strconv.ParseFloat(strings.TrimSpace(line[len("EXPOSURE="):]), 32)
+			if err != nil || exposure <= 0 {
+				return h, newError(FormatError, "Bad "+line)
+			}
+			// multiple exposure lines are cumulative
+			h.exposure *= float32(exposure)
 		}
 	}
 
 	if !formatSpecifier {
-		return 0, 0, newError(FormatError, "No FORMAT specifier found.")
+		return h, newError(FormatError, "No FORMAT specifier found.")
 	}
 
 	line, err = r.ReadString('\n')
 
 	if err != nil {
-		return 0, 0, newError(ReadError, err.Error())
+		return h, newError(ReadError, err.Error())
 	}
 
-	width, height := 0, 0
-	if n, err := fmt.Sscanf(line, "-Y %d +X %d", &height, &width); n < 2 || err != nil {
-		return 0, 0, newError(FormatError, "Missing image size specifier.")
+	var sign1, axis1, sign2, axis2 byte
+	if n, err := fmt.Sscanf(line, "%c%c %d %c%c %d", &sign1, &axis1, &h.scanlines, &sign2, &axis2, &h.scanlineWidth); n < 6 || err != nil {
+		return h, newError(FormatError, "Missing image size specifier.")
+	}
+	for _, c := range []byte{sign1, sign2} {
+		if c != '-' && c != '+' {
+			return h, newError(FormatError, "Bad image size specifier.")
+		}
+	}
+	if !(axis1 == 'Y' && axis2 == 'X') && !(axis1 == 'X' && axis2 == 'Y') {
+		return h, newError(FormatError, "Bad image size specifier.")
+	}
+	if h.scanlines <= 0 || h.scanlineWidth <= 0 {
+		return h, newError(FormatError, "Bad image size.")
 	}
+	h.major = axis{axis1, sign1 == '-'}
+	h.minor = axis{axis2, sign2 == '-'}
 
-	return width, height, nil
+	return h, nil
 }
 
 func readPixels_RLE(r *bufio.Reader, scanlineWidth, numScanlines int, data []float32) error {
@@ -90,9 +183,9 @@ func readPixels_RLE(r *bufio.Reader, scanlineWidth, numScanlines int, data []flo
 
 		if rgbe[0] != 2 || rgbe[1] != 2 || (rgbe[2]&0x80) != 0 {
 			// this file is not run length encoded
-			data[0], data[1], data[2] = rgbeToFloat(rgbe[0], rgbe[1], rgbe[2], rgbe[3])
+			data[offset], data[offset+1], data[offset+2] = rgbeToFloat(rgbe[0], rgbe[1], rgbe[2], rgbe[3])
 
-			return readPixels(r, scanlineWidth*numScanlines-1, data[3:])
+			return readPixels(r, scanlineWidth*numScanlines-1, data[offset+3:])
 		}
 
 		if int(rgbe[2])<<8|int(rgbe[3]) != scanlineWidth {
@@ -0,0 +1,152 @@
+package rgbe
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+// minimum run length for the run length encoding to be worth it
+const minRunLength = 4
+
+// Encode writes the RGB float pixels, starting at the top left corner, as a run length encoded Radiance RGBE image
+func Encode(w io.Writer, width, height int, data []float32) error {
+	if width <= 0 || height <= 0 {
+		return newError(FormatError, fmt.Sprintf("Bad image size %dx%d.", width, height))
+	}
+	if len(data) != width*height*3 {
+		return newError(FormatError, fmt.Sprintf("Expected %d floats, got %d.", width*height*3, len(data)))
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", height, width); err != nil {
+		return newError(WriteError, err.Error())
+	}
+	if err := writePixels_RLE(bw, width, height, data); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return newError(WriteError, err.Error())
+	}
+	return nil
+}
+
+func writePixels_RLE(w *bufio.Writer, scanlineWidth, numScanlines int, data []float32) error {
+	if scanlineWidth < 8 || scanlineWidth > 0x7fff {
+		// run length encoding is not allowed so write flat
+		return writePixels(w, scanlineWidth*numScanlines, data)
+	}
+
+	scanlineBuffer := make([]byte, 4*scanlineWidth)
+	for y := 0; y < numScanlines; y++ {
+		header := []byte{2, 2, byte(scanlineWidth >> 8), byte(scanlineWidth & 0xff)}
+		if _, err := w.Write(header); err != nil {
+			return newError(WriteError, err.Error())
+		}
+
+		// split the scanline into four separate channels
+		for i := 0; i < scanlineWidth; i++ {
+			offset := (y*scanlineWidth + i) * 3
+			r, g, b, e := floatToRGBE(data[offset], data[offset+1], data[offset+2])
+			scanlineBuffer[i] = r
+			scanlineBuffer[i+scanlineWidth] = g
+			scanlineBuffer[i+2*scanlineWidth] = b
+			scanlineBuffer[i+3*scanlineWidth] = e
+		}
+
+		for i := 0; i < 4; i++ {
+			if err := writeBytes_RLE(w, scanlineBuffer[i*scanlineWidth:(i+1)*scanlineWidth]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeBytes_RLE run length encodes one channel of a scanline
+func writeBytes_RLE(w *bufio.Writer, data []byte) error {
+	cur := 0
+	for cur < len(data) {
+		begRun := cur
+		runCount, oldRunCount := 0, 0
+		// find the next run that is long enough
+		for runCount < minRunLength && begRun < len(data) {
+			begRun += runCount
+			oldRunCount = runCount
+			runCount = 1
+			for begRun+runCount < len(data) && runCount < 127 && data[begRun] == data[begRun+runCount] {
+				runCount++
+			}
+		}
+
+		// if the data before the next big run is a short run then write it as such
+		if oldRunCount > 1 && oldRunCount == begRun-cur {
+			if _, err := w.Write([]byte{byte(128 + oldRunCount), data[cur]}); err != nil {
+				return newError(WriteError, err.Error())
+			}
+			cur = begRun
+		}
+
+		// write out bytes until we reach the start of the next run
+		for cur < begRun {
+			count := begRun - cur
+			if count > 128 {
+				count = 128
+			}
+			if err := w.WriteByte(byte(count)); err != nil {
+				return newError(WriteError, err.Error())
+			}
+			if _, err := w.Write(data[cur : cur+count]); err != nil {
+				return newError(WriteError, err.Error())
+			}
+			cur += count
+		}
+
+		// write out the next run if one was found
+		if runCount >= minRunLength {
+			if _, err := w.Write([]byte{byte(128 + runCount), data[begRun]}); err != nil {
+				return newError(WriteError, err.Error())
+			}
+			cur += runCount
+		}
+	}
+	return nil
+}
+
+func writePixels(w *bufio.Writer, numPixels int, data []float32) error {
+	for i := 0; i < numPixels; i++ {
+		r, g, b, e := floatToRGBE(data[i*3], data[i*3+1], data[i*3+2])
+		if _, err := w.Write([]byte{r, g, b, e}); err != nil {
+			return newError(WriteError, err.Error())
+		}
+	}
+	return nil
+}
+
+// standard conversion from float pixels to rgbe, the inverse of rgbeToFloat
+func floatToRGBE(r, g, b float32) (byte, byte, byte, byte) {
+	v := math.Max(float64(r), math.Max(float64(g), float64(b)))
+	if v < 1e-32 {
+		return 0, 0, 0, 0
+	}
+	mantissa, exponent := math.Frexp(v)
+	if exponent > 127 {
+		// clamp values that can't be represented to the largest one that can
+		mantissa, exponent = 255.0/256.0, 127
+		v = math.Ldexp(mantissa, exponent)
+	}
+	scale := mantissa * 256 / v
+	return channel(r, scale), channel(g, scale), channel(b, scale), byte(exponent + 128)
+}
+
+func channel(c float32, scale float64) byte {
+	v := float64(c) * scale
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return byte(v)
+}
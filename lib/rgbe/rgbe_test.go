@@ -0,0 +1,204 @@
+package rgbe
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// testImage returns an image with both noise and long runs of the same value, to exercise every RLE code path
+func testImage(width, height int, seed int64) []float32 {
+	rnd := rand.New(rand.NewSource(seed))
+	data := make([]float32, width*height*3)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * 3
+			switch {
+			case x%50 < 20:
+				// a run
+				data[i], data[i+1], data[i+2] = 1.5, 0.25, 0.125
+			case x%50 < 25:
+				// black
+			default:
+				data[i] = rnd.Float32() * 100
+				data[i+1] = rnd.Float32()
+				data[i+2] = rnd.Float32() * 0.001
+			}
+		}
+	}
+	return data
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	sizes := [][2]int{{1, 1}, {7, 3}, {8, 2}, {64, 5}, {300, 2}, {1024, 1}}
+	for _, size := range sizes {
+		width, height := size[0], size[1]
+		t.Run(fmt.Sprintf("%dx%d", width, height), func(t *testing.T) {
+			in := testImage(width, height, int64(width))
+			var buf bytes.Buffer
+			if err := Encode(&buf, width, height, in); err != nil {
+				t.Fatal(err)
+			}
+			w, h, out, err := Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w != width || h != height {
+				t.Fatalf("got size %dx%d, expected %dx%d", w, h, width, height)
+			}
+			for i := 0; i < len(in); i += 3 {
+				max := math.Max(float64(in[i]), math.Max(float64(in[i+1]), float64(in[i+2])))
+				for c := 0; c < 3; c++ {
+					// the shared exponent gives each channel the precision of the brightest one
+					if diff := math.Abs(float64(out[i+c] - in[i+c])); diff > max/128 {
+						t.Fatalf("pixel %d channel %d: got %f, expected %f", i/3, c, out[i+c], in[i+c])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeIsStable(t *testing.T) {
+	width, height := 200, 3
+	var first bytes.Buffer
+	if err := Encode(&first, width, height, testImage(width, height, 1)); err != nil {
+		t.Fatal(err)
+	}
+	_, _, decoded, err := Decode(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var second bytes.Buffer
+	if err := Encode(&second, width, height, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("encoding a decoded image should produce the same bytes")
+	}
+}
+
+func TestEncodeClampsAndBlack(t *testing.T) {
+	in := []float32{0, 0, 0, -1, -2, -3, 1e-40, 0, 0, 3e38, 1, 1}
+	var buf bytes.Buffer
+	if err := Encode(&buf, 4, 1, in); err != nil {
+		t.Fatal(err)
+	}
+	_, _, out, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 9; i++ {
+		if out[i] != 0 {
+			t.Errorf("value %d: got %f, expected 0", i, out[i])
+		}
+	}
+	if math.IsInf(float64(out[9]), 0) || out[9] < 1e38 {
+		t.Errorf("expected a large finite value, got %f", out[9])
+	}
+}
+
+func TestEncodeBadInput(t *testing.T) {
+	if err := Encode(&bytes.Buffer{}, 0, 1, nil); err == nil {
+		t.Error("expected an error for an empty image")
+	}
+	if err := Encode(&bytes.Buffer{}, 2, 2, make([]float32, 3)); err == nil {
+		t.Error("expected an error for too few pixels")
+	}
+}
+
+// exact returns a value that survives the rgbe conversion without loss
+func exact(i int) [3]float32 {
+	r, g, b := rgbeToFloat(byte(128+i), byte(i), 10, 129)
+	return [3]float32{r, g, b}
+}
+
+func TestDecodeOrientations(t *testing.T) {
+	const width, height = 3, 2
+	var expected []float32
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := exact(y*width + x)
+			expected = append(expected, p[:]...)
+		}
+	}
+
+	tests := []struct {
+		resolution string
+		// pixel returns the x, y of the n'th pixel stored in the file
+		pixel func(n int) (int, int)
+	}{
+		{"-Y 2 +X 3", func(n int) (int, int) { return n % 3, n / 3 }},
+		{"-Y 2 -X 3", func(n int) (int, int) { return 2 - n%3, n / 3 }},
+		{"+Y 2 +X 3", func(n int) (int, int) { return n % 3, 1 - n/3 }},
+		{"+Y 2 -X 3", func(n int) (int, int) { return 2 - n%3, 1 - n/3 }},
+		{"+X 3 -Y 2", func(n int) (int, int) { return n / 2, n % 2 }},
+		{"+X 3 +Y 2", func(n int) (int, int) { return n / 2, 1 - n%2 }},
+		{"-X 3 -Y 2", func(n int) (int, int) { return 2 - n/2, n % 2 }},
+		{"-X 3 +Y 2", func(n int) (int, int) { return 2 - n/2, 1 - n%2 }},
+	}
+	for _, test := range tests {
+		t.Run(test.resolution, func(t *testing.T) {
+			var buf bytes.Buffer
+			fmt.Fprintf(&buf, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n%s\n", test.resolution)
+			for n := 0; n < width*height; n++ {
+				x, y := test.pixel(n)
+				p := exact(y*width + x)
+				r, g, b, e := floatToRGBE(p[0], p[1], p[2])
+				buf.Write([]byte{r, g, b, e})
+			}
+			w, h, out, err := Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w != width || h != height {
+				t.Fatalf("got size %dx%d, expected %dx%d", w, h, width, height)
+			}
+			for i := range expected {
+				if out[i] != expected[i] {
+					t.Fatalf("got %v, expected %v", out, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeExposure(t *testing.T) {
+	p := exact(5)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "#?RADIANCE\nEXPOSURE=2\nFORMAT=32-bit_rle_rgbe\nEXPOSURE= 0.25\n\n-Y 1 +X 1\n")
+	r, g, b, e := floatToRGBE(p[0], p[1], p[2])
+	buf.Write([]byte{r, g, b, e})
+	_, _, out, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for c := 0; c < 3; c++ {
+		if expected := p[c] / 0.5; out[c] != expected {
+			t.Errorf("channel %d: got %f, expected %f", c, out[c], expected)
+		}
+	}
+}
+
+func TestDecodeBadHeader(t *testing.T) {
+	tests := map[string]string{
+		"empty":           "",
+		"initial token":   "RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 1\n",
+		"no format":       "#?RADIANCE\n\n-Y 1 +X 1\n",
+		"xyze format":     "#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n",
+		"bad exposure":    "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\nEXPOSURE=-1\n\n-Y 1 +X 1\n",
+		"no resolution":   "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n",
+		"same axis":       "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +Y 1\n",
+		"bad sign":        "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n*Y 1 +X 1\n",
+		"zero size":       "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 0 +X 1\n",
+		"truncated pixel": "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 2\n\x01\x02\x03\x80",
+	}
+	for name, in := range tests {
+		if _, _, _, err := Decode(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+// Package scenefile reads and writes the JSON files that describe a level: the models and their materials and
+// transforms, the lights, the environment map and where the camera starts. Asset paths in the file are relative to the
+// root of the assets, like "models/cube" and "rock_floor/Base_Color.png" for textures.
+package scenefile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+)
+
+// Scene is the content of a scene file
+type Scene struct {
+	// Environment is the equirectangular HDR image in the textures that lights the scene and is drawn as the sky
+	Environment      string               `json:"environment,omitempty"`
+	Camera           *Camera              `json:"camera,omitempty"`
+	DirectionalLight *DirectionalLight    `json:"directionalLight,omitempty"`
+	PointLights      []PointLight         `json:"pointLights,omitempty"`
+	Materials        map[string]*Material `json:"materials,omitempty"`
+	Nodes            []*Node              `json:"nodes"`
+}
+
+// Camera is where the camera starts, yaw and pitch are in degrees and a yaw of 0 looks down the positive X axis
+type Camera struct {
+	Position [3]float32 `json:"position"`
+	Yaw      float32    `json:"yaw"`
+	Pitch    float32    `json:"pitch"`
+}
+
+type DirectionalLight struct {
+	// Direction points towards the light, it doesn't have to be normalised
+	Direction [3]float32 `json:"direction"`
+	Color     [3]float32 `json:"color"`
+	Disabled  bool       `json:"disabled,omitempty"`
+}
+
+type PointLight struct {
+	Position [3]float32 `json:"position"`
+	Color    [3]float32 `json:"color"`
+	// Range picks the attenuation for a light that reaches about this far, see RangeOrDefault
+	Range int `json:"range,omitempty"`
+}
+
+// DefaultRange is the range of point lights that don't set one
+const DefaultRange = 20
+
+// RangeOrDefault returns the range of the light, or DefaultRange when it isn't set
+func (l PointLight) RangeOrDefault() int {
+	if l.Range == 0 {
+		return DefaultRange
+	}
+	return l.Range
+}
+
+// Shaders that a material can be drawn with
+const (
+	// Textured meshes take their properties from the textures, and from the mtl file of the model when the material
+	// doesn't set them
+	Textured = "textured"
+	// Flat meshes have a single albedo, metallic and roughness value
+	Flat = "flat"
+)
+
+// Texture map keys, the single valued maps are read from the red channel, ORM and MR are packed textures that carry
+// ambient occlusion, roughness and metallic in the red, green and blue channel
+var textureKeys = []string{"albedo", "metallic", "roughness", "normal", "ao", "emissive", "orm", "mr"}
+
+// Material is shared by the nodes that name it
+type Material struct {
+	// Shader is Textured or Flat, it defaults to Textured
+	Shader    string            `json:"shader,omitempty"`
+	Albedo    *[3]float32       `json:"albedo,omitempty"`
+	Metallic  *float32          `json:"metallic,omitempty"`
+	Roughness *float32          `json:"roughness,omitempty"`
+	Emissive  *[3]float32       `json:"emissive,omitempty"`
+	Textures  map[string]string `json:"textures,omitempty"`
+	// LineWidth and PointSize are in pixels, they're used by models of lines and points
+	LineWidth *float32 `json:"lineWidth,omitempty"`
+	PointSize *float32 `json:"pointSize,omitempty"`
+}
+
+// ShaderOrDefault returns the shader of the material, or Textured when it isn't set
+func (m *Material) ShaderOrDefault() string {
+	if m == nil || m.Shader == "" {
+		return Textured
+	}
+	return m.Shader
+}
+
+// Node places a model, or just a transform for its children, relative to its parent
+type Node struct {
+	Name   string   `json:"name,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Hidden bool     `json:"hidden,omitempty"`
+	// Model is the directory of an OBJ model, GLTF a .gltf or .glb file, a node can have at most one of them
+	Model string `json:"model,omitempty"`
+	GLTF  string `json:"gltf,omitempty"`
+	// Material names one of the scene's materials, it's only used for Model
+	Material string `json:"material,omitempty"`
+	Transform
+	Repeat   *Repeat `json:"repeat,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Transform is either a column major matrix, or a translation, rotation and scale that are applied in the reverse
+// order. Unset parts are the identity.
+type Transform struct {
+	Translation *[3]float32  `json:"translation,omitempty"`
+	Rotation    *Rotation    `json:"rotation,omitempty"`
+	Scale       *[3]float32  `json:"scale,omitempty"`
+	Matrix      *[16]float32 `json:"matrix,omitempty"`
+}
+
+// Rotation is a counter clockwise rotation around an axis, which doesn't have to be normalised
+type Rotation struct {
+	Axis    [3]float32 `json:"axis"`
+	Degrees float32    `json:"degrees"`
+}
+
+// Repeat places copies of a node, children included, in a grid. The copy at x, y, z is moved by Step times x, y and z
+// in the space of the parent.
+type Repeat struct {
+	Count [3]int     `json:"count"`
+	Step  [3]float32 `json:"step"`
+}
+
+// Open reads and checks a scene file
+func Open(path string) (*Scene, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scene, err := Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return scene, nil
+}
+
+// OpenFS is Open for a scene file in fsys
+func OpenFS(fsys fs.FS, name string) (*Scene, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	scene, err := Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return scene, nil
+}
+
+// Save writes the scene to a file, see Encode
+func Save(path string, scene *Scene) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Encode(f, scene); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Encode writes the scene as indented JSON, with lists of numbers like positions and colours on a single line
+func Encode(w io.Writer, scene *Scene) error {
+	if err := scene.Check(); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(scene, "", "\t")
+	if err != nil {
+		return err
+	}
+	content = append(compactNumbers(content), '\n')
+	_, err = w.Write(content)
+	return err
+}
+
+// compactNumbers puts the arrays in the indented JSON that only have numbers on one line
+func compactNumbers(content []byte) []byte {
+	var out []byte
+	inString := false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		out = append(out, c)
+		switch {
+		case inString && c == '\\':
+			i++
+			out = append(out, content[i])
+		case c == '"':
+			inString = !inString
+		case c == '[' && !inString:
+			end := bytes.IndexByte(content[i:], ']')
+			if end < 0 {
+				continue
+			}
+			fields := bytes.Fields(content[i+1 : i+end])
+			if len(fields) == 0 || !onlyNumbers(fields) {
+				continue
+			}
+			out = append(out, bytes.Join(fields, []byte(" "))...)
+			out = append(out, ']')
+			i += end
+		}
+	}
+	return out
+}
+
+func onlyNumbers(fields [][]byte) bool {
+	for _, f := range fields {
+		for _, c := range f {
+			if !strings.ContainsRune("0123456789-+.eE,", rune(c)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Decode reads a scene and checks that it's complete. Unknown fields are an error, so that misspelled ones aren't
+// silently ignored.
+func Decode(r io.Reader) (*Scene, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	scene := &Scene{}
+	if err := dec.Decode(scene); err != nil {
+		return nil, err
+	}
+	if err := scene.Check(); err != nil {
+		return nil, err
+	}
+	return scene, nil
+}
+
+// Check returns an error, with the path to the field, for the first problem in the scene
+func (s *Scene) Check() error {
+	for name, m := range s.Materials {
+		if m == nil {
+			return fmt.Errorf("materials.%s is empty", name)
+		}
+		if shader := m.ShaderOrDefault(); shader != Textured && shader != Flat {
+			return fmt.Errorf("materials.%s: unknown shader %q, expected %q or %q", name, shader, Textured, Flat)
+		}
+		if (m.LineWidth != nil && *m.LineWidth <= 0) || (m.PointSize != nil && *m.PointSize <= 0) {
+			return fmt.Errorf("materials.%s: lineWidth and pointSize must be positive", name)
+		}
+		for key := range m.Textures {
+			if !knownTexture(key) {
+				return fmt.Errorf("materials.%s: unknown texture %q, expected one of %v", name, key, textureKeys)
+			}
+		}
+	}
+	if l := s.DirectionalLight; l != nil && l.Direction == [3]float32{} {
+		return fmt.Errorf("directionalLight: direction can't be zero")
+	}
+	for i, l := range s.PointLights {
+		if l.Range < 0 {
+			return fmt.Errorf("pointLights[%d]: range can't be negative", i)
+		}
+	}
+	return s.checkNodes("nodes", s.Nodes)
+}
+
+func (s *Scene) checkNodes(prefix string, nodes []*Node) error {
+	for i, n := range nodes {
+		field := fmt.Sprintf("%s[%d]", prefix, i)
+		if n == nil {
+			return fmt.Errorf("%s is empty", field)
+		}
+		if n.Model != "" && n.GLTF != "" {
+			return fmt.Errorf("%s: has both a model and a gltf file", field)
+		}
+		if n.Material != "" {
+			if n.Model == "" {
+				return fmt.Errorf("%s: material %q needs a model", field, n.Material)
+			}
+			if _, ok := s.Materials[n.Material]; !ok {
+				return fmt.Errorf("%s: material %q is not defined", field, n.Material)
+			}
+		}
+		if n.Matrix != nil && (n.Translation != nil || n.Rotation != nil || n.Scale != nil) {
+			return fmt.Errorf("%s: has both a matrix and a translation, rotation or scale", field)
+		}
+		if r := n.Rotation; r != nil && r.Axis == [3]float32{} && r.Degrees != 0 {
+			return fmt.Errorf("%s: rotation has no axis", field)
+		}
+		if r := n.Repeat; r != nil && (r.Count[0] < 1 || r.Count[1] < 1 || r.Count[2] < 1) {
+			return fmt.Errorf("%s: repeat count must be at least 1 along every axis", field)
+		}
+		if err := s.checkNodes(field+".children", n.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func knownTexture(key string) bool {
+	for _, k := range textureKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Copies returns the offset of every copy of the node, a node without a Repeat has a single copy at the origin
+func (n *Node) Copies() [][3]float32 {
+	if n.Repeat == nil {
+		return [][3]float32{{}}
+	}
+	r := n.Repeat
+	offsets := make([][3]float32, 0, r.Count[0]*r.Count[1]*r.Count[2])
+	for x := 0; x < r.Count[0]; x++ {
+		for y := 0; y < r.Count[1]; y++ {
+			for z := 0; z < r.Count[2]; z++ {
+				offsets = append(offsets, [3]float32{float32(x) * r.Step[0], float32(y) * r.Step[1], float32(z) * r.Step[2]})
+			}
+		}
+	}
+	return offsets
+}
+
+// Translate returns the transform moved by offset in the space of the parent
+func (t Transform) Translate(offset [3]float32) Transform {
+	if t.Matrix != nil {
+		m := *t.Matrix
+		for i := range offset {
+			m[12+i] += offset[i]
+		}
+		t.Matrix = &m
+		return t
+	}
+	var translation [3]float32
+	if t.Translation != nil {
+		translation = *t.Translation
+	}
+	for i := range offset {
+		translation[i] += offset[i]
+	}
+	t.Translation = &translation
+	return t
+}
+
+// Decompose returns the translation, rotation and scale that make up the column major matrix, or the matrix itself
+// when they don't give back exactly the same matrix, like for shears and most rotations that went through a matrix
+func Decompose(m [16]float32) Transform {
+	matrix := Transform{Matrix: &m}
+	if m[3] != 0 || m[7] != 0 || m[11] != 0 || m[15] != 1 {
+		return matrix
+	}
+	var t Transform
+	if translation := [3]float32{m[12], m[13], m[14]}; translation != [3]float32{} {
+		t.Translation = &translation
+	}
+	var scale [3]float32
+	for i := range scale {
+		c := m[i*4 : i*4+3]
+		scale[i] = float32(math.Sqrt(float64(c[0]*c[0] + c[1]*c[1] + c[2]*c[2])))
+		if scale[i] == 0 {
+			return matrix
+		}
+	}
+	// a mirrored matrix has a negative scale, put it on the X axis
+	if det := m[0]*(m[5]*m[10]-m[6]*m[9]) - m[4]*(m[1]*m[10]-m[2]*m[9]) + m[8]*(m[1]*m[6]-m[2]*m[5]); det < 0 {
+		scale[0] = -scale[0]
+	}
+	if scale != [3]float32{1, 1, 1} {
+		t.Scale = &scale
+	}
+	// the rotation matrix, and the quaternion of it
+	var r [3][3]float64
+	for col := range r {
+		for row := range r[col] {
+			r[col][row] = float64(m[col*4+row] / scale[col])
+		}
+	}
+	if trace := r[0][0] + r[1][1] + r[2][2]; trace < 3 {
+		w := math.Sqrt(math.Max(0, 1+trace)) / 2
+		x := math.Copysign(math.Sqrt(math.Max(0, 1+r[0][0]-r[1][1]-r[2][2]))/2, r[1][2]-r[2][1])
+		y := math.Copysign(math.Sqrt(math.Max(0, 1-r[0][0]+r[1][1]-r[2][2]))/2, r[2][0]-r[0][2])
+		z := math.Copysign(math.Sqrt(math.Max(0, 1-r[0][0]-r[1][1]+r[2][2]))/2, r[0][1]-r[1][0])
+		if l := math.Sqrt(x*x + y*y + z*z); l > 0 {
+			degrees := 2 * math.Atan2(l, w) * 180 / math.Pi
+			t.Rotation = &Rotation{Axis: [3]float32{float32(x / l), float32(y / l), float32(z / l)}, Degrees: float32(degrees)}
+		}
+	}
+	if t.Mat4() != m {
+		return matrix
+	}
+	return t
+}
+
+// Mat4 returns the column major matrix of the transform
+func (t Transform) Mat4() [16]float32 {
+	if t.Matrix != nil {
+		return *t.Matrix
+	}
+	translation := [3]float32{}
+	if t.Translation != nil {
+		translation = *t.Translation
+	}
+	scale := [3]float32{1, 1, 1}
+	if t.Scale != nil {
+		scale = *t.Scale
+	}
+	// the rotation as a unit quaternion
+	x, y, z, w := float32(0), float32(0), float32(0), float32(1)
+	if r := t.Rotation; r != nil && r.Axis != [3]float32{} {
+		a := r.Axis
+		l := float32(math.Sqrt(float64(a[0]*a[0] + a[1]*a[1] + a[2]*a[2])))
+		half := float64(r.Degrees) * math.Pi / 360
+		s := float32(math.Sin(half)) / l
+		x, y, z, w = a[0]*s, a[1]*s, a[2]*s, float32(math.Cos(half))
+	}
+	s := scale
+	// T * R * S
+	return [16]float32{
+		(1 - 2*(y*y+z*z)) * s[0], (2 * (x*y + z*w)) * s[0], (2 * (x*z - y*w)) * s[0], 0,
+		(2 * (x*y - z*w)) * s[1], (1 - 2*(x*x+z*z)) * s[1], (2 * (y*z + x*w)) * s[1], 0,
+		(2 * (x*z + y*w)) * s[2], (2 * (y*z - x*w)) * s[2], (1 - 2*(x*x+y*y)) * s[2], 0,
+		translation[0], translation[1], translation[2], 1,
+	}
+}
@@ -0,0 +1,134 @@
+package scenefile
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	content, err := os.ReadFile("../../scenes/pbr.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scene, err := Decode(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the example is written the way Encode writes it, so that a saved scene looks like the file it was loaded from
+	var example bytes.Buffer
+	if err := Encode(&example, scene); err != nil {
+		t.Fatal(err)
+	}
+	if example.String() != string(content) {
+		t.Errorf("scenes/pbr.json isn't encoded the way Encode writes it:\n%s", example.String())
+	}
+	// names that look like JSON must survive the compacting of number lists
+	scene.Nodes[0].Name = `floor [1, 2] "tiles"`
+	scene.Nodes[0].Children = []*Node{{Transform: Decompose(rotationY(0.3))}}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, scene); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.String()
+	if !strings.Contains(encoded, `"position": [10, 5, 5]`) {
+		t.Errorf("number lists aren't on one line:\n%s", encoded)
+	}
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, scene) {
+		t.Fatalf("the decoded scene differs from the encoded one:\n%s", encoded)
+	}
+
+	// encoding again gives the same file
+	var again bytes.Buffer
+	if err := Encode(&again, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != encoded {
+		t.Fatalf("the scene changed when it was encoded again:\n%s\n%s", encoded, again.String())
+	}
+}
+
+func TestDecompose(t *testing.T) {
+	translation := [3]float32{1, -2, 3.5}
+	scale := [3]float32{2, 0.5, 1}
+	tests := map[string]Transform{
+		"identity":    {},
+		"translation": {Translation: &translation},
+		"scale":       {Translation: &translation, Scale: &scale},
+		"rotation":    {Rotation: &Rotation{Axis: [3]float32{0, 1, 0}, Degrees: 90}},
+		"mirror":      {Scale: &[3]float32{-1, 1, 1}},
+		"shear":       {Matrix: &[16]float32{1, 0, 0, 0, 0.5, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}},
+		"arbitrary":   {Matrix: &[16]float32{0.3, 0.1, 0, 0, -0.2, 0.7, 0.1, 0, 0, 0, 1.3, 0, 4, 5, 6, 1}},
+	}
+	for name, transform := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := transform.Mat4()
+			d := Decompose(m)
+			// whatever form Decompose picks, it must give back the exact matrix
+			if d.Mat4() != m {
+				t.Fatalf("got %v, expected %v", d.Mat4(), m)
+			}
+			if transform.Matrix == nil && d.Matrix != nil && transform.Rotation == nil {
+				t.Errorf("got a matrix for %v", m)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	offset := [3]float32{2, 0, 4}
+	for _, transform := range []Transform{
+		{},
+		{Translation: &[3]float32{1, 1, 1}, Rotation: &Rotation{Axis: [3]float32{1, 1, 0}, Degrees: 30}, Scale: &[3]float32{1, 2, 3}},
+		{Matrix: &[16]float32{0, 0, -1, 0, 0, 1, 0, 0, 1, 0, 0, 0, 7, 8, 9, 1}},
+	} {
+		before := transform.Mat4()
+		got := transform.Translate(offset).Mat4()
+		expected := before
+		expected[12] += offset[0]
+		expected[13] += offset[1]
+		expected[14] += offset[2]
+		if got != expected {
+			t.Errorf("got %v, expected %v", got, expected)
+		}
+		if transform.Mat4() != before {
+			t.Errorf("Translate changed the transform it was called on")
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":         `{"nodes": [{"modle": "models/cube"}]}`,
+		"missing material":      `{"nodes": [{"model": "models/cube", "material": "rock"}]}`,
+		"unknown shader":        `{"materials": {"rock": {"shader": "pbr"}}, "nodes": []}`,
+		"unknown texture":       `{"materials": {"rock": {"textures": {"bump": "rock.png"}}}, "nodes": []}`,
+		"model and gltf":        `{"nodes": [{"model": "models/cube", "gltf": "models/cube.glb"}]}`,
+		"matrix and scale":      `{"nodes": [{"children": [{"scale": [1, 1, 1], "matrix": [1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1]}]}]}`,
+		"empty repeat":          `{"nodes": [{"repeat": {"count": [2, 0, 2], "step": [1, 1, 1]}}]}`,
+		"negative range":        `{"pointLights": [{"position": [0, 0, 0], "color": [1, 1, 1], "range": -1}], "nodes": []}`,
+		"rotation without axis": `{"nodes": [{"rotation": {"axis": [0, 0, 0], "degrees": 10}}]}`,
+		"negative line width":   `{"materials": {"wire": {"lineWidth": -1}}, "nodes": []}`,
+		"zero light direction":  `{"directionalLight": {"direction": [0, 0, 0], "color": [1, 1, 1]}, "nodes": []}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(content)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func rotationY(radians float64) [16]float32 {
+	c, s := float32(math.Cos(radians)), float32(math.Sin(radians))
+	return [16]float32{c, 0, -s, 0, 0, 1, 0, 0, s, 0, c, 0, 0, 0, 0, 1}
+}
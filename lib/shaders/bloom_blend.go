@@ -6,12 +6,15 @@ type BloomBlend struct {
 	LocBloomTexture  int32
 }
 
-func NewBloomBlend() *BloomBlend {
-	c := buildShader("fx", "fx_bloom_blender")
+func NewBloomBlend() (*BloomBlend, error) {
+	c, err := buildShader("fx", "fx_bloom_blender")
+	if err != nil {
+		return nil, err
+	}
 
 	return &BloomBlend{
 		Program:          c,
 		LocScreenTexture: loc(c, "screenTexture"),
 		LocBloomTexture:  loc(c, "bloomTexture"),
-	}
+	}, nil
 }
@@ -5,11 +5,14 @@ type BloomSeparator struct {
 	LocScreenTexture int32
 }
 
-func NewBloomSeparator() *BloomSeparator {
-	c := buildShader("fx", "fx_brigthness_sep")
+func NewBloomSeparator() (*BloomSeparator, error) {
+	c, err := buildShader("fx", "fx_brigthness_sep")
+	if err != nil {
+		return nil, err
+	}
 
 	return &BloomSeparator{
 		Program:          c,
 		LocScreenTexture: loc(c, "screenTexture"),
-	}
+	}, nil
 }
@@ -5,11 +5,14 @@ type Blur struct {
 	LocScreenTexture int32
 }
 
-func NewBlur() *Blur {
-	c := buildShader("fx", "fx_blur")
+func NewBlur() (*Blur, error) {
+	c, err := buildShader("fx", "fx_blur")
+	if err != nil {
+		return nil, err
+	}
 
 	return &Blur{
 		Program:          c,
 		LocScreenTexture: loc(c, "screenTexture"),
-	}
+	}, nil
 }
@@ -8,8 +8,11 @@ type Emissive struct {
 	LocColor int32
 }
 
-func NewEmissive() *Emissive {
-	c := buildShader("emissive", "emissive")
+func NewEmissive() (*Emissive, error) {
+	c, err := buildShader("emissive", "emissive")
+	if err != nil {
+		return nil, err
+	}
 	blockIndex := gl.GetUniformBlockIndex(c, gl.Str("Matrices\x00"))
 	gl.UniformBlockBinding(c, blockIndex, 0)
 
@@ -17,5 +20,5 @@ func NewEmissive() *Emissive {
 		Program:  c,
 		LocModel: loc(c, "model"),
 		LocColor: loc(c, "emissive"),
-	}
+	}, nil
 }
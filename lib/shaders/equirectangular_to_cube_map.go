@@ -7,13 +7,16 @@ type EquiRectToCubeMap struct {
 	LocView               int32
 }
 
-func NewEquiRectToCubeMap() *EquiRectToCubeMap {
-	c := buildShader("ibl_cubemap", "equirectangular_to_cubemap")
+func NewEquiRectToCubeMap() (*EquiRectToCubeMap, error) {
+	c, err := buildShader("ibl_cubemap", "equirectangular_to_cubemap")
+	if err != nil {
+		return nil, err
+	}
 
 	return &EquiRectToCubeMap{
 		Program:               c,
 		LocEquirectangularMap: loc(c, "equirectangularMap"),
 		LocProjection:         loc(c, "projection"),
 		LocView:               loc(c, "view"),
-	}
+	}, nil
 }
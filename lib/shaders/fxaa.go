@@ -1,7 +1,10 @@
 package shaders
 
-func NewFxaa() *Fxaa {
-	c := buildShader("fx", "fx_fxaa")
+func NewFxaa() (*Fxaa, error) {
+	c, err := buildShader("fx", "fx_fxaa")
+	if err != nil {
+		return nil, err
+	}
 	s := &Fxaa{
 		Program:          c,
 		LocInTexture:     loc(c, "screenTexture"),
@@ -15,7 +18,7 @@ func NewFxaa() *Fxaa {
 	//blockIndex := gl.GetUniformBlockIndex(c, gl.Str("Matrices\x00"))
 	//gl.UniformBlockBinding(c, blockIndex, 0)
 
-	return s
+	return s, nil
 }
 
 type Fxaa struct {
@@ -6,12 +6,15 @@ type Gaussian struct {
 	LocHorizontal    int32
 }
 
-func NewGaussian() *Gaussian {
-	c := buildShader("fx", "fx_guassian_blur")
+func NewGaussian() (*Gaussian, error) {
+	c, err := buildShader("fx", "fx_guassian_blur")
+	if err != nil {
+		return nil, err
+	}
 
 	return &Gaussian{
 		Program:          c,
 		LocScreenTexture: loc(c, "screenTexture"),
 		LocHorizontal:    loc(c, "horizontal"),
-	}
+	}, nil
 }
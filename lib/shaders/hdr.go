@@ -6,11 +6,14 @@ type HDR struct {
 	LocExposure      int32
 }
 
-func NewHDR() *HDR {
-	c := buildShader("fx", "fx_tone")
+func NewHDR() (*HDR, error) {
+	c, err := buildShader("fx", "fx_tone")
+	if err != nil {
+		return nil, err
+	}
 	return &HDR{
 		Program:          c,
 		LocScreenTexture: loc(c, "screenTexture"),
 		LocExposure:      loc(c, "exposure"),
-	}
+	}, nil
 }
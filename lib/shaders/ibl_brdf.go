@@ -4,9 +4,12 @@ type IBLBrdf struct {
 	Program uint32
 }
 
-func NewIBLBrdf() *IBLBrdf {
-	c := buildShader("ibl_brdf", "ibl_brdf")
+func NewIBLBrdf() (*IBLBrdf, error) {
+	c, err := buildShader("ibl_brdf", "ibl_brdf")
+	if err != nil {
+		return nil, err
+	}
 	return &IBLBrdf{
 		Program: c,
-	}
+	}, nil
 }
@@ -7,13 +7,16 @@ type IBLIrradiance struct {
 	LocView           int32
 }
 
-func NewIBLIrradiance() *IBLIrradiance {
-	c := buildShader("ibl_cubemap", "ibl_irradiance_convolution")
+func NewIBLIrradiance() (*IBLIrradiance, error) {
+	c, err := buildShader("ibl_cubemap", "ibl_irradiance_convolution")
+	if err != nil {
+		return nil, err
+	}
 
 	return &IBLIrradiance{
 		Program:           c,
 		LocEnvironmentMap: loc(c, "environmentMap"),
 		LocProjection:     loc(c, "projection"),
 		LocView:           loc(c, "view"),
-	}
+	}, nil
 }
@@ -8,8 +8,11 @@ type IBLPreFilter struct {
 	LocRoughness      int32
 }
 
-func NewIBLPrefilter() *IBLPreFilter {
-	c := buildShader("ibl_cubemap", "ibl_prefilter")
+func NewIBLPrefilter() (*IBLPreFilter, error) {
+	c, err := buildShader("ibl_cubemap", "ibl_prefilter")
+	if err != nil {
+		return nil, err
+	}
 
 	return &IBLPreFilter{
 		Program:           c,
@@ -17,5 +20,5 @@ func NewIBLPrefilter() *IBLPreFilter {
 		LocProjection:     loc(c, "projection"),
 		LocView:           loc(c, "view"),
 		LocRoughness:      loc(c, "roughness"),
-	}
+	}, nil
 }
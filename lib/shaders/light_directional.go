@@ -2,8 +2,11 @@ package shaders
 
 import "github.com/go-gl/gl/v4.1-core/gl"
 
-func NewDirectionalLight() *DirectionalLight {
-	c := buildShader("lighting_dir_pbr", "lighting_dir_pbr")
+func NewDirectionalLight() (*DirectionalLight, error) {
+	c, err := buildShader("lighting_dir_pbr", "lighting_dir_pbr")
+	if err != nil {
+		return nil, err
+	}
 	s := &DirectionalLight{
 		Program: c,
 
@@ -11,6 +14,7 @@ func NewDirectionalLight() *DirectionalLight {
 		LocGNormal:           loc(c, "gNormal"),
 		LocGAlbedo:           loc(c, "gAlbedoSpec"),
 		LocGAmbientOcclusion: loc(c, "gAmbientOcclusion"),
+		LocGEmissiveAO:       loc(c, "gEmissiveAO"),
 		LocIBLEnabled:        loc(c, "iblEnabled"),
 		LocIrradianceMap:     loc(c, "irradianceMap"),
 		LocPrefilterMap:      loc(c, "prefilterMap"),
@@ -29,7 +33,7 @@ func NewDirectionalLight() *DirectionalLight {
 	blockIndex := gl.GetUniformBlockIndex(c, gl.Str("Matrices\x00"))
 	gl.UniformBlockBinding(c, blockIndex, 0)
 
-	return s
+	return s, nil
 }
 
 type DirectionalLight struct {
@@ -39,6 +43,7 @@ type DirectionalLight struct {
 	LocGNormal           int32
 	LocGAlbedo           int32
 	LocGAmbientOcclusion int32
+	LocGEmissiveAO       int32
 	LocIBLEnabled        int32
 	LocIrradianceMap     int32
 	LocPrefilterMap      int32
@@ -6,8 +6,11 @@ import (
 	"github.com/go-gl/gl/v4.1-core/gl"
 )
 
-func NewPointLightShader(lights int) *PointLight {
-	c := buildShader("lighting_point_pbr", "lighting_point_pbr")
+func NewPointLightShader(lights int) (*PointLight, error) {
+	c, err := buildShader("lighting_point_pbr", "lighting_point_pbr")
+	if err != nil {
+		return nil, err
+	}
 	s := &PointLight{
 		Program: c,
 		lights:  lights,
@@ -16,6 +19,7 @@ func NewPointLightShader(lights int) *PointLight {
 		LocGAlbedo:           loc(c, "gAlbedoSpec"),
 		LocGDepth:            loc(c, "gDepth"),
 		LocGAmbientOcclusion: loc(c, "gAmbientOcclusion"),
+		LocGEmissiveAO:       loc(c, "gEmissiveAO"),
 
 		LocNumLights: loc(c, "numLights"),
 
@@ -32,7 +36,7 @@ func NewPointLightShader(lights int) *PointLight {
 		s.LocLightQuadratic = append(s.LocLightQuadratic, loc(c, fmt.Sprintf("pointLight[%d].Quadratic", i)))
 	}
 
-	return s
+	return s, nil
 }
 
 type PointLight struct {
@@ -43,6 +47,7 @@ type PointLight struct {
 	LocGAlbedo           int32
 	LocGDepth            int32
 	LocGAmbientOcclusion int32
+	LocGEmissiveAO       int32
 
 	LocNumLights      int32
 	LocLightPos       []int32
@@ -5,10 +5,13 @@ type Passthrough struct {
 	LocScreenTexture int32
 }
 
-func NewPassthrough() *Passthrough {
-	c := buildShader("fx", "fx_pass")
+func NewPassthrough() (*Passthrough, error) {
+	c, err := buildShader("fx", "fx_pass")
+	if err != nil {
+		return nil, err
+	}
 	return &Passthrough{
 		Program:          c,
 		LocScreenTexture: loc(c, "screenTexture"),
-	}
+	}, nil
 }
@@ -0,0 +1,53 @@
+package shaders
+
+import "github.com/go-gl/gl/v4.1-core/gl"
+
+// Lines draws line segments as screen aligned quads, since core profile contexts only support a line width of one
+type Lines struct {
+	Program       uint32
+	LocModel      int32
+	LocColor      int32
+	LocWidth      int32
+	LocScreenSize int32
+}
+
+func NewLines() (*Lines, error) {
+	c, err := buildGeometryShader("lines", "lines", "primitive")
+	if err != nil {
+		return nil, err
+	}
+	blockIndex := gl.GetUniformBlockIndex(c, gl.Str("Matrices\x00"))
+	gl.UniformBlockBinding(c, blockIndex, 0)
+
+	return &Lines{
+		Program:       c,
+		LocModel:      loc(c, "model"),
+		LocColor:      loc(c, "color"),
+		LocWidth:      loc(c, "width"),
+		LocScreenSize: loc(c, "screenSize"),
+	}, nil
+}
+
+// Points draws round points with a size in pixels, it needs gl.PROGRAM_POINT_SIZE to be enabled
+type Points struct {
+	Program  uint32
+	LocModel int32
+	LocColor int32
+	LocSize  int32
+}
+
+func NewPoints() (*Points, error) {
+	c, err := buildShader("points", "points")
+	if err != nil {
+		return nil, err
+	}
+	blockIndex := gl.GetUniformBlockIndex(c, gl.Str("Matrices\x00"))
+	gl.UniformBlockBinding(c, blockIndex, 0)
+
+	return &Points{
+		Program:  c,
+		LocModel: loc(c, "model"),
+		LocColor: loc(c, "color"),
+		LocSize:  loc(c, "size"),
+	}, nil
+}
@@ -11,8 +11,11 @@ type Skybox struct {
 	SkyboxVAO        uint32
 }
 
-func NewSkybox() *Skybox {
-	c := buildShader("skybox", "skybox")
+func NewSkybox() (*Skybox, error) {
+	c, err := buildShader("skybox", "skybox")
+	if err != nil {
+		return nil, err
+	}
 	shader := &Skybox{
 		Program:          c,
 		LocScreenTexture: loc(c, "skybox"),
@@ -31,7 +34,7 @@ func NewSkybox() *Skybox {
 	blockIndex := gl.GetUniformBlockIndex(c, gl.Str("Matrices\x00"))
 	gl.UniformBlockBinding(c, blockIndex, 0)
 
-	return shader
+	return shader, nil
 }
 
 var skyboxVertices []float32 = []float32{
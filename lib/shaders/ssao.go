@@ -6,8 +6,11 @@ import (
 	"github.com/go-gl/gl/v4.1-core/gl"
 )
 
-func NewSSAO() *SSAO {
-	c := buildShader("ssao", "ssao")
+func NewSSAO() (*SSAO, error) {
+	c, err := buildShader("ssao", "ssao")
+	if err != nil {
+		return nil, err
+	}
 	s := &SSAO{
 		Program:       c,
 		LocEnabled:    loc(c, "enabled"),
@@ -23,7 +26,7 @@ func NewSSAO() *SSAO {
 	for i := range s.LocSamples {
 		s.LocSamples[i] = loc(c, fmt.Sprintf("samples[%d]", i))
 	}
-	return s
+	return s, nil
 }
 
 type SSAO struct {
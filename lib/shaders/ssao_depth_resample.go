@@ -2,8 +2,11 @@ package shaders
 
 import "github.com/go-gl/gl/v4.1-core/gl"
 
-func NewSSAODepthResampler() *SSAODepthResampler {
-	c := buildShader("ssao", "ssao_depth_resample")
+func NewSSAODepthResampler() (*SSAODepthResampler, error) {
+	c, err := buildShader("ssao", "ssao_depth_resample")
+	if err != nil {
+		return nil, err
+	}
 	s := &SSAODepthResampler{
 		Program:       c,
 		LocGDepth:     loc(c, "gDepth"),
@@ -12,7 +15,7 @@ func NewSSAODepthResampler() *SSAODepthResampler {
 
 	blockIndex := gl.GetUniformBlockIndex(c, gl.Str("Matrices\x00"))
 	gl.UniformBlockBinding(c, blockIndex, 0)
-	return s
+	return s, nil
 }
 
 type SSAODepthResampler struct {
@@ -6,12 +6,15 @@ type SSAOGaussian struct {
 	LocHorizontal    int32
 }
 
-func NewSSAOGaussian() *SSAOGaussian {
-	c := buildShader("fx", "ssao_blur")
+func NewSSAOGaussian() (*SSAOGaussian, error) {
+	c, err := buildShader("fx", "ssao_blur")
+	if err != nil {
+		return nil, err
+	}
 
 	return &SSAOGaussian{
 		Program:          c,
 		LocScreenTexture: loc(c, "screenTexture"),
 		LocHorizontal:    loc(c, "horizontal"),
-	}
+	}, nil
 }
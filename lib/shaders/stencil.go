@@ -1,12 +1,15 @@
 package shaders
 
-func NewStencil() *Stencil {
+func NewStencil() (*Stencil, error) {
 	s := &Stencil{}
-	s.Program = buildShader("null", "null")
+	var err error
+	if s.Program, err = buildShader("null", "null"); err != nil {
+		return nil, err
+	}
 	s.LocModel = loc(s.Program, "model")
 	s.LocView = loc(s.Program, "view")
 	s.LocProjection = loc(s.Program, "projection")
-	return s
+	return s, nil
 }
 
 type Stencil struct {
@@ -2,37 +2,60 @@ package shaders
 
 import (
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"io/fs"
+	"os"
+	"path"
 	"strings"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 )
 
-func buildShader(vertex, frag string) uint32 {
-	vertexShaderSource, err := loadVertexShader(vertex)
-	if err != nil {
-		panic(err)
-	}
-	fragmentShaderSource, err := loadFragShader(frag)
-	if err != nil {
-		panic(err)
-	}
+func buildShader(vertex, frag string) (uint32, error) {
+	return buildStages(stage{vertex + ".vert", gl.VERTEX_SHADER}, stage{frag + ".frag", gl.FRAGMENT_SHADER})
+}
 
-	vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
-	if err != nil {
-		panic(err)
-	}
+// buildGeometryShader is buildShader with a geometry shader between the vertex and fragment shader
+func buildGeometryShader(vertex, geometry, frag string) (uint32, error) {
+	return buildStages(
+		stage{vertex + ".vert", gl.VERTEX_SHADER},
+		stage{geometry + ".geom", gl.GEOMETRY_SHADER},
+		stage{frag + ".frag", gl.FRAGMENT_SHADER},
+	)
+}
+
+// stage is a shader source file in the shaders folder and the type of shader it is
+type stage struct {
+	file       string
+	shaderType uint32
+}
 
-	fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
-	if err != nil {
-		panic(err)
+// buildStages compiles the stages and links them into a shader program
+func buildStages(stages ...stage) (uint32, error) {
+	var compiled []uint32
+	deleteShaders := func() {
+		for _, shader := range compiled {
+			gl.DeleteShader(shader)
+		}
+	}
+	for _, st := range stages {
+		source, err := loadShader(st.file)
+		if err != nil {
+			deleteShaders()
+			return 0, err
+		}
+		shader, err := compileShader(source, st.shaderType)
+		if err != nil {
+			deleteShaders()
+			return 0, fmt.Errorf("%s: %v", st.file, err)
+		}
+		compiled = append(compiled, shader)
 	}
 
 	program := gl.CreateProgram()
 
-	gl.AttachShader(program, vertexShader)
-	gl.AttachShader(program, fragmentShader)
+	for _, shader := range compiled {
+		gl.AttachShader(program, shader)
+	}
 	gl.LinkProgram(program)
 
 	var status int32
@@ -44,25 +67,25 @@ func buildShader(vertex, frag string) uint32 {
 		l := strings.Repeat("\x00", int(logLength+1))
 		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(l))
 
-		panic(fmt.Errorf("failed to link program[%d]: %v", program, l))
+		gl.DeleteProgram(program)
+		deleteShaders()
+		return 0, fmt.Errorf("failed to link program[%d]: %v", program, l)
 	}
 
-	gl.DetachShader(program, vertexShader)
-	gl.DeleteShader(vertexShader)
-	gl.DetachShader(program, fragmentShader)
-	gl.DeleteShader(fragmentShader)
+	for _, shader := range compiled {
+		gl.DetachShader(program, shader)
+		gl.DeleteShader(shader)
+	}
 
 	//glLogShader(program, vertex, frag)
-	return program
+	return program, nil
 }
 
-func loadVertexShader(name string) (string, error) {
-	res, err := ioutil.ReadFile(filepath.Join("shaders", fmt.Sprintf("%s.vert", name)))
-	return string(res) + "\x00", err
-}
+// Files is where the shader sources are read from, in its shaders directory
+var Files fs.FS = os.DirFS(".")
 
-func loadFragShader(name string) (string, error) {
-	res, err := ioutil.ReadFile(filepath.Join("shaders", fmt.Sprintf("%s.frag", name)))
+func loadShader(file string) (string, error) {
+	res, err := fs.ReadFile(Files, path.Join("shaders", file))
 	return string(res) + "\x00", err
 }
 
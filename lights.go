@@ -1,6 +1,9 @@
 package main
 
-import "math"
+import (
+	"fmt"
+	"math"
+)
 
 type DirectionalLight struct {
 	Direction [3]float32
@@ -18,17 +21,28 @@ type PointLight struct {
 	rand     float32
 }
 
+// Radius returns the distance where the light contribution is negligible and panics if it can't be calculated
 func (l *PointLight) Radius() float32 {
+	radius, err := l.CalcRadius()
+	if err != nil {
+		panic(err)
+	}
+	return radius
+}
+
+// CalcRadius returns the distance where the light contribution is negligible
+func (l *PointLight) CalcRadius() (float32, error) {
 	if l.radius != 0 {
-		return l.radius
+		return l.radius, nil
 	}
 	maxChannel := float32(math.Max(math.Max(float64(l.Color[0]), float64(l.Color[1])), float64(l.Color[2])))
 	inner := l.Linear*l.Linear - 2*l.Exp*(l.Exp-(256/1)*maxChannel)
-	l.radius = -l.Linear + float32(math.Sqrt(float64(inner)))/(2*l.Exp)
-	if math.IsNaN(float64(l.radius)) {
-		panic("CalcPointLightBSphere calculated a NaN")
+	radius := -l.Linear + float32(math.Sqrt(float64(inner)))/(2*l.Exp)
+	if math.IsNaN(float64(radius)) || math.IsInf(float64(radius), 0) {
+		return 0, fmt.Errorf("point light radius could not be calculated for color %v and attenuation %v/%v", l.Color, l.Linear, l.Exp)
 	}
-	return l.radius
+	l.radius = radius
+	return l.radius, nil
 }
 
 type LightAttenuation struct {
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// uploadBudget is how long each frame may spend on uploading assets that finished loading
+const uploadBudget = 4 * time.Millisecond
+
+// loadSlots limits how many assets are decoded at the same time
+var loadSlots = make(chan struct{}, runtime.NumCPU())
+
+// loading counts the loads that haven't finished yet
+var loading int32
+
+// loadAsync runs load on a worker goroutine, load must not use OpenGL but can push work to the uploads queue
+func loadAsync(load func()) {
+	atomic.AddInt32(&loading, 1)
+	go func() {
+		loadSlots <- struct{}{}
+		defer func() { <-loadSlots }()
+		defer atomic.AddInt32(&loading, -1)
+		load()
+	}()
+}
+
+// loadsDone returns true when there are no loads in progress and nothing left to upload
+func loadsDone() bool {
+	uploads.Lock()
+	defer uploads.Unlock()
+	return atomic.LoadInt32(&loading) == 0 && len(uploads.jobs) == 0
+}
+
+// uploads is the queue of work that needs the OpenGL context, filled by the loader goroutines and drained by the
+// render loop
+var uploads = &uploadQueue{}
+
+type uploadQueue struct {
+	sync.Mutex
+	jobs []func()
+}
+
+func (q *uploadQueue) push(job func()) {
+	q.Lock()
+	q.jobs = append(q.jobs, job)
+	q.Unlock()
+}
+
+func (q *uploadQueue) pop() func() {
+	q.Lock()
+	defer q.Unlock()
+	if len(q.jobs) == 0 {
+		return nil
+	}
+	job := q.jobs[0]
+	q.jobs[0] = nil
+	q.jobs = q.jobs[1:]
+	return job
+}
+
+// drain runs queued jobs on the calling thread, which must own the OpenGL context, until the budget is used up. At
+// least one job is run so that loading always makes progress.
+func (q *uploadQueue) drain(budget time.Duration) int {
+	start := time.Now()
+	count := 0
+	for {
+		job := q.pop()
+		if job == nil {
+			return count
+		}
+		job()
+		count++
+		if time.Since(start) > budget {
+			return count
+		}
+	}
+}
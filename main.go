@@ -2,7 +2,7 @@
 package main
 
 import (
-	"log"
+	"flag"
 	"math/rand"
 	"os"
 	"runtime"
@@ -32,14 +32,11 @@ func realMain() error {
 	// GLFW event handling must run on the main OS thread
 	runtime.LockOSThread()
 
-	// Set the working directory to the root of Go package, so that its assets can be accessed.
-	dir, err := importPathToDir("github.com/stojg/cspace")
-	if err != nil {
-		log.Fatalln("Unable to find Go package in your GOPATH, it's needed to load assets:", err)
-	}
-
-	if err = os.Chdir(dir); err != nil {
-		log.Panicln("os.Chdir:", err)
+	assetDir := flag.String("assets", "", "directory with shaders, models and textures that replace or add to the built in ones, changes to its models and textures are reloaded")
+	sceneFile := flag.String("scene", "", "scene file to load instead of the built in "+defaultLevel)
+	flag.Parse()
+	if err := useAssetDir(*assetDir); err != nil {
+		return err
 	}
 
 	keys = make(map[glfw.Key]bool)
@@ -61,10 +58,26 @@ func realMain() error {
 		return err
 	}
 
-	scene := NewScene()
-	scene.Init()
+	level, err := ReadLevel(*sceneFile)
+	if err != nil {
+		return err
+	}
+
+	scene, err := NewScene()
+	if err != nil {
+		return err
+	}
+	if err := scene.Init(); err != nil {
+		return err
+	}
+	if err := scene.Load(level); err != nil {
+		return err
+	}
 
-	PBRLevel(scene.graph)
+	// reload assets when they're changed on disk
+	if *assetDir != "" {
+		watchAssetDir(*assetDir)
+	}
 
 	previousTime := glfw.GetTime()
 	for !window.ShouldClose() {
@@ -72,11 +85,17 @@ func realMain() error {
 		now := glfw.GetTime()
 		elapsed := (now - previousTime)
 		previousTime = now
+		if uploads.drain(uploadBudget) > 0 && loadsDone() {
+			numTextures, textureMemory := TextureMemory()
+			glLogf("assets loaded, %d textures using %.1f MiB of video memory\n", numTextures, float64(textureMemory)/(1<<20))
+		}
 		scene.Render(elapsed)
 		fpsCounter(window)
 		window.SwapBuffers()
 	}
 
+	scene.Clear()
+	releaseCube()
 	window.Destroy()
 
 	return nil
@@ -110,14 +129,19 @@ func renderQuad() {
 	gl.BindVertexArray(0)
 }
 
-var cubeVAO *Mesh
+var cubeModel []*Mesh
 
-// renderQuad renders a full screen quad
+// renderCube renders a unit cube
 func renderCube() {
-	if cubeVAO == nil {
-		meshes := LoadModel("models/cube", TexturedMesh)
-		cubeVAO = meshes[0]
+	if cubeModel == nil {
+		cubeModel = LoadModel("models/cube", TexturedMesh)
 	}
-	cubeVAO.Render()
+	cubeModel[0].Render()
 	gl.BindVertexArray(0)
 }
+
+// releaseCube releases the cube model that renderCube loaded
+func releaseCube() {
+	assets.ReleaseModel(cubeModel)
+	cubeModel = nil
+}
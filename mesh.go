@@ -6,76 +6,217 @@ import (
 	"unsafe"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/stojg/cspace/lib/geom"
 	"github.com/stojg/cspace/lib/obj"
+	"github.com/stojg/cspace/lib/scenefile"
 )
 
-type Vertex struct {
-	Position  [3]float32
-	Normal    [3]float32
-	TexCoords [2]float32
-	Tangent   [3]float32
+// Vertex is the interleaved vertex layout shared with the asset converters
+type Vertex = geom.Vertex
+
+func NewMesh(name string, vertices []Vertex, indices []uint32, textures []*Texture, mat *obj.Material, shaderType ShaderType) *Mesh {
+	return newMesh(name, vertices, indices, geom.BoundingVolumeOf(vertices), textures, mat, shaderType)
 }
 
-func NewMesh(name string, vertices []Vertex, textures []*Texture, mat *obj.Material, shaderType ShaderType) *Mesh {
+// newMesh is NewMesh for vertices that the bounds are already known for, like the ones from a mesh cache
+func newMesh(name string, vertices []Vertex, indices []uint32, bounds geom.BoundingVolume, textures []*Texture, mat *obj.Material, shaderType ShaderType) *Mesh {
 	q := &Mesh{
 		Name:        name,
 		Vertices:    vertices,
 		NumVertices: int32(len(vertices)),
+		Indices:     indices,
+		Attributes:  geom.AttributesOf(vertices),
+		Bounds:      bounds,
 		Textures:    textures,
 		Material:    mat,
+		LineWidth:   DefaultLineWidth,
+		PointSize:   DefaultPointSize,
+
+		AlbedoFactor:    [3]float32{1, 1, 1},
+		MetallicFactor:  1,
+		RoughnessFactor: 1,
 	}
 	q.MeshType = shaderType
 	q.init()
 	return q
 }
 
+// The line width and point size in pixels that new meshes get
+const (
+	DefaultLineWidth float32 = 1
+	DefaultPointSize float32 = 3
+)
+
 type Mesh struct {
-	Name        string
+	Name string
+	// Primitive is how the vertices are drawn, lines and points are drawn unlit in the forward pass with the albedo
+	// colour instead of going through the gBuffer
+	Primitive   obj.Primitive
 	Vertices    []Vertex
 	NumVertices int32
 	Indices     []uint32
-	Textures    []*Texture
-	Material    *obj.Material
-	MeshType    ShaderType
+	// Attributes are the optional vertex attributes, vertex colours and a second UV set, that the mesh uses
+	Attributes geom.Attributes
+	// Bounds are around the vertices in the space of the mesh, used to skip meshes that are out of view
+	Bounds   geom.BoundingVolume
+	Textures []*Texture
+	Material *obj.Material
+	MeshType ShaderType
 	// PRB material
 	Albedo    [3]float32
 	Metallic  float32
 	Roughness float32
+	Emissive  [3]float32 // multiplied with the emissive texture of textured meshes
+	// AlbedoFactor, MetallicFactor and RoughnessFactor are multiplied with the textures of textured meshes, they're 1
+	// unless the model scales its textures like glTF materials do
+	AlbedoFactor    [3]float32
+	MetallicFactor  float32
+	RoughnessFactor float32
+	// TexCoordSets is the UV set that each texture type is mapped with, 1 for the second set of meshes that have one.
+	// Texture types that aren't in it use the first set.
+	TexCoordSets map[TextureType]int
+	// LineWidth and PointSize are in pixels
+	LineWidth float32
+	PointSize float32
+
+	buffers *meshBuffers
+	model   *modelAsset // the cached model this mesh was handed out from, if any
+	// levelMaterial is the scene file material that was applied to the mesh, and its name, kept for saving the scene
+	levelMaterial     *scenefile.Material
+	levelMaterialName string
+}
 
-	vbo, vao uint32
+// meshBuffers are the OpenGL objects of a mesh, shared by every instance of a cached model
+type meshBuffers struct {
+	vbo, vao, ebo uint32
+	count         int32 // number of indices, or vertices when the mesh isn't indexed
+	indexed       bool
+	attributes    geom.Attributes
 }
 
 func (s *Mesh) Render() {
-	gl.BindVertexArray(s.vao)
-	gl.DrawArrays(gl.TRIANGLES, 0, s.NumVertices)
+	mode := uint32(gl.TRIANGLES)
+	switch s.Primitive {
+	case obj.Lines:
+		mode = gl.LINES
+	case obj.Points:
+		mode = gl.POINTS
+	}
+	// attributes without an array read the current value of the attribute, which isn't part of the vao
+	if s.buffers.attributes&geom.Colors == 0 {
+		gl.VertexAttrib4f(4, 1, 1, 1, 1)
+	}
+	if s.buffers.attributes&geom.TexCoords2 == 0 {
+		gl.VertexAttrib2f(5, 0, 0)
+	}
+	gl.BindVertexArray(s.buffers.vao)
+	if s.buffers.indexed {
+		gl.DrawElements(mode, s.buffers.count, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		return
+	}
+	gl.DrawArrays(mode, 0, s.buffers.count)
+}
+
+// instance returns a copy of the mesh that shares the vertex buffers but has its own textures and material values
+func (s *Mesh) instance() *Mesh {
+	m := *s
+	m.Textures = append([]*Texture(nil), s.Textures...)
+	return &m
 }
 
+// delete releases the OpenGL buffers
+func (b *meshBuffers) delete() {
+	gl.DeleteVertexArrays(1, &b.vao)
+	gl.DeleteBuffers(1, &b.vbo)
+	if b.indexed {
+		gl.DeleteBuffers(1, &b.ebo)
+	}
+	*b = meshBuffers{}
+}
+
+// textureSlots are the texture inputs of the textured shader, each bound to its own texture unit
+var textureSlots = []TextureType{Albedo, Metallic, Roughness, Normal, AO, Emissive}
+
+// channelMasks select the red, green, blue or alpha channel with a dot product
+var channelMasks = [4][4]float32{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+
 func (s *Mesh) setTextures(tShader *GbufferTShader) {
-	for i := range s.Textures {
-		GLBindTexture(i, tShader.TextureUniform(s.Textures[i].textureType), s.Textures[i].ID)
+	for unit, texType := range textureSlots {
+		texture, channel := s.texture(texType)
+		GLBindTexture(unit, tShader.TextureUniform(texType), texture.ID)
+		if loc := tShader.ChannelUniform(texType); loc != -1 {
+			gl.Uniform4fv(loc, 1, &channelMasks[channel][0])
+		}
+		gl.Uniform1i(tShader.TexCoordsUniform(texType), int32(s.TexCoordSets[texType]))
+		topDown := int32(0)
+		if texture.TopDown {
+			topDown = 1
+		}
+		gl.Uniform1i(tShader.TopDownUniform(texType), topDown)
+		if texType == Normal {
+			twoChannel := int32(0)
+			if texture.twoChannel() {
+				twoChannel = 1
+			}
+			gl.Uniform1i(tShader.LocNormalTwoChannel, twoChannel)
+		}
 	}
+	gl.Uniform3f(tShader.LocEmissiveFactor, s.Emissive[0], s.Emissive[1], s.Emissive[2])
+	gl.Uniform3f(tShader.LocAlbedoFactor, s.AlbedoFactor[0], s.AlbedoFactor[1], s.AlbedoFactor[2])
+	gl.Uniform1f(tShader.LocMetallicFactor, s.MetallicFactor)
+	gl.Uniform1f(tShader.LocRoughnessFactor, s.RoughnessFactor)
+}
+
+// texture returns the texture and the channel that carries the texture type, meshes that doesn't have one get a
+// neutral texture
+func (s *Mesh) texture(texType TextureType) (*Texture, int) {
+	for _, texture := range s.Textures {
+		if channel, ok := texture.channel(texType); ok {
+			return texture, channel
+		}
+	}
+	return neutralTexture(texType), 0
 }
 
 func (s *Mesh) setMaterial(mShader *GbufferMShader) {
 	gl.Uniform3f(mShader.LocAlbedo, s.Albedo[0], s.Albedo[1], s.Albedo[2])
 	gl.Uniform1f(mShader.LocMetallic, s.Metallic)
 	gl.Uniform1f(mShader.LocRoughness, s.Roughness)
+	gl.Uniform3f(mShader.LocEmissive, s.Emissive[0], s.Emissive[1], s.Emissive[2])
 }
 
 func (s *Mesh) init() {
+	s.buffers = newMeshBuffers(s.Vertices, s.Indices, s.Attributes)
+}
+
+func newMeshBuffers(vertices []Vertex, indices []uint32, attributes geom.Attributes) *meshBuffers {
 	const sizeOfFloat = 4
 
+	b := &meshBuffers{
+		count:      int32(len(vertices)),
+		indexed:    len(indices) > 0,
+		attributes: attributes,
+	}
+
 	// Create buffers/arrays
-	gl.GenVertexArrays(1, &s.vao)
-	gl.GenBuffers(1, &s.vbo)
+	gl.GenVertexArrays(1, &b.vao)
+	gl.GenBuffers(1, &b.vbo)
 
-	gl.BindVertexArray(s.vao)
+	gl.BindVertexArray(b.vao)
 
 	// load data into vertex buffers
-	gl.BindBuffer(gl.ARRAY_BUFFER, s.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
 
 	size := int32(unsafe.Sizeof(Vertex{}))
-	gl.BufferData(gl.ARRAY_BUFFER, len(s.Vertices)*int(size), gl.Ptr(s.Vertices), gl.STATIC_DRAW)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*int(size), gl.Ptr(vertices), gl.STATIC_DRAW)
+
+	// load the indices into the element buffer, the binding is stored in the vao
+	if b.indexed {
+		b.count = int32(len(indices))
+		gl.GenBuffers(1, &b.ebo)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, b.ebo)
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+	}
 
 	// vertex position
 	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, size, gl.PtrOffset(0))
@@ -89,69 +230,26 @@ func (s *Mesh) init() {
 	gl.VertexAttribPointer(2, 2, gl.FLOAT, false, size, gl.PtrOffset(6*sizeOfFloat))
 	gl.EnableVertexAttribArray(2)
 
-	// tangents
-	gl.VertexAttribPointer(3, 3, gl.FLOAT, false, size, gl.PtrOffset(8*sizeOfFloat))
+	// tangents and their handedness
+	gl.VertexAttribPointer(3, 4, gl.FLOAT, false, size, gl.PtrOffset(8*sizeOfFloat))
 	gl.EnableVertexAttribArray(3)
 
+	// the optional attributes are only read from the buffer when the mesh has them, see Render
+	if attributes&geom.Colors != 0 {
+		gl.VertexAttribPointer(4, 4, gl.FLOAT, false, size, gl.PtrOffset(12*sizeOfFloat))
+		gl.EnableVertexAttribArray(4)
+	}
+	if attributes&geom.TexCoords2 != 0 {
+		gl.VertexAttribPointer(5, 2, gl.FLOAT, false, size, gl.PtrOffset(16*sizeOfFloat))
+		gl.EnableVertexAttribArray(5)
+	}
+
 	// reset, so no other graph accidentally changes this vao
 	gl.BindVertexArray(0)
-}
-
-func edge(a, b Vertex) [3]float32 {
-	return [3]float32{
-		a.Position[0] - b.Position[0],
-		a.Position[1] - b.Position[1],
-		a.Position[2] - b.Position[2],
-	}
+	return b
 }
 
 func normalise(vec [3]float32) [3]float32 {
 	l := 1.0 / float32(math.Sqrt(float64(vec[0]*vec[0]+vec[1]*vec[1]+vec[2]*vec[2])))
 	return [3]float32{vec[0] * l, vec[1] * l, vec[2] * l}
 }
-
-func getVertices(meshdata []float32) []Vertex {
-	const stride = 8
-
-	if len(meshdata)%stride != 0 {
-		panic("the graph data is not a multiple of 8, want [3]Pos, [3]Normals, [2]TexCoords")
-	}
-	var vertices []Vertex
-
-	for i := 0; i < len(meshdata); i += stride {
-		var vertex Vertex
-		copy(vertex.Position[:], meshdata[i:i+3])
-		copy(vertex.Normal[:], meshdata[i+3:i+6])
-		copy(vertex.TexCoords[:], meshdata[i+6:i+8])
-		vertices = append(vertices, vertex)
-	}
-
-	// calculate tangents and bi-tangents
-	for i := 0; i < len(vertices); i += 3 {
-		v0 := vertices[i]
-		v1 := vertices[i+1]
-		v2 := vertices[i+2]
-
-		edge1 := edge(v1, v0)
-		edge2 := edge(v2, v0)
-
-		deltaU1 := v1.TexCoords[0] - v0.TexCoords[0]
-		deltaV1 := v1.TexCoords[1] - v0.TexCoords[1]
-		deltaU2 := v2.TexCoords[0] - v0.TexCoords[0]
-		deltaV2 := v2.TexCoords[1] - v0.TexCoords[1]
-
-		f := 1.0 / (deltaU1*deltaV2 - deltaU2*deltaV1)
-
-		var tangent [3]float32
-		tangent[0] = f * (deltaV2*edge1[0] - deltaV1*edge2[0])
-		tangent[1] = f * (deltaV2*edge1[1] - deltaV1*edge2[1])
-		tangent[2] = f * (deltaV2*edge1[2] - deltaV1*edge2[2])
-
-		tangent = normalise(tangent)
-
-		copy(vertices[i].Tangent[:], tangent[:])
-		copy(vertices[i+1].Tangent[:], tangent[:])
-		copy(vertices[i+2].Tangent[:], tangent[:])
-	}
-	return vertices
-}
@@ -1,50 +1,190 @@
 package main
 
 import (
-	"path/filepath"
+	"fmt"
+	"io/fs"
+	"path"
 
+	"github.com/stojg/cspace/lib/geom"
+	"github.com/stojg/cspace/lib/meshcache"
 	"github.com/stojg/cspace/lib/obj"
 )
 
+// LoadModel returns the meshes of the model in the directory, the model is cached and shared through the Assets manager
 func LoadModel(directory string, shaderType ShaderType) []*Mesh {
+	return assets.Model(directory, shaderType)
+}
+
+// LoadModelAsync loads the model in the background and calls ready with the meshes from the render loop when they
+// have been uploaded, see Assets.ModelAsync
+func LoadModelAsync(directory string, shaderType ShaderType, ready func([]*Mesh)) {
+	assets.ModelAsync(directory, shaderType, ready)
+}
+
+// textureLoader is the signature of Assets.Texture, so that models can load their textures directly or in the
+// background
+type textureLoader func(texType TextureType, file string, gammaCorrect bool) (*Texture, error)
+
+// buildModel uploads the mesh data returned by loadMeshData and loads the textures of the meshes
+func buildModel(directory string, shaderType ShaderType, meshes []*meshcache.Mesh, err error, loadTexture textureLoader) []*Mesh {
 
 	var result []*Mesh
 
-	filePath := filepath.Join(directory, "model.obj")
-	objects := obj.LoadObject(filePath)
+	if err != nil {
+		glError(fmt.Errorf("LoadModel: %v, using a fallback cube", err))
+		return fallbackCube(shaderType)
+	}
 
-	for _, object := range objects {
-		glLogf("--- Loaded %s ----\n", object.Name)
-		glLogf("size %d bytes\n", len(object.Data))
+	for _, data := range meshes {
+		glLogf("--- Loaded %s ----\n", data.Name)
 
-		vertices := getVertices(object.Data)
-		var textures []*Texture
+		textures := materialTextures(data.Material, loadTexture)
+		if len(textures) == 0 {
+			textures = directoryTextures(directory, loadTexture)
+		}
 
-		diffuseTexture, err := newTexture(Albedo, filepath.Join(directory, "d.png"), false)
-		if err == nil {
-			textures = append(textures, diffuseTexture)
+		glLogf("%s, vertices %d, indices %d\n", data.Primitive, len(data.Vertices), len(data.Indices))
+		glLogf("textures %d \n", len(textures))
+		glLogln("------------------------")
+
+		mesh := newMesh(data.Name, data.Vertices, data.Indices, data.Bounds, textures, data.Material, shaderType)
+		mesh.Primitive = data.Primitive
+		mesh.Albedo = data.Material.Diffuse
+		mesh.Metallic = data.Material.Metallic
+		mesh.Roughness = data.Material.Roughness
+		mesh.Emissive = data.Material.Emissive
+		// Ke scales map_Ke, but most exporters leave it at zero when there is a map
+		if data.Material.EmissiveMap != "" && mesh.Emissive == [3]float32{} {
+			mesh.Emissive = [3]float32{1, 1, 1}
 		}
+		result = append(result, mesh)
+	}
+	return result
+}
+
+// loadMeshData reads the model.mesh cache created by cspace-meshconv if it's newer than the model.obj and its
+// material libraries, otherwise it parses and processes the model.obj. It doesn't use OpenGL and is safe to call from
+// any goroutine.
+func loadMeshData(directory string) ([]*meshcache.Mesh, error) {
+	filePath := path.Join(directory, "model.obj")
+	cachePath := path.Join(directory, "model"+meshcache.Extension)
 
-		specularTexture, err := newTexture(Metallic, filepath.Join(directory, "s.png"), false)
+	sources, _ := fs.Glob(assetFS, path.Join(directory, "*.mtl"))
+	sources = append(sources, filePath)
+	if meshcache.IsFreshFS(assetFS, cachePath, sources...) {
+		meshes, err := meshcache.ReadFileFS(assetFS, cachePath)
 		if err == nil {
-			textures = append(textures, specularTexture)
+			glLogf("using mesh cache %s\n", cachePath)
+			return meshes, nil
 		}
+		glError(fmt.Errorf("LoadModel: %v, falling back to %s", err, filePath))
+	}
 
-		normalTexture, err := newTexture(Normal, filepath.Join(directory, "n.png"), false)
-		if err == nil {
-			textures = append(textures, normalTexture)
+	objects, err := obj.LoadFS(assetFS, filePath, obj.Options{})
+	if err != nil {
+		return nil, err
+	}
+	meshes, err := meshcache.FromObjects(objects)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+	return meshes, nil
+}
+
+// materialTextures loads the texture maps referenced by the material
+func materialTextures(mat *obj.Material, loadTexture textureLoader) []*Texture {
+	metallicMap := mat.MetallicMap
+	if metallicMap == "" {
+		metallicMap = mat.SpecularMap
+	}
+	maps := []struct {
+		textureType  TextureType
+		file         string
+		gammaCorrect bool
+	}{
+		{Albedo, mat.DiffuseMap, true},
+		{Metallic, metallicMap, false},
+		{Roughness, mat.RoughnessMap, false},
+		{Normal, mat.NormalMap, false},
+		{AO, mat.AOMap, false},
+		{Emissive, mat.EmissiveMap, true},
+	}
+
+	var textures []*Texture
+	for _, m := range maps {
+		if m.file == "" {
+			continue
 		}
+		texture, err := loadTexture(m.textureType, m.file, m.gammaCorrect)
+		if err != nil {
+			glError(fmt.Errorf("material %s: %v, using a fallback texture", mat.Name, err))
+			texture = FallbackTexture(m.textureType)
+		}
+		textures = append(textures, texture)
+	}
+	return textures
+}
 
-		roughnessTexture, err := newTexture(Roughness, filepath.Join(directory, "r.png"), false)
+// directoryTextures loads the textures named by convention, d.png, s.png, n.png and r.png, from the model directory
+func directoryTextures(directory string, loadTexture textureLoader) []*Texture {
+	var textures []*Texture
+	conventions := []struct {
+		textureType TextureType
+		file        string
+	}{
+		{Albedo, "d.png"},
+		{Metallic, "s.png"},
+		{Normal, "n.png"},
+		{Roughness, "r.png"},
+		{AO, "ao.png"},
+		{Emissive, "e.png"},
+	}
+	for _, c := range conventions {
+		file := path.Join(directory, c.file)
+		// the textures are optional, so don't let a missing file turn into a fallback texture
+		if _, err := fs.Stat(assetFS, file); err != nil {
+			continue
+		}
+		texture, err := loadTexture(c.textureType, file, false)
 		if err == nil {
-			textures = append(textures, roughnessTexture)
+			textures = append(textures, texture)
 		}
+	}
+	return textures
+}
 
-		glLogf("textures %d \n", len(textures))
-		glLogln("------------------------")
-
-		result = append(result, NewMesh(object.Name, vertices, textures, object.Mtr, shaderType))
+// fallbackCube returns a cube with the same extent as models/cube that stands in for models that failed to load
+func fallbackCube(shaderType ShaderType) []*Mesh {
+	// the normal and the two axes spanning each face, u cross v equals the normal
+	faces := [6][3][3]float32{
+		{{1, 0, 0}, {0, 0, -1}, {0, 1, 0}},
+		{{-1, 0, 0}, {0, 0, 1}, {0, 1, 0}},
+		{{0, 1, 0}, {1, 0, 0}, {0, 0, -1}},
+		{{0, -1, 0}, {1, 0, 0}, {0, 0, 1}},
+		{{0, 0, 1}, {1, 0, 0}, {0, 1, 0}},
+		{{0, 0, -1}, {-1, 0, 0}, {0, 1, 0}},
 	}
-	return result
+	corners := [6][2]float32{{0, 0}, {1, 0}, {1, 1}, {0, 0}, {1, 1}, {0, 1}}
 
+	var data []float32
+	for _, face := range faces {
+		n, u, v := face[0], face[1], face[2]
+		for _, c := range corners {
+			a, b := c[0]*2-1, c[1]*2-1
+			for i := 0; i < 3; i++ {
+				data = append(data, n[i]+u[i]*a+v[i]*b)
+			}
+			data = append(data, n[0], n[1], n[2], c[0], c[1])
+		}
+	}
+	triangles, _ := geom.Triangles(data)
+	vertices, indices := geom.Index(triangles)
+	var textures []*Texture
+	for _, t := range []TextureType{Albedo, Metallic, Roughness, Normal} {
+		textures = append(textures, FallbackTexture(t))
+	}
+	mesh := NewMesh("fallback_cube", vertices, indices, textures, obj.NewMaterial(), shaderType)
+	mesh.Albedo = [3]float32{1, 0, 1}
+	mesh.Roughness = 1
+	return []*Mesh{mesh}
 }
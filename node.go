@@ -3,6 +3,9 @@ package main
 import (
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stojg/cspace/lib/obj"
+	"github.com/stojg/cspace/lib/scenefile"
+	"github.com/stojg/cspace/lib/shaders"
 )
 
 type ShaderType int
@@ -13,30 +16,50 @@ const (
 )
 
 type SceneNode interface {
-	SimpleRender(ModelShader)
-	Render(tShader *GbufferTShader, mShader *GbufferMShader)
-	Add(mesh []*Mesh, transform mgl32.Mat4)
+	NodeParent
+	SimpleRender(shader ModelShader, frustum *Frustum) CullStats
+	Render(tShader *GbufferTShader, mShader *GbufferMShader, frustum *Frustum) CullStats
+	RenderForward(lShader *shaders.Lines, pShader *shaders.Points)
+	Children() []*Node
+	Find(name string) *Node
+	FindTagged(tag string) []*Node
+}
+
+// NodeParent is what nodes can be added below, the root of the scene graph or any of its nodes
+type NodeParent interface {
+	Add(mesh []*Mesh, transform mgl32.Mat4) *Node
+	AddChild(child *Node)
 }
 
 func NewBaseNode() SceneNode {
-	origin := mgl32.Translate3D(0, 0, 0)
 	q := &BaseNode{
 		Node: Node{
-			transform: &origin,
+			transform: mgl32.Ident4(),
+			world:     mgl32.Ident4(),
 		},
 	}
 	return q
 }
 
+// BaseNode is the root of the scene graph
 type BaseNode struct {
 	Node
 }
 
-func (n *BaseNode) Render(tShader *GbufferTShader, mShader *GbufferMShader) {
+// Render draws the triangle meshes that are inside the frustum into the gBuffer
+func (n *BaseNode) Render(tShader *GbufferTShader, mShader *GbufferMShader, frustum *Frustum) CullStats {
+	var stats CullStats
 	var tMeshes []*Node
 	var mMeshes []*Node
-	children := n.Node.Children()
-	for _, child := range children {
+	for _, child := range n.Node.meshNodes() {
+		if child.mesh.Primitive != obj.Triangles {
+			continue
+		}
+		if !frustum.Visible(child.mesh.Bounds, child.World()) {
+			stats.Culled++
+			continue
+		}
+		stats.Drawn++
 		if child.mesh.MeshType == TexturedMesh {
 			tMeshes = append(tMeshes, child)
 		} else if child.mesh.MeshType == MaterialMesh {
@@ -46,54 +69,320 @@ func (n *BaseNode) Render(tShader *GbufferTShader, mShader *GbufferMShader) {
 
 	gl.UseProgram(tShader.Program())
 	for i := range tMeshes {
-		gl.UniformMatrix4fv(tShader.LocModel, 1, false, &tMeshes[i].transform[0])
+		world := tMeshes[i].World()
+		gl.UniformMatrix4fv(tShader.LocModel, 1, false, &world[0])
 		tMeshes[i].mesh.setTextures(tShader)
 		tMeshes[i].mesh.Render()
 	}
 
 	gl.UseProgram(mShader.Program())
 	for i := range mMeshes {
-		gl.UniformMatrix4fv(mShader.LocModel, 1, false, &mMeshes[i].transform[0])
+		world := mMeshes[i].World()
+		gl.UniformMatrix4fv(mShader.LocModel, 1, false, &world[0])
 		mMeshes[i].mesh.setMaterial(mShader)
 		mMeshes[i].mesh.Render()
 	}
+	return stats
 }
 
-func (n *BaseNode) SimpleRender(shader ModelShader) {
-	for _, child := range n.Node.Children() {
-		child.SimpleRender(shader)
+// RenderForward draws the line and point meshes unlit with their albedo colour
+func (n *BaseNode) RenderForward(lShader *shaders.Lines, pShader *shaders.Points) {
+	var lines []*Node
+	var points []*Node
+	for _, child := range n.Node.meshNodes() {
+		if child.mesh.Primitive == obj.Lines {
+			lines = append(lines, child)
+		} else if child.mesh.Primitive == obj.Points {
+			points = append(points, child)
+		}
+	}
+
+	gl.UseProgram(lShader.Program)
+	for i := range lines {
+		world := lines[i].World()
+		gl.UniformMatrix4fv(lShader.LocModel, 1, false, &world[0])
+		gl.Uniform3fv(lShader.LocColor, 1, &lines[i].mesh.Albedo[0])
+		gl.Uniform1f(lShader.LocWidth, lines[i].mesh.LineWidth)
+		lines[i].mesh.Render()
+	}
+
+	gl.UseProgram(pShader.Program)
+	for i := range points {
+		world := points[i].World()
+		gl.UniformMatrix4fv(pShader.LocModel, 1, false, &world[0])
+		gl.Uniform3fv(pShader.LocColor, 1, &points[i].mesh.Albedo[0])
+		gl.Uniform1f(pShader.LocSize, points[i].mesh.PointSize)
+		points[i].mesh.Render()
 	}
 }
 
+// SimpleRender draws the triangle meshes that are inside the frustum with only the model transform, for depth passes
+func (n *BaseNode) SimpleRender(shader ModelShader, frustum *Frustum) CullStats {
+	var stats CullStats
+	if n.hidden {
+		return stats
+	}
+	for _, child := range n.Node.children {
+		child.simpleRender(shader, frustum, &stats)
+	}
+	return stats
+}
+
+// Node is a transform relative to its parent, and optionally a mesh that is drawn with the world transform. The nodes
+// returned by Add are handles that game code can use to move, hide and remove parts of the scene while it's rendered,
+// from the goroutine that runs the render loop.
 type Node struct {
+	name      string
+	tags      []string
+	parent    *Node
 	children  []*Node
-	transform *mgl32.Mat4
-	mesh      *Mesh
+	transform mgl32.Mat4
+	// world is the cached product of the transforms from the root down to this node, it's recalculated when dirty
+	world  mgl32.Mat4
+	dirty  bool
+	hidden bool
+	mesh   *Mesh
+
+	// trs is the transform as it was written in a scene file, so that it's saved the same way, it's dropped when the
+	// transform changes
+	trs *scenefile.Transform
+	// repeat is set on the copies of a scene file node with a repeat, so that they're saved as that node again
+	repeat *levelRepeat
+	// gltf is the file the nodes below this one were loaded from, imported is set on those nodes
+	gltf     string
+	imported bool
+
+	// models are the meshes that Add and addMeshes attached below this node, each holding a reference to its model
+	// that Release hands back
+	models [][]*Mesh
+	// free deletes what a loader created for the nodes below this one and doesn't share, like the meshes of a glTF file
+	free     func()
+	released bool
 }
 
-func (n *Node) SimpleRender(shader ModelShader) {
-	gl.UniformMatrix4fv(shader.ModelUniform(), 1, false, &n.transform[0])
-	n.mesh.Render()
-	for _, child := range n.children {
-		child.SimpleRender(shader)
+// Name returns the name that Find looks for
+func (n *Node) Name() string {
+	return n.name
+}
+
+// SetName names the node, names don't have to be unique
+func (n *Node) SetName(name string) {
+	n.name = name
+}
+
+// Tag adds tags that FindTagged looks for
+func (n *Node) Tag(tags ...string) {
+	for _, tag := range tags {
+		if !n.HasTag(tag) {
+			n.tags = append(n.tags, tag)
+		}
 	}
 }
 
+func (n *Node) HasTag(tag string) bool {
+	for _, t := range n.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Visible returns false when the node, but not necessarily one of its parents, is hidden
+func (n *Node) Visible() bool {
+	return !n.hidden
+}
+
+// SetVisible shows or hides the node and every node below it
+func (n *Node) SetVisible(visible bool) {
+	n.hidden = !visible
+}
+
+// Children returns the nodes attached to this one, the slice must not be changed
 func (n *Node) Children() []*Node {
-	var children []*Node
+	return n.children
+}
+
+// Parent returns the node this one is attached to, or nil for the root and removed nodes
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Remove detaches the node, and the nodes below it, from the scene. The meshes are kept, so that the node can be
+// added back with AddChild, use Release for nodes that are done with.
+func (n *Node) Remove() {
+	if n.parent == nil {
+		return
+	}
+	siblings := n.parent.children
+	for i, child := range siblings {
+		if child == n {
+			n.parent.children = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	n.parent = nil
+	n.setDirty()
+}
+
+// Release removes the node and releases the models of the meshes below it, their buffers and textures are deleted once
+// no other node uses them. The node must not be used afterwards.
+func (n *Node) Release() {
+	n.Remove()
+	n.release()
+}
+
+func (n *Node) release() {
 	for _, child := range n.children {
-		children = append(children, child)
-		children = append(children, child.Children()...)
+		child.release()
 	}
-	return children
+	for _, meshes := range n.models {
+		assets.ReleaseModel(meshes)
+	}
+	if n.free != nil {
+		n.free()
+	}
+	n.children, n.models, n.free, n.mesh = nil, nil, nil, nil
+	n.released = true
 }
 
-func (n *Node) Add(mesh []*Mesh, transform mgl32.Mat4) {
-	for i := range mesh {
-		child := &Node{
-			mesh:      mesh[i],
-			transform: &transform,
+// AddChild attaches a node, that was removed or is attached somewhere else, below this one. The child keeps its
+// transform, which is now relative to this node. Adding a node below itself is ignored.
+func (n *Node) AddChild(child *Node) {
+	for p := n; p != nil; p = p.parent {
+		if p == child {
+			return
+		}
+	}
+	child.Remove()
+	child.parent = n
+	n.children = append(n.children, child)
+	child.setDirty()
+}
+
+// Find returns the first node below this one, depth first, with the name or nil if there isn't one
+func (n *Node) Find(name string) *Node {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+		if found := child.Find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// FindTagged returns every node below this one with the tag, depth first
+func (n *Node) FindTagged(tag string) []*Node {
+	var found []*Node
+	for _, child := range n.children {
+		if child.HasTag(tag) {
+			found = append(found, child)
 		}
-		n.children = append(n.children, child)
+		found = append(found, child.FindTagged(tag)...)
+	}
+	return found
+}
+
+// Transform returns the transform relative to the parent
+func (n *Node) Transform() mgl32.Mat4 {
+	return n.transform
+}
+
+// SetTransform changes the transform relative to the parent, which moves every node below this one too
+func (n *Node) SetTransform(transform mgl32.Mat4) {
+	n.transform = transform
+	n.trs = nil
+	n.setDirty()
+}
+
+// World returns the transform from the node to world space
+func (n *Node) World() mgl32.Mat4 {
+	if n.dirty {
+		if n.parent != nil {
+			n.world = n.parent.World().Mul4(n.transform)
+		} else {
+			n.world = n.transform
+		}
+		n.dirty = false
+	}
+	return n.world
+}
+
+// setDirty marks the world transform of the node and the nodes below it as out of date. The descendants of a dirty
+// node are always dirty, so the walk stops at nodes that already are.
+func (n *Node) setDirty() {
+	if n.dirty {
+		return
+	}
+	n.dirty = true
+	for _, child := range n.children {
+		child.setDirty()
+	}
+}
+
+func (n *Node) simpleRender(shader ModelShader, frustum *Frustum, stats *CullStats) {
+	if n.hidden {
+		return
+	}
+	// lines and points don't cast shadows
+	if n.mesh != nil && n.mesh.Primitive == obj.Triangles {
+		world := n.World()
+		if frustum.Visible(n.mesh.Bounds, world) {
+			gl.UniformMatrix4fv(shader.ModelUniform(), 1, false, &world[0])
+			n.mesh.Render()
+			stats.Drawn++
+		} else {
+			stats.Culled++
+		}
+	}
+	for _, child := range n.children {
+		child.simpleRender(shader, frustum, stats)
+	}
+}
+
+// meshNodes returns the visible nodes below this one that have a mesh, depth first
+func (n *Node) meshNodes() []*Node {
+	var nodes []*Node
+	for _, child := range n.children {
+		if child.hidden {
+			continue
+		}
+		if child.mesh != nil {
+			nodes = append(nodes, child)
+		}
+		nodes = append(nodes, child.meshNodes()...)
+	}
+	return nodes
+}
+
+// Add adds a child node with the transform, relative to this node, and a child of that for each of the meshes. The
+// returned node moves, hides or removes all of the meshes together, and Release on it releases the model of the meshes.
+func (n *Node) Add(mesh []*Mesh, transform mgl32.Mat4) *Node {
+	group := n.addChild(transform, nil)
+	group.addMeshes(mesh)
+	return group
+}
+
+// addMeshes adds a child without a transform for each of the meshes, used to fill in a node once its model has loaded.
+// The node takes over the reference to the model of the meshes.
+func (n *Node) addMeshes(mesh []*Mesh) {
+	if len(mesh) > 0 {
+		n.models = append(n.models, mesh)
+	}
+	for i := range mesh {
+		n.addChild(mgl32.Ident4(), mesh[i])
+	}
+}
+
+func (n *Node) addChild(transform mgl32.Mat4, mesh *Mesh) *Node {
+	child := &Node{
+		parent:    n,
+		transform: transform,
+		mesh:      mesh,
+		dirty:     true,
 	}
+	n.children = append(n.children, child)
+	return child
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
+	"time"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
@@ -30,23 +32,38 @@ var fxaaOn = true
 var dirLightOn = true
 var skyBoxOn = true
 var showDebug = false
+var saveScreenshot = false
+var saveScene = false
 
 var currentNumLights = 0
 
+// gBufferCulling and shadowCulling are the meshes drawn and culled in the last frame, shown with the frame rate
+var gBufferCulling, shadowCulling CullStats
+
 var directionLight = &DirectionalLight{
 	Direction: normalise([3]float32{1, 0.7, 0}),
 	Color:     [3]float32{5, 5, 6},
 }
 
-func NewScene() *Scene {
+func NewScene() (*Scene, error) {
 
 	s := &Scene{
-		gBuffer:        NewGBufferPipeline(),
-		shadow:         NewShadow(directionLight),
-		camera:         NewCamera(),
-		projection:     mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/float32(windowHeight), near, far),
-		graph:          NewBaseNode(),
-		lightBoxShader: shaders.NewEmissive(),
+		camera:     NewCamera(),
+		projection: mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/float32(windowHeight), near, far),
+		graph:      NewBaseNode(),
+	}
+	var err error
+	if s.gBuffer, err = NewGBufferPipeline(); err != nil {
+		return nil, err
+	}
+	if s.forward, err = NewForwardPipeline(); err != nil {
+		return nil, err
+	}
+	if s.shadow, err = NewShadow(directionLight); err != nil {
+		return nil, err
+	}
+	if s.lightBoxShader, err = shaders.NewEmissive(); err != nil {
+		return nil, err
 	}
 
 	att := ligthAtt[20]
@@ -61,8 +78,9 @@ func NewScene() *Scene {
 			rand:     rand.Float32() * 2,
 		})
 	}
+	s.randomLights = true
 	chkError("end_of_new_scene")
-	return s
+	return s, nil
 }
 
 type Scene struct {
@@ -71,6 +89,7 @@ type Scene struct {
 	graph      SceneNode
 
 	gBuffer *GBufferPipeline
+	forward *ForwardPipeline
 	bloom   *BloomEffect
 	shadow  *ShadowFBO
 	ssao    *SsaoFBO
@@ -86,25 +105,51 @@ type Scene struct {
 	passShader       *shaders.Passthrough
 
 	pointLights []*PointLight
+	// randomLights is set while the point lights are the random ones from NewScene
+	randomLights bool
+	// environment is the HDR file in the textures that the scene is lit by
+	environment string
 
 	uboMatrices uint32
 }
 
-func (s *Scene) Init() {
+// Init builds the lighting and post processing passes, it returns an error if one of their shaders doesn't compile
+// or link
+func (s *Scene) Init() error {
 
-	s.dirLightShader = shaders.NewDirectionalLight()
-	s.pointLightShader = shaders.NewPointLightShader(maxPointLights)
-	s.bloom = NewBloomEffect(windowWidth/2, windowHeight/2)
-	s.ssao = NewSSAO(windowWidth, windowHeight)
+	var err error
+	if s.dirLightShader, err = shaders.NewDirectionalLight(); err != nil {
+		return err
+	}
+	if s.pointLightShader, err = shaders.NewPointLightShader(maxPointLights); err != nil {
+		return err
+	}
+	if s.bloom, err = NewBloomEffect(windowWidth/2, windowHeight/2); err != nil {
+		return err
+	}
+	if s.ssao, err = NewSSAO(windowWidth, windowHeight); err != nil {
+		return err
+	}
 	s.hdr = NewHDRFBO()
-	s.ibl = NewCubeMap(512, 512)
-	s.skybox = NewSkymap(s.ibl.envCubeMap)
-
-	s.fxaa = NewFxaa(windowWidth, windowHeight)
-	s.tonemap = NewToneMap(windowWidth, windowHeight)
-	s.passShader = shaders.NewPassthrough()
+	if s.ibl, err = NewCubeMap(512, 512); err != nil {
+		return err
+	}
+	if s.skybox, err = NewSkymap(s.ibl.envCubeMap); err != nil {
+		return err
+	}
 
-	s.ibl.Update(GetHDRTexture("sky0016.hdr"))
+	if s.fxaa, err = NewFxaa(windowWidth, windowHeight); err != nil {
+		return err
+	}
+	if s.tonemap, err = NewToneMap(windowWidth, windowHeight); err != nil {
+		return err
+	}
+	if s.passShader, err = shaders.NewPassthrough(); err != nil {
+		return err
+	}
+	if err := initDebugShaders(); err != nil {
+		return err
+	}
 
 	gl.GenBuffers(1, &s.uboMatrices)
 	gl.BindBuffer(gl.UNIFORM_BUFFER, s.uboMatrices)
@@ -120,6 +165,14 @@ func (s *Scene) Init() {
 	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
 
 	chkError("scene.init")
+	return nil
+}
+
+// Clear releases every node of the scene graph together with the models and textures that only they use
+func (s *Scene) Clear() {
+	for _, node := range append([]*Node(nil), s.graph.Children()...) {
+		node.Release()
+	}
 }
 
 func (s *Scene) Render(elapsed float64) {
@@ -130,9 +183,10 @@ func (s *Scene) Render(elapsed float64) {
 	view := s.camera.View(elapsed)
 	s.updateMatrices(view)
 
-	shadowMap := s.shadow.Render(s.graph)
+	shadowMap, shadowStats := s.shadow.Render(s.graph)
+	shadowCulling = shadowStats
 
-	s.gBuffer.Render(s.graph)
+	gBufferCulling = s.gBuffer.Render(s.graph, s.projection, view)
 
 	aoTexture := s.ssao.Render(s.gBuffer.buffer.gDepth, s.gBuffer.buffer.gNormalRoughness)
 
@@ -145,9 +199,14 @@ func (s *Scene) Render(elapsed float64) {
 	gl.Enable(gl.BLEND)
 	gl.BlendEquation(gl.FUNC_ADD)
 	gl.BlendFunc(gl.ONE, gl.ONE)
+	// a scene can have fewer lights than the number keys ask for
+	numLights := currentNumLights
+	if numLights > len(s.pointLights) {
+		numLights = len(s.pointLights)
+	}
 	{ // point light pass
 		gl.UseProgram(s.pointLightShader.Program)
-		gl.Uniform1i(s.pointLightShader.LocNumLights, int32(currentNumLights))
+		gl.Uniform1i(s.pointLightShader.LocNumLights, int32(numLights))
 
 		// for now I have settled with passing all lights into one shader pass. Stencil pass turned out to be very hard
 		// to get correct and not very fast. This could possibly be more performant by using tiled deferred rendering.
@@ -162,6 +221,7 @@ func (s *Scene) Render(elapsed float64) {
 		GLBindTexture(1, s.pointLightShader.LocGNormal, s.gBuffer.buffer.gNormalRoughness)
 		GLBindTexture(2, s.pointLightShader.LocGAlbedo, s.gBuffer.buffer.gAlbedoMetallic)
 		GLBindTexture(3, s.pointLightShader.LocGAmbientOcclusion, aoTexture)
+		GLBindTexture(4, s.pointLightShader.LocGEmissiveAO, s.gBuffer.buffer.gEmissiveAO)
 		gl.Uniform2f(s.pointLightShader.LocScreenSize, float32(windowWidth), float32(windowHeight))
 		renderQuad()
 	}
@@ -172,11 +232,12 @@ func (s *Scene) Render(elapsed float64) {
 		GLBindTexture(2, s.dirLightShader.LocGAlbedo, s.gBuffer.buffer.gAlbedoMetallic)
 		GLBindTexture(3, s.dirLightShader.LocShadowMap, shadowMap)
 		GLBindTexture(4, s.dirLightShader.LocGAmbientOcclusion, aoTexture)
+		GLBindTexture(5, s.dirLightShader.LocGEmissiveAO, s.gBuffer.buffer.gEmissiveAO)
 		if skyBoxOn {
 			gl.Uniform1i(s.dirLightShader.LocIBLEnabled, 1)
-			GLBindCubeMap(5, s.dirLightShader.LocIrradianceMap, s.ibl.irradianceMap)
-			GLBindCubeMap(6, s.dirLightShader.LocPrefilterMap, s.ibl.prefilterMap)
-			GLBindTexture(7, s.dirLightShader.LocPbrdfLUT, s.ibl.brdfLUTTexture)
+			GLBindCubeMap(6, s.dirLightShader.LocIrradianceMap, s.ibl.irradianceMap)
+			GLBindCubeMap(7, s.dirLightShader.LocPrefilterMap, s.ibl.prefilterMap)
+			GLBindTexture(8, s.dirLightShader.LocPbrdfLUT, s.ibl.brdfLUTTexture)
 		} else {
 			gl.Uniform1i(s.dirLightShader.LocIBLEnabled, 0)
 		}
@@ -197,7 +258,7 @@ func (s *Scene) Render(elapsed float64) {
 	{ // render emissive objects
 		gl.Enable(gl.DEPTH_TEST)
 		gl.UseProgram(s.lightBoxShader.Program)
-		for i := range s.pointLights[:currentNumLights] {
+		for i := range s.pointLights[:numLights] {
 			model := mgl32.Translate3D(s.pointLights[i].Position[0], s.pointLights[i].Position[1]+sin, s.pointLights[i].Position[2])
 			model = model.Mul4(mgl32.Scale3D(0.1, 0.1, 0.1))
 			model = model.Mul4(mgl32.HomogRotate3D(float32(math.Cos(glfw.GetTime())), mgl32.Vec3{1, 1, 1}.Normalize()))
@@ -207,9 +268,31 @@ func (s *Scene) Render(elapsed float64) {
 		}
 	}
 
+	// lines and points are drawn unlit on top of the lit scene, tested against the depth of the gBuffer
+	s.forward.Render(s.graph)
+
 	if skyBoxOn {
 		s.skybox.Render(view, s.ibl.envCubeMap)
 	}
+
+	if saveScreenshot {
+		saveScreenshot = false
+		file := fmt.Sprintf("screenshot-%s.hdr", time.Now().Format("20060102-150405"))
+		if err := SaveHDRTexture(s.gBuffer.buffer.finalTexture, windowWidth, windowHeight, file); err != nil {
+			glError(fmt.Errorf("screenshot: %v", err))
+		} else {
+			glLogf("saved screenshot %s\n", file)
+		}
+	}
+	if saveScene {
+		saveScene = false
+		file := fmt.Sprintf("scene-%s.json", time.Now().Format("20060102-150405"))
+		if err := s.SaveLevel(file); err != nil {
+			glError(fmt.Errorf("save scene: %v", err))
+		} else {
+			glLogf("saved scene %s\n", file)
+		}
+	}
 	out := s.gBuffer.buffer.finalTexture
 	if bloomOn {
 		out = s.bloom.Render(out)
@@ -2,8 +2,8 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"strings"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
@@ -36,35 +36,40 @@ func (s *DefaultShader) UsePV(projection, view mgl32.Mat4) {
 	gl.UniformMatrix4fv(s.view, 1, false, &view[0])
 }
 
-func NewDefaultShader(vertex, frag string) *DefaultShader {
+func NewDefaultShader(vertex, frag string) (*DefaultShader, error) {
 	shader := &DefaultShader{}
 
-	program := buildShader(vertex, frag)
+	program, err := buildShader(vertex, frag)
+	if err != nil {
+		return nil, err
+	}
 	// try to find these pretty standard uniforms
 	shader.program = program
 	shader.view = gl.GetUniformLocation(shader.Program(), gl.Str("view\x00"))
 	shader.projection = gl.GetUniformLocation(shader.Program(), gl.Str("projection\x00"))
-	return shader
+	return shader, nil
 }
 
-func buildShader(vertex, frag string) uint32 {
+// buildShader compiles and links the vertex and fragment shaders into a shader program
+func buildShader(vertex, frag string) (uint32, error) {
 	vertexShaderSource, err := loadVertexShader(vertex)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 	fragmentShaderSource, err := loadFragShader(frag)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 
 	vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("%s.vert: %v", vertex, err)
 	}
 
 	fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
 	if err != nil {
-		panic(err)
+		gl.DeleteShader(vertexShader)
+		return 0, fmt.Errorf("%s.frag: %v", frag, err)
 	}
 
 	program := gl.CreateProgram()
@@ -82,7 +87,10 @@ func buildShader(vertex, frag string) uint32 {
 		l := strings.Repeat("\x00", int(logLength+1))
 		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(l))
 
-		panic(fmt.Errorf("failed to link program[%d]: %v", program, l))
+		gl.DeleteProgram(program)
+		gl.DeleteShader(vertexShader)
+		gl.DeleteShader(fragmentShader)
+		return 0, fmt.Errorf("failed to link program %s / %s: %v", vertex, frag, l)
 	}
 
 	gl.DetachShader(program, vertexShader)
@@ -91,16 +99,16 @@ func buildShader(vertex, frag string) uint32 {
 	gl.DeleteShader(fragmentShader)
 
 	glLogShader(program, vertex, frag)
-	return program
+	return program, nil
 }
 
 func loadVertexShader(name string) (string, error) {
-	res, err := ioutil.ReadFile(filepath.Join("shaders", fmt.Sprintf("%s.vert", name)))
+	res, err := fs.ReadFile(assetFS, path.Join("shaders", name+".vert"))
 	return string(res) + "\x00", err
 }
 
 func loadFragShader(name string) (string, error) {
-	res, err := ioutil.ReadFile(filepath.Join("shaders", fmt.Sprintf("%s.frag", name)))
+	res, err := fs.ReadFile(assetFS, path.Join("shaders", name+".frag"))
 	return string(res) + "\x00", err
 }
 
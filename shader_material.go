@@ -2,9 +2,13 @@ package main
 
 import "github.com/go-gl/gl/v4.1-core/gl"
 
-func NewMaterialShader() *GbufferMShader {
+func NewMaterialShader() (*GbufferMShader, error) {
+	s, err := NewDefaultShader("g_buffer", "g_buffer_m")
+	if err != nil {
+		return nil, err
+	}
 	shader := &GbufferMShader{
-		Shader: NewDefaultShader("g_buffer", "g_buffer_m"),
+		Shader: s,
 	}
 
 	blockIndex := gl.GetUniformBlockIndex(shader.Program(), gl.Str("Matrices\x00"))
@@ -14,7 +18,8 @@ func NewMaterialShader() *GbufferMShader {
 	shader.LocAlbedo = uniformLocation(shader, "mat.albedo")
 	shader.LocMetallic = uniformLocation(shader, "mat.metallic")
 	shader.LocRoughness = uniformLocation(shader, "mat.roughness")
-	return shader
+	shader.LocEmissive = uniformLocation(shader, "mat.emissive")
+	return shader, nil
 }
 
 type GbufferMShader struct {
@@ -23,4 +28,5 @@ type GbufferMShader struct {
 	LocAlbedo    int32
 	LocMetallic  int32
 	LocRoughness int32
+	LocEmissive  int32
 }
@@ -4,16 +4,42 @@ type ModelShader interface {
 	ModelUniform() int32
 }
 
-func NewTextureShader() *GbufferTShader {
+func NewTextureShader() (*GbufferTShader, error) {
+	s, err := NewDefaultShader("g_buffer_t", "g_buffer_t")
+	if err != nil {
+		return nil, err
+	}
 	shader := &GbufferTShader{
-		Shader: NewDefaultShader("g_buffer_t", "g_buffer_t"),
+		Shader: s,
 	}
 	shader.LocModel = uniformLocation(shader, "model")
 	shader.LocAlbedo = uniformLocation(shader, "mat.albedo")
 	shader.LocMetallic = uniformLocation(shader, "mat.metallic")
 	shader.LocRoughness = uniformLocation(shader, "mat.roughness")
 	shader.LocNormal = uniformLocation(shader, "mat.normal")
-	return shader
+	shader.LocAO = uniformLocation(shader, "mat.ao")
+	shader.LocEmissive = uniformLocation(shader, "mat.emissive")
+	shader.LocEmissiveFactor = uniformLocation(shader, "mat.emissiveFactor")
+	shader.LocMetallicChannel = uniformLocation(shader, "mat.metallicChannel")
+	shader.LocRoughnessChannel = uniformLocation(shader, "mat.roughnessChannel")
+	shader.LocAOChannel = uniformLocation(shader, "mat.aoChannel")
+	shader.LocAlbedoFactor = uniformLocation(shader, "mat.albedoFactor")
+	shader.LocMetallicFactor = uniformLocation(shader, "mat.metallicFactor")
+	shader.LocRoughnessFactor = uniformLocation(shader, "mat.roughnessFactor")
+	shader.LocAlbedoTexCoords = uniformLocation(shader, "mat.albedoTexCoords")
+	shader.LocMetallicTexCoords = uniformLocation(shader, "mat.metallicTexCoords")
+	shader.LocRoughnessTexCoords = uniformLocation(shader, "mat.roughnessTexCoords")
+	shader.LocNormalTexCoords = uniformLocation(shader, "mat.normalTexCoords")
+	shader.LocAOTexCoords = uniformLocation(shader, "mat.aoTexCoords")
+	shader.LocEmissiveTexCoords = uniformLocation(shader, "mat.emissiveTexCoords")
+	shader.LocAlbedoTopDown = uniformLocation(shader, "mat.albedoTopDown")
+	shader.LocMetallicTopDown = uniformLocation(shader, "mat.metallicTopDown")
+	shader.LocRoughnessTopDown = uniformLocation(shader, "mat.roughnessTopDown")
+	shader.LocNormalTopDown = uniformLocation(shader, "mat.normalTopDown")
+	shader.LocAOTopDown = uniformLocation(shader, "mat.aoTopDown")
+	shader.LocEmissiveTopDown = uniformLocation(shader, "mat.emissiveTopDown")
+	shader.LocNormalTwoChannel = uniformLocation(shader, "mat.normalTwoChannel")
+	return shader, nil
 }
 
 type GbufferTShader struct {
@@ -23,6 +49,34 @@ type GbufferTShader struct {
 	LocRoughness int32
 	LocMetallic  int32
 	LocNormal    int32
+	LocAO        int32
+	LocEmissive  int32
+	// the emissive texture is multiplied with this color
+	LocEmissiveFactor int32
+	// the channel masks of the single valued properties
+	LocMetallicChannel  int32
+	LocRoughnessChannel int32
+	LocAOChannel        int32
+	// the albedo, metallic and roughness textures are multiplied with these factors
+	LocAlbedoFactor    int32
+	LocMetallicFactor  int32
+	LocRoughnessFactor int32
+	// the UV set of each texture
+	LocAlbedoTexCoords    int32
+	LocMetallicTexCoords  int32
+	LocRoughnessTexCoords int32
+	LocNormalTexCoords    int32
+	LocAOTexCoords        int32
+	LocEmissiveTexCoords  int32
+	// set for textures that are stored top down, like DDS and KTX files usually are
+	LocAlbedoTopDown    int32
+	LocMetallicTopDown  int32
+	LocRoughnessTopDown int32
+	LocNormalTopDown    int32
+	LocAOTopDown        int32
+	LocEmissiveTopDown  int32
+	// set for normal maps that only store x and y
+	LocNormalTwoChannel int32
 }
 
 func (s *GbufferTShader) TextureUniform(t TextureType) int32 {
@@ -35,6 +89,65 @@ func (s *GbufferTShader) TextureUniform(t TextureType) int32 {
 		return s.LocRoughness
 	case Normal:
 		return s.LocNormal
+	case AO:
+		return s.LocAO
+	case Emissive:
+		return s.LocEmissive
+	default:
+		return -1
+	}
+}
+
+// ChannelUniform returns the location of the mask that selects which channel a single valued texture type is read
+// from, or -1 for the texture types that use all channels
+func (s *GbufferTShader) ChannelUniform(t TextureType) int32 {
+	switch t {
+	case Metallic:
+		return s.LocMetallicChannel
+	case Roughness:
+		return s.LocRoughnessChannel
+	case AO:
+		return s.LocAOChannel
+	default:
+		return -1
+	}
+}
+
+// TexCoordsUniform returns the location of the UV set that a texture type is mapped with
+func (s *GbufferTShader) TexCoordsUniform(t TextureType) int32 {
+	switch t {
+	case Albedo:
+		return s.LocAlbedoTexCoords
+	case Metallic:
+		return s.LocMetallicTexCoords
+	case Roughness:
+		return s.LocRoughnessTexCoords
+	case Normal:
+		return s.LocNormalTexCoords
+	case AO:
+		return s.LocAOTexCoords
+	case Emissive:
+		return s.LocEmissiveTexCoords
+	default:
+		return -1
+	}
+}
+
+// TopDownUniform returns the location of the flag that flips V for a texture type that is stored top down
+func (s *GbufferTShader) TopDownUniform(t TextureType) int32 {
+	switch t {
+	case Albedo:
+		return s.LocAlbedoTopDown
+	case Metallic:
+		return s.LocMetallicTopDown
+	case Roughness:
+		return s.LocRoughnessTopDown
+	case Normal:
+		return s.LocNormalTopDown
+	case AO:
+		return s.LocAOTopDown
+	case Emissive:
+		return s.LocEmissiveTopDown
 	default:
 		return -1
 	}
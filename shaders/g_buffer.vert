@@ -3,10 +3,14 @@
 layout (location = 0) in vec3 position;
 layout (location = 1) in vec3 normal;
 layout (location = 2) in vec2 texCoords;
-layout (location = 3) in vec3 tangent;
+layout (location = 3) in vec4 tangent;
+// the optional attributes are white and zero for meshes that don't have them
+layout (location = 4) in vec4 color;
+layout (location = 5) in vec2 texCoords2;
 
 out vec2 TexCoords;
 out vec3 Normal;
+out vec4 Color;
 
 layout (std140) uniform Matrices
 {
@@ -24,5 +28,6 @@ void main()
     mat4 vm = view * model;
     gl_Position = projection * vm * vec4(position, 1.0);
     TexCoords = texCoords;
+    Color = color;
     Normal = transpose(inverse(mat3(vm))) * normal;
 }
@@ -2,14 +2,17 @@
 
 layout (location = 0) out vec4 gNormalRoughness;
 layout (location = 1) out vec4 gAlbedoMetallic;
+layout (location = 2) out vec4 gEmissiveAO;
 
 in vec2 TexCoords;
 in vec3 Normal;
+in vec4 Color;
 
 struct Material {
     vec3 albedo;
     float metallic;
     float roughness;
+    vec3 emissive;
 };
 uniform Material mat;
 
@@ -20,7 +23,9 @@ void main()
     // store the per-fragment roughness
     gNormalRoughness.w = mat.roughness;
     // store the per-fragment albedo/diffuse
-    gAlbedoMetallic.rgb = mat.albedo;
+    gAlbedoMetallic.rgb = mat.albedo * Color.rgb;
     // store the per-fragment metallicness
     gAlbedoMetallic.a = mat.metallic;
+    // store the emitted light and no baked ambient occlusion
+    gEmissiveAO = vec4(mat.emissive, 1.0);
 }
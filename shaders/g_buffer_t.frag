@@ -2,9 +2,12 @@
 
 layout (location = 0) out vec4 gNormalRoughness;
 layout (location = 1) out vec4 gAlbedoMetallic;
+layout (location = 2) out vec4 gEmissiveAO;
 
 in vec2 TexCoords;
+in vec2 TexCoords2;
 in vec3 Normal;
+in vec4 Color;
 in mat3 TBN;
 
 struct Material {
@@ -12,27 +15,70 @@ struct Material {
     sampler2D metallic;
     sampler2D normal;
     sampler2D roughness;
+    sampler2D ao;
+    sampler2D emissive;
+    vec3 emissiveFactor;
+    // the albedo, metallic and roughness textures are multiplied with these
+    vec3 albedoFactor;
+    float metallicFactor;
+    float roughnessFactor;
+    // selects the channel of a packed texture that the single valued properties are read from
+    vec4 metallicChannel;
+    vec4 roughnessChannel;
+    vec4 aoChannel;
+    // the UV set that each texture is mapped with, 0 or 1 for the second set
+    int albedoTexCoords;
+    int metallicTexCoords;
+    int roughnessTexCoords;
+    int normalTexCoords;
+    int aoTexCoords;
+    int emissiveTexCoords;
+    // the texture rows are stored top down, so V is flipped when sampling it
+    bool albedoTopDown;
+    bool metallicTopDown;
+    bool roughnessTopDown;
+    bool normalTopDown;
+    bool aoTopDown;
+    bool emissiveTopDown;
+    // the normal map only has x and y, like BC5/RG textures
+    bool normalTwoChannel;
 };
 uniform Material mat;
 
 vec3 CalcBumpedNormal(vec3 normal);
+vec2 uv(int set, bool topDown);
 
 void main()
 {
     // store the per-fragment normals
     gNormalRoughness.rgb = CalcBumpedNormal(Normal).rgb;
     // store the per-fragment roughness
-    gNormalRoughness.a = texture(mat.roughness, TexCoords).r;
+    gNormalRoughness.a = dot(texture(mat.roughness, uv(mat.roughnessTexCoords, mat.roughnessTopDown)), mat.roughnessChannel) * mat.roughnessFactor;
 
     // And the diffuse per-fragment color
-    gAlbedoMetallic.rgb = texture(mat.albedo, TexCoords).rgb;
+    gAlbedoMetallic.rgb = texture(mat.albedo, uv(mat.albedoTexCoords, mat.albedoTopDown)).rgb * mat.albedoFactor * Color.rgb;
     // Store specular intensity in gAlbedoSpec's alpha component
-    gAlbedoMetallic.a = texture(mat.metallic, TexCoords).r;
+    gAlbedoMetallic.a = dot(texture(mat.metallic, uv(mat.metallicTexCoords, mat.metallicTopDown)), mat.metallicChannel) * mat.metallicFactor;
+
+    // the emitted light is added in the lighting pass
+    gEmissiveAO.rgb = texture(mat.emissive, uv(mat.emissiveTexCoords, mat.emissiveTopDown)).rgb * mat.emissiveFactor;
+    // baked ambient occlusion, combined with the SSAO in the lighting pass
+    gEmissiveAO.a = dot(texture(mat.ao, uv(mat.aoTexCoords, mat.aoTopDown)), mat.aoChannel);
+}
+
+vec2 uv(int set, bool topDown)
+{
+    vec2 coords = set == 1 ? TexCoords2 : TexCoords;
+    return topDown ? vec2(coords.x, 1.0 - coords.y) : coords;
 }
 
 vec3 CalcBumpedNormal(vec3 normal)
 {
-    vec3 BumpMapNormal = texture(mat.normal, TexCoords).xyz;
+    vec3 BumpMapNormal = texture(mat.normal, uv(mat.normalTexCoords, mat.normalTopDown)).xyz;
     BumpMapNormal = 2.0 * BumpMapNormal - vec3(1.0);
+    // two channel (BC5/RG) normal maps only store x and y
+    if (mat.normalTwoChannel) {
+        BumpMapNormal.z = sqrt(max(0.0, 1.0 - dot(BumpMapNormal.xy, BumpMapNormal.xy)));
+    }
     return normalize(TBN * BumpMapNormal);
 }
@@ -3,10 +3,15 @@
 layout (location = 0) in vec3 position;
 layout (location = 1) in vec3 normal;
 layout (location = 2) in vec2 texCoords;
-layout (location = 3) in vec3 tangent;
+layout (location = 3) in vec4 tangent;
+// the optional attributes are white and zero for meshes that don't have them
+layout (location = 4) in vec4 color;
+layout (location = 5) in vec2 texCoords2;
 
 out vec2 TexCoords;
+out vec2 TexCoords2;
 out vec3 Normal;
+out vec4 Color;
 out mat3 TBN;
 
 layout (std140) uniform Matrices
@@ -25,8 +30,12 @@ void main()
     mat4 vm = view * model;
     gl_Position = projection * vm * vec4(position, 1.0);
     TexCoords = texCoords;
+    TexCoords2 = texCoords2;
+    Color = color;
     mat3 normalMatrix = transpose(inverse(mat3(vm)));
-    vec3 T = normalize(normalMatrix * tangent);
+    vec3 T = normalize(normalMatrix * tangent.xyz);
     Normal = normalize(normalMatrix * normal);
-    TBN = mat3(T, cross(T, Normal), Normal);
+    // the w component is the handedness of the tangent frame, it's negative where the UVs are mirrored
+    vec3 B = cross(Normal, T) * tangent.w;
+    TBN = mat3(T, B, Normal);
 }
@@ -6,6 +6,7 @@ uniform sampler2D gDepth;
 uniform sampler2D gNormal;
 uniform sampler2D gAlbedoSpec;
 uniform sampler2D gAmbientOcclusion;
+uniform sampler2D gEmissiveAO;
 uniform int iblEnabled;
 uniform samplerCube irradianceMap;
 uniform samplerCube prefilterMap;
@@ -67,6 +68,8 @@ void main()
     float metallic  = texture(gAlbedoSpec, TexCoords).a;
     float roughness = texture(gNormal, TexCoords).w;
     float ao        = texture(gAmbientOcclusion, TexCoords).r;
+    // combine the screen space and the baked ambient occlusion
+    ao             *= texture(gEmissiveAO, TexCoords).a;
     float shadow    = ShadowCalculation(invView * FragPos, N);
 
     vec3 Lo = vec3(0.0);
@@ -101,7 +104,11 @@ void main()
         ambient = (kD * diffuse + specular) * ao;
     }
 
-    FragColor   = vec4(ambient + Lo ,1);
+    // the emitted light is only added by this pass so that it's not counted once per light, it's picked up by the
+    // bloom together with the rest of the bright parts
+    vec3 emissive = texture(gEmissiveAO, TexCoords).rgb;
+
+    FragColor   = vec4(ambient + Lo + emissive, 1);
 }
 
 vec3 LightCalculation(vec3 V, vec3 N, vec3 albedo, float roughness, float metallic, vec3 F0, vec3 lightPos, vec3 lightColor, float attenuation) {
@@ -17,6 +17,7 @@ uniform sampler2D gDepth;
 uniform sampler2D gNormal;
 uniform sampler2D gAlbedoSpec;
 uniform sampler2D gAmbientOcclusion;
+uniform sampler2D gEmissiveAO;
 
 struct Light {
     vec3 Position;
@@ -51,6 +52,8 @@ void main()
     float metallic = texture(gAlbedoSpec, TexCoords).a;
     float roughness = texture(gNormal, TexCoords).w;
     float ao = texture(gAmbientOcclusion, TexCoords).r;
+    // combine the screen space and the baked ambient occlusion
+    ao *= texture(gEmissiveAO, TexCoords).a;
 
     vec3 F0 = vec3(0.04);
     F0      = mix(F0, albedo, metallic);
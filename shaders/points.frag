@@ -0,0 +1,16 @@
+#version 410
+
+uniform vec3 color;
+
+in vec4 Color;
+
+out vec4 FragColor;
+
+void main() {
+    // round off the square point sprite
+    vec2 p = gl_PointCoord * 2.0 - 1.0;
+    if (dot(p, p) > 1.0) {
+        discard;
+    }
+    FragColor = vec4(color * Color.rgb, 1.0);
+}
@@ -0,0 +1,25 @@
+#version 410
+
+layout (location = 0) in vec3 position;
+layout (location = 4) in vec4 color;
+
+layout (std140) uniform Matrices
+{
+    mat4 projection;
+    mat4 view;
+    mat4 invProjection;
+    mat4 invView;
+    vec3 cameraPos;
+};
+
+uniform mat4 model;
+// size of the points in pixels
+uniform float size;
+
+out vec4 Color;
+
+void main() {
+    gl_Position = projection * view * model * vec4(position, 1.0);
+    gl_PointSize = size;
+    Color = color;
+}
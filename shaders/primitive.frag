@@ -0,0 +1,11 @@
+#version 410
+
+uniform vec3 color;
+
+in vec4 Color;
+
+out vec4 FragColor;
+
+void main() {
+    FragColor = vec4(color * Color.rgb, 1.0);
+}
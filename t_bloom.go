@@ -5,14 +5,26 @@ import (
 	"github.com/stojg/cspace/lib/shaders"
 )
 
-func NewBloomEffect(width, height int32) *BloomEffect {
+func NewBloomEffect(width, height int32) (*BloomEffect, error) {
+	bloomSeparator, err := shaders.NewBloomSeparator()
+	if err != nil {
+		return nil, err
+	}
+	bloomBlend, err := shaders.NewBloomBlend()
+	if err != nil {
+		return nil, err
+	}
+	gaussianShader, err := shaders.NewGaussian()
+	if err != nil {
+		return nil, err
+	}
 	b := &BloomEffect{
 		width:          width,
 		height:         height,
 		pingBuffers:    [2]*FBO{NewFBO(width, height), NewFBO(width, height)},
-		bloomSeparator: shaders.NewBloomSeparator(),
-		bloomBlend:     shaders.NewBloomBlend(),
-		gaussianShader: shaders.NewGaussian(),
+		bloomSeparator: bloomSeparator,
+		bloomBlend:     bloomBlend,
+		gaussianShader: gaussianShader,
 	}
 
 	GLFramebuffer(&b.fbo)
@@ -24,7 +36,7 @@ func NewBloomEffect(width, height int32) *BloomEffect {
 
 	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
 
-	return b
+	return b, nil
 }
 
 type BloomEffect struct {
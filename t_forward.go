@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/stojg/cspace/lib/shaders"
+)
+
+func NewForwardPipeline() (*ForwardPipeline, error) {
+	lShader, err := shaders.NewLines()
+	if err != nil {
+		return nil, err
+	}
+	pShader, err := shaders.NewPoints()
+	if err != nil {
+		return nil, err
+	}
+	return &ForwardPipeline{
+		lShader: lShader,
+		pShader: pShader,
+	}, nil
+}
+
+// ForwardPipeline draws the meshes that can't go through the gBuffer, lines and points, into the currently bound
+// framebuffer
+type ForwardPipeline struct {
+	lShader *shaders.Lines
+	pShader *shaders.Points
+}
+
+func (f *ForwardPipeline) Render(graph SceneNode) {
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthMask(true)
+	// the lines are expanded into quads that face either way
+	gl.Disable(gl.CULL_FACE)
+	gl.Enable(gl.PROGRAM_POINT_SIZE)
+
+	gl.UseProgram(f.lShader.Program)
+	gl.Uniform2f(f.lShader.LocScreenSize, float32(windowWidth), float32(windowHeight))
+	graph.RenderForward(f.lShader, f.pShader)
+
+	gl.Disable(gl.PROGRAM_POINT_SIZE)
+	gl.Enable(gl.CULL_FACE)
+	gl.UseProgram(0)
+}
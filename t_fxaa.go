@@ -19,8 +19,11 @@ type Fxaa struct {
 	shader *shaders.Fxaa
 }
 
-func NewFxaa(width, height int32) *Fxaa {
-	c := shaders.NewFxaa()
+func NewFxaa(width, height int32) (*Fxaa, error) {
+	c, err := shaders.NewFxaa()
+	if err != nil {
+		return nil, err
+	}
 	fxaa := &Fxaa{
 		shader:        c,
 		width:         width,
@@ -37,7 +40,7 @@ func NewFxaa(width, height int32) *Fxaa {
 
 	chkFramebuffer()
 
-	return fxaa
+	return fxaa, nil
 }
 
 func (fxaa *Fxaa) Render(inTexture uint32) uint32 {
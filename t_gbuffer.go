@@ -2,19 +2,28 @@ package main
 
 import (
 	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
 )
 
-func NewGBufferPipeline() *GBufferPipeline {
+func NewGBufferPipeline() (*GBufferPipeline, error) {
+	nullShader, err := NewDefaultShader("null", "null")
+	if err != nil {
+		return nil, err
+	}
 	p := &GBufferPipeline{
 		buffer:     NewGbuffer(),
-		nullShader: NewDefaultShader("null", "null"),
+		nullShader: nullShader,
+	}
+	if p.mShader, err = NewMaterialShader(); err != nil {
+		return nil, err
 	}
-	p.mShader = NewMaterialShader()
 
-	p.tShader = NewTextureShader()
+	if p.tShader, err = NewTextureShader(); err != nil {
+		return nil, err
+	}
 	blockIndex := gl.GetUniformBlockIndex(p.tShader.Program(), gl.Str("Matrices\x00"))
 	gl.UniformBlockBinding(p.tShader.Program(), blockIndex, 0)
-	return p
+	return p, nil
 }
 
 type GBufferPipeline struct {
@@ -24,21 +33,22 @@ type GBufferPipeline struct {
 	nullShader *DefaultShader
 }
 
-// Render into the gBuffer
-func (g *GBufferPipeline) Render(graph SceneNode) {
+// Render the meshes that the camera can see into the gBuffer
+func (g *GBufferPipeline) Render(graph SceneNode, projection, view mgl32.Mat4) CullStats {
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthMask(true)
 
 	gl.Enable(gl.CULL_FACE)
 	gl.CullFace(gl.BACK)
 	gl.BindFramebuffer(gl.FRAMEBUFFER, g.buffer.fbo)
-	var attachments = [2]uint32{gl.COLOR_ATTACHMENT0, gl.COLOR_ATTACHMENT1}
+	var attachments = [3]uint32{gl.COLOR_ATTACHMENT0, gl.COLOR_ATTACHMENT1, gl.COLOR_ATTACHMENT2}
 	gl.DrawBuffers(int32(len(attachments)), &attachments[0])
 	gl.Clear(gl.DEPTH_BUFFER_BIT | gl.COLOR_BUFFER_BIT)
-	graph.Render(g.tShader, g.mShader)
+	stats := graph.Render(g.tShader, g.mShader, NewFrustum(projection.Mul4(view)))
 
 	gl.UseProgram(0)
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	return stats
 }
 
 type Gbuffer struct {
@@ -46,6 +56,7 @@ type Gbuffer struct {
 
 	gNormalRoughness uint32
 	gAlbedoMetallic  uint32
+	gEmissiveAO      uint32
 	gDepth           uint32
 	finalTexture     uint32
 }
@@ -75,6 +86,16 @@ func NewGbuffer() *Gbuffer {
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT1, gl.TEXTURE_2D, gbuffer.gAlbedoMetallic, 0)
 
+	// Emissive + baked ambient occlusion texture buffer
+	gl.GenTextures(1, &gbuffer.gEmissiveAO)
+	gl.BindTexture(gl.TEXTURE_2D, gbuffer.gEmissiveAO)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA16F, windowWidth, windowHeight, 0, gl.RGBA, gl.FLOAT, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT2, gl.TEXTURE_2D, gbuffer.gEmissiveAO, 0)
+
 	//  Depth texture
 	gl.GenTextures(1, &gbuffer.gDepth)
 	gl.BindTexture(gl.TEXTURE_2D, gbuffer.gDepth)
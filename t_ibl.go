@@ -24,14 +24,23 @@ type IBL struct {
 	brdfShader                     *shaders.IBLBrdf
 }
 
-func NewCubeMap(width, height int32) *IBL {
+func NewCubeMap(width, height int32) (*IBL, error) {
 	cube := &IBL{
 		width:  width,
 		height: height,
-		equirectangularToCubemapShader: shaders.NewEquiRectToCubeMap(),
-		irradianceShader:               shaders.NewIBLIrradiance(),
-		prefilterShader:                shaders.NewIBLPrefilter(),
-		brdfShader:                     shaders.NewIBLBrdf(),
+	}
+	var err error
+	if cube.equirectangularToCubemapShader, err = shaders.NewEquiRectToCubeMap(); err != nil {
+		return nil, err
+	}
+	if cube.irradianceShader, err = shaders.NewIBLIrradiance(); err != nil {
+		return nil, err
+	}
+	if cube.prefilterShader, err = shaders.NewIBLPrefilter(); err != nil {
+		return nil, err
+	}
+	if cube.brdfShader, err = shaders.NewIBLBrdf(); err != nil {
+		return nil, err
 	}
 
 	gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
@@ -70,9 +79,11 @@ func NewCubeMap(width, height int32) *IBL {
 
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 
-	return cube
+	return cube, nil
 }
 
+// Update renders an equirectangular environment texture, as loaded by NewHDRTexture from a .hdr or .exr file, into the
+// environment, irradiance and prefiltered cube maps
 func (cube *IBL) Update(texture *Texture) {
 	fovy := (90 * math.Pi) / 180.0
 	captureProjection := mgl32.Perspective(float32(fovy), 1, 0.1, 10)
@@ -13,11 +13,14 @@ type AverageExposure struct {
 	firstFrame bool
 }
 
-func NewAverageExposure() *AverageExposure {
-
+func NewAverageExposure() (*AverageExposure, error) {
+	passShader, err := shaders.NewPassthrough()
+	if err != nil {
+		return nil, err
+	}
 	a := &AverageExposure{
 		firstFrame: true,
-		passShader: shaders.NewPassthrough(),
+		passShader: passShader,
 		exposure:   1.0,
 	}
 	gl.GenFramebuffers(1, &a.fbo)
@@ -31,7 +34,7 @@ func NewAverageExposure() *AverageExposure {
 	chkFramebuffer()
 
 	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
-	return a
+	return a, nil
 }
 
 // 0.5 Calculate average luminence of last frame's scene (pingpongColorBuffer[0] filled at end of loop (see last section of code))
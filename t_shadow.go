@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
@@ -18,7 +19,7 @@ type ShadowFBO struct {
 	Projection          mgl32.Mat4
 }
 
-func NewShadow(light *DirectionalLight) *ShadowFBO {
+func NewShadow(light *DirectionalLight) (*ShadowFBO, error) {
 	shadow := &ShadowFBO{
 		Width:  1024 * 2,
 		Height: 1024 * 2,
@@ -57,20 +58,36 @@ func NewShadow(light *DirectionalLight) *ShadowFBO {
 	}
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 
+	shader, err := NewDefaultShader("shadow", "shadow")
+	if err != nil {
+		return nil, err
+	}
 	shadow.shader = &ShadowShader{
-		DefaultShader: NewDefaultShader("shadow", "shadow"),
+		DefaultShader: shader,
 	}
 	shadow.locLightSpaceMatrix = uniformLocation(shadow.shader, "lightSpaceMatrix")
 	shadow.shader.uniformModelLoc = uniformLocation(shadow.shader.DefaultShader, "model")
 
 	shadow.Projection = mgl32.Ortho(-44, 40, -25, 25, -45, 40)
-	shadow.View = mgl32.LookAt(light.Direction[0], light.Direction[1], light.Direction[2], 0, 0, 0, 0, 1, 0)
+	shadow.SetLight(light)
+
+	return shadow, nil
+}
 
-	return shadow
+// SetLight points the shadow map along the direction of the light, call it when the direction changes
+func (s *ShadowFBO) SetLight(light *DirectionalLight) {
+	dir := mgl32.Vec3(light.Direction)
+	up := mgl32.Vec3{0, 1, 0}
+	// the view can't be built from an up vector that is parallel to the direction
+	if dir.Len() > 0 && math.Abs(float64(dir.Normalize().Dot(up))) > 0.999 {
+		up = mgl32.Vec3{0, 0, 1}
+	}
+	s.View = mgl32.LookAtV(dir, mgl32.Vec3{}, up)
 }
 
-// Render the directional lights shadow mask and push that into a shadow depth texture
-func (s *ShadowFBO) Render(graph SceneNode) uint32 {
+// Render the directional lights shadow mask and push that into a shadow depth texture, meshes outside of the lights
+// ortho projection are skipped
+func (s *ShadowFBO) Render(graph SceneNode) (uint32, CullStats) {
 	gl.BindFramebuffer(gl.FRAMEBUFFER, s.fbo)
 	gl.Clear(gl.DEPTH_BUFFER_BIT)
 
@@ -85,13 +102,13 @@ func (s *ShadowFBO) Render(graph SceneNode) uint32 {
 	gl.UniformMatrix4fv(s.locLightSpaceMatrix, 1, false, &lightSpaceMatrix[0])
 
 	gl.Viewport(0, 0, s.Width, s.Height)
-	graph.SimpleRender(s.shader)
+	stats := graph.SimpleRender(s.shader, NewFrustum(lightSpaceMatrix))
 	gl.Viewport(0, 0, windowWidth, windowHeight)
 
 	gl.UseProgram(0)
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 
-	return s.depthMap
+	return s.depthMap, stats
 }
 
 type ShadowShader struct {
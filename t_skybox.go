@@ -6,12 +6,16 @@ import (
 	"github.com/stojg/cspace/lib/shaders"
 )
 
-func NewSkymap(cubemap uint32) *Skybox {
+func NewSkymap(cubemap uint32) (*Skybox, error) {
+	shader, err := shaders.NewSkybox()
+	if err != nil {
+		return nil, err
+	}
 	t := &Skybox{
 		cubemap: cubemap,
-		shader:  shaders.NewSkybox(),
+		shader:  shader,
 	}
-	return t
+	return t, nil
 }
 
 type Skybox struct {
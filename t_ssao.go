@@ -10,7 +10,7 @@ import (
 
 // NewSSAO returns the Screen Space Ambient Occlusion effect
 // on macbook air this takes ~10ms to render
-func NewSSAO(width, height int32) *SsaoFBO {
+func NewSSAO(width, height int32) (*SsaoFBO, error) {
 	ssao := &SsaoFBO{
 		Width:  width,
 		Height: height,
@@ -71,11 +71,20 @@ func NewSSAO(width, height int32) *SsaoFBO {
 
 	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
 
-	ssao.depthResampler = shaders.NewSSAODepthResampler()
-	ssao.shader = shaders.NewSSAO()
-	ssao.blurShader = shaders.NewSSAOGaussian()
-	ssao.passShader = shaders.NewPassthrough()
-	return ssao
+	var err error
+	if ssao.depthResampler, err = shaders.NewSSAODepthResampler(); err != nil {
+		return nil, err
+	}
+	if ssao.shader, err = shaders.NewSSAO(); err != nil {
+		return nil, err
+	}
+	if ssao.blurShader, err = shaders.NewSSAOGaussian(); err != nil {
+		return nil, err
+	}
+	if ssao.passShader, err = shaders.NewPassthrough(); err != nil {
+		return nil, err
+	}
+	return ssao, nil
 }
 
 type SsaoFBO struct {
@@ -5,17 +5,21 @@ import (
 	"github.com/stojg/cspace/lib/shaders"
 )
 
-func NewToneMap(width, height int32) *ToneMap {
+func NewToneMap(width, height int32) (*ToneMap, error) {
+	shader, err := shaders.NewHDR()
+	if err != nil {
+		return nil, err
+	}
 	t := &ToneMap{
 		width:  width,
 		height: height,
-		shader: shaders.NewHDR(),
+		shader: shader,
 	}
 	GLFramebuffer(&t.fbo)
 	GLTextureRGB8(&t.texture, width, height, gl.LINEAR, gl.CLAMP_TO_EDGE, nil)
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.texture, 0)
 	chkFramebuffer()
-	return t
+	return t, nil
 }
 
 type ToneMap struct {
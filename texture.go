@@ -1,15 +1,18 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"image"
 	"image/draw"
 	_ "image/jpeg"
 	_ "image/png"
-	"os"
-	"path/filepath"
+	"path"
+	"strings"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/stojg/cspace/lib/exr"
+	"github.com/stojg/cspace/lib/gpuimage"
 	"github.com/stojg/cspace/lib/rgbe"
 )
 
@@ -20,31 +23,306 @@ const (
 	Metallic  TextureType = "metallic"
 	Roughness TextureType = "roughness"
 	Normal    TextureType = "normal"
+	AO        TextureType = "ao"
+	Emissive  TextureType = "emissive"
+	// Packed textures carry a single valued property in each channel, see Channels
+	Packed TextureType = "packed"
+)
+
+// Channels lists the property carried by the red, green, blue and alpha channel of a packed texture, unused channels
+// are left empty
+type Channels [4]TextureType
+
+var (
+	// ORM is the occlusion, roughness and metallic layout used by many PBR asset libraries
+	ORM = Channels{AO, Roughness, Metallic}
+	// MR is the layout of the glTF metallicRoughness texture
+	MR = Channels{"", Roughness, Metallic}
 )
 
 type Texture struct {
 	ID          uint32
 	textureType TextureType // type of texture, like diffuse, specular or bump
+	channels    Channels    // the properties of a Packed texture
+	Format      uint32      // the OpenGL internal format
+	Width       int32
+	Height      int32
+	Levels      int32 // number of mip levels, including the base level
+	// TopDown is set when the first row of the texture is the top of the image, the shaders flip V when sampling it
+	TopDown bool
+
+	asset *textureAsset // the cache entry when the texture is shared through the Assets manager
+}
+
+// Delete releases the OpenGL texture
+func (t *Texture) Delete() {
+	delete(textures, t.ID)
+	gl.DeleteTextures(1, &t.ID)
+	t.ID = 0
+}
+
+// textures keeps track of every uploaded texture by their OpenGL id for the memory report
+var textures = make(map[uint32]*Texture)
+
+func trackTexture(t *Texture) *Texture {
+	textures[t.ID] = t
+	return t
+}
+
+// TextureMemory returns the number of textures and an estimate of how many bytes of video memory they use
+func TextureMemory() (int, int) {
+	total := 0
+	for _, t := range textures {
+		total += t.MemorySize()
+	}
+	return len(textures), total
+}
+
+// MemorySize returns an estimate of the number of bytes the texture and its mip levels use in video memory
+func (t *Texture) MemorySize() int {
+	size := 0
+	w, h := int(t.Width), int(t.Height)
+	for level := int32(0); level < t.Levels; level++ {
+		if blockSize, ok := compressedBlockSizes[t.Format]; ok {
+			size += ((w + 3) / 4) * ((h + 3) / 4) * blockSize
+		} else {
+			size += w * h * pixelSizes[t.Format]
+		}
+		if w > 1 {
+			w /= 2
+		}
+		if h > 1 {
+			h /= 2
+		}
+	}
+	return size
+}
+
+// mipLevels returns the number of levels in a full mip chain
+func mipLevels(width, height int32) int32 {
+	levels := int32(1)
+	for width > 1 || height > 1 {
+		width /= 2
+		height /= 2
+		levels++
+	}
+	return levels
 }
 
 // GetTexture will load and return a Texture and panic if the texture could not be loaded
 func GetTexture(texType TextureType, file string, gammaCorrect bool) *Texture {
-	texture, err := newTexture(texType, filepath.Join("textures", file), gammaCorrect)
+	texture, err := LoadTexture(texType, file, gammaCorrect)
 	if err != nil {
 		panic(err)
 	}
 	return texture
 }
 
+// LoadTexture will load and return a shared Texture from the textures folder
+func LoadTexture(texType TextureType, file string, gammaCorrect bool) (*Texture, error) {
+	return assets.Texture(texType, path.Join("textures", file), gammaCorrect)
+}
+
+// LoadTextureAsync returns a shared Texture from the textures folder that shows a placeholder until the image has been
+// decoded in the background and uploaded
+func LoadTextureAsync(texType TextureType, file string, gammaCorrect bool) *Texture {
+	return assets.TextureAsync(texType, path.Join("textures", file), gammaCorrect)
+}
+
+// LoadPackedTexture will load and return a shared Texture from the textures folder that carries one property per
+// channel, like an ORM texture
+func LoadPackedTexture(file string, channels Channels) (*Texture, error) {
+	return assets.PackedTexture(path.Join("textures", file), channels)
+}
+
+// LoadPackedTextureAsync is LoadTextureAsync for textures that carry one property per channel
+func LoadPackedTextureAsync(file string, channels Channels) *Texture {
+	return assets.PackedTextureAsync(path.Join("textures", file), channels)
+}
+
+// channel returns which channel of the texture carries the texture type, textures that aren't packed keep single
+// valued properties in the red channel
+func (t *Texture) channel(texType TextureType) (int, bool) {
+	if t.textureType != Packed {
+		return 0, t.textureType == texType
+	}
+	for i, c := range t.channels {
+		if c == texType {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 // GetHDRTexture will load and return a Texture and panic if the texture could not be loaded
 func GetHDRTexture(file string) *Texture {
-	texture, err := NewHDRTexture(filepath.Join("textures", file))
+	texture, err := LoadHDRTexture(file)
 	if err != nil {
 		panic(err)
 	}
 	return texture
 }
 
+// LoadHDRTexture will load and return a HDR Texture from the textures folder
+func LoadHDRTexture(file string) (*Texture, error) {
+	return NewHDRTexture(path.Join("textures", file))
+}
+
+var checkerTextureID uint32
+
+// FallbackTexture returns a texture that stands in for textures that failed to load. Albedo and emissive maps get a
+// magenta checker that stands out, the other types a neutral value since a checker would show up as a bumpy, shiny or
+// dark surface instead.
+func FallbackTexture(texType TextureType) *Texture {
+	if texType != Albedo && texType != Emissive {
+		// a copy, so that the shared texture is left alone when the fallback is replaced
+		texture := *neutralTexture(texType)
+		return &texture
+	}
+	if checkerTextureID == 0 {
+		const size = 8
+		pixels := make([]uint8, 0, size*size*4)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				if (x+y)%2 == 0 {
+					pixels = append(pixels, 255, 0, 255, 255)
+				} else {
+					pixels = append(pixels, 0, 0, 0, 255)
+				}
+			}
+		}
+		gl.GenTextures(1, &checkerTextureID)
+		gl.BindTexture(gl.TEXTURE_2D, checkerTextureID)
+		gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, size, size, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	}
+	return trackTexture(&Texture{
+		ID:          checkerTextureID,
+		textureType: texType,
+		Format:      gl.RGBA,
+		Width:       8,
+		Height:      8,
+		Levels:      1,
+	})
+}
+
+type flatTextureKey struct {
+	textureType TextureType
+	color       [3]float32
+}
+
+var flatTextures = make(map[flatTextureKey]*Texture)
+
+// flatTexture returns a shared single pixel texture, it must not be replaced or deleted
+func flatTexture(texType TextureType, color [3]float32) *Texture {
+	key := flatTextureKey{textureType: texType, color: color}
+	if _, ok := flatTextures[key]; !ok {
+		flatTextures[key] = NewColorTexture(texType, color)
+	}
+	return flatTextures[key]
+}
+
+// neutralColor is the value of a texture type that doesn't change the look of a surface
+func neutralColor(texType TextureType) [3]float32 {
+	switch texType {
+	case Metallic:
+		return [3]float32{0, 0, 0}
+	case Roughness, AO:
+		return [3]float32{1, 1, 1}
+	case Normal:
+		return [3]float32{0.5, 0.5, 1}
+	case Emissive:
+		// the emissive texture is multiplied with the emissive factor of the mesh
+		return [3]float32{1, 1, 1}
+	}
+	return [3]float32{0.5, 0.5, 0.5}
+}
+
+// neutralTexture returns the shared texture that is bound for texture types a mesh doesn't have
+func neutralTexture(texType TextureType) *Texture {
+	return flatTexture(texType, neutralColor(texType))
+}
+
+// placeholderTexture returns a flat texture with a neutral value for the texture type, shown while the real texture
+// is loading
+func placeholderTexture(texType TextureType, channels Channels) *Texture {
+	color := neutralColor(texType)
+	if texType == Packed {
+		for i, c := range channels[:3] {
+			color[i] = neutralColor(c)[0]
+		}
+	}
+	if texType == Emissive {
+		// don't light up the whole surface while the emissive map is loading
+		color = [3]float32{0, 0, 0}
+	}
+	// hand out copies so that the shared texture is left alone when the copy is replaced
+	texture := *flatTexture(texType, color)
+	texture.channels = channels
+	return &texture
+}
+
+// replace points the texture at the OpenGL texture of src, so that everything holding the texture sees the change. The
+// channels of the texture are kept.
+func (t *Texture) replace(src *Texture) {
+	if textures[t.ID] == t {
+		delete(textures, t.ID)
+	}
+	t.ID = src.ID
+	t.Format = src.Format
+	t.Width = src.Width
+	t.Height = src.Height
+	t.Levels = src.Levels
+	t.TopDown = src.TopDown
+	if textures[t.ID] == src {
+		textures[t.ID] = t
+	}
+}
+
+// NewColorTexture returns a single pixel texture with the color, used when a material has a factor but no texture map
+func NewColorTexture(texType TextureType, color [3]float32) *Texture {
+	pixels := []uint8{toUint8(color[0]), toUint8(color[1]), toUint8(color[2]), 255}
+	var textureID uint32
+	gl.GenTextures(1, &textureID)
+	gl.BindTexture(gl.TEXTURE_2D, textureID)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, 1, 1, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	return trackTexture(&Texture{
+		ID:          textureID,
+		textureType: texType,
+		Format:      gl.RGBA,
+		Width:       1,
+		Height:      1,
+		Levels:      1,
+	})
+}
+
+func toUint8(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 255
+	}
+	return uint8(v*255 + 0.5)
+}
+
+// FallbackHDRTexture returns a flat grey environment that stands in for HDR textures that failed to load
+func FallbackHDRTexture() *Texture {
+	const width, height = 4, 2
+	data := make([]float32, width*height*3)
+	for i := range data {
+		data[i] = 0.5
+	}
+	return hdrTexture(width, height, data)
+}
+
 // GetLDRCubeMap will load and return an Texture id for a OpenGL texture cube map
 func GetLDRCubeMap() uint32 {
 	var textureID uint32
@@ -86,21 +364,37 @@ func GetLDRCubeMap() uint32 {
 	return textureID
 }
 
+// NewHDRTexture loads a Radiance RGBE (.hdr) or OpenEXR (.exr) file into a float texture. The format is chosen by the
+// magic number, falling back to the file extension.
 func NewHDRTexture(file string) (*Texture, error) {
 
-	fi, err := os.Open(file)
+	fi, err := assetFS.Open(file)
 	if err != nil {
 		return nil, err
 	}
 	defer fi.Close()
 
-	width, height, data, err := rgbe.Decode(fi)
+	width, height, data, err := decodeHDR(file, bufio.NewReader(fi))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %v", file, err)
 	}
 
-	data = flipImgData(width, height, data)
+	return hdrTexture(width, height, flipImgData(width, height, data)), nil
+}
+
+func decodeHDR(file string, r *bufio.Reader) (int, int, []float32, error) {
+	magic, _ := r.Peek(len(exr.Magic))
+	isEXR := string(magic) == exr.Magic
+	if !isEXR && !strings.HasPrefix(string(magic), "#?") {
+		isEXR = strings.EqualFold(path.Ext(file), ".exr")
+	}
+	if isEXR {
+		return exr.Decode(r)
+	}
+	return rgbe.Decode(r)
+}
 
+func hdrTexture(width, height int, data []float32) *Texture {
 	var textureID uint32
 	gl.GenTextures(1, &textureID)
 	gl.BindTexture(gl.TEXTURE_2D, textureID)
@@ -121,23 +415,31 @@ func NewHDRTexture(file string) (*Texture, error) {
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
 
-	return &Texture{
+	return trackTexture(&Texture{
 		ID:          textureID,
 		textureType: Albedo,
-	}, nil
+		Format:      gl.RGB32F,
+		Width:       int32(width),
+		Height:      int32(height),
+		Levels:      1,
+	})
 }
 
 func loadImage(file string) (*image.RGBA, error) {
-	imgFile, err := os.Open(file)
+	imgFile, err := assetFS.Open(file)
 	if err != nil {
-		return nil, fmt.Errorf("Texture %q not found on disk: %v", file, err)
+		return nil, fmt.Errorf("Texture %q not found: %v", file, err)
 	}
+	defer imgFile.Close()
 
 	img, _, err := image.Decode(imgFile)
 	if err != nil {
 		return nil, err
 	}
+	return toRGBA(img)
+}
 
+func toRGBA(img image.Image) (*image.RGBA, error) {
 	rgba := image.NewRGBA(img.Bounds())
 	if rgba.Stride != rgba.Rect.Size().X*4 {
 		return nil, fmt.Errorf("unsupported stride %d", rgba.Stride)
@@ -147,24 +449,63 @@ func loadImage(file string) (*image.RGBA, error) {
 }
 
 func newTexture(name TextureType, file string, gammaCorrect bool) (*Texture, error) {
-	imgFile, err := os.Open(file)
+	data, err := decodeTexture(file)
 	if err != nil {
-		return nil, fmt.Errorf("Texture %q not found on disk: %v", file, err)
+		return nil, err
 	}
-	img, _, err := image.Decode(imgFile)
+	return data.upload(name, gammaCorrect)
+}
+
+// textureData is a decoded texture file in OpenGL row order, or a GPU image in the row order of its file. Decoding
+// doesn't use OpenGL so it can be done on any goroutine, while upload must be called on the main thread.
+type textureData struct {
+	file string
+	rgba *image.RGBA
+	gpu  *gpuimage.Image
+}
+
+func decodeTexture(file string) (*textureData, error) {
+	imgFile, err := assetFS.Open(file)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Texture %q not found: %v", file, err)
 	}
+	defer imgFile.Close()
 
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return nil, fmt.Errorf("unsupported stride %d", rgba.Stride)
+	switch strings.ToLower(path.Ext(file)) {
+	case ".dds", ".ktx", ".ktx2":
+		// top down images are uploaded as they are, not every block compressed format can be flipped
+		img, err := gpuimage.Decode(imgFile)
+		if err != nil {
+			return nil, fmt.Errorf("Texture %q could not be decoded: %v", file, err)
+		}
+		return &textureData{file: file, gpu: img}, nil
 	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 
+	img, _, err := image.Decode(imgFile)
+	if err != nil {
+		return nil, fmt.Errorf("Texture %q could not be decoded: %v", file, err)
+	}
+	rgba, err := toRGBA(img)
+	if err != nil {
+		return nil, fmt.Errorf("Texture %q: %v", file, err)
+	}
 	// flip it into open GL format
-	rgba = flip(rgba)
+	return &textureData{file: file, rgba: flip(rgba)}, nil
+}
 
+func (d *textureData) upload(name TextureType, gammaCorrect bool) (*Texture, error) {
+	if d.gpu != nil {
+		texture, err := newGPUTexture(name, d.gpu, gammaCorrect)
+		if err != nil {
+			return nil, fmt.Errorf("Texture %q: %v", d.file, err)
+		}
+		return texture, nil
+	}
+	return newRGBATexture(name, d.rgba, gammaCorrect), nil
+}
+
+// newRGBATexture uploads an image that is already flipped into OpenGL order into a mipmapped texture
+func newRGBATexture(name TextureType, rgba *image.RGBA, gammaCorrect bool) *Texture {
 	var texture uint32
 	gl.GenTextures(1, &texture)
 
@@ -175,13 +516,14 @@ func newTexture(name TextureType, file string, gammaCorrect bool) (*Texture, err
 	if gammaCorrect {
 		internalFormat = gl.SRGB8_ALPHA8
 	}
+	width, height := int32(rgba.Rect.Size().X), int32(rgba.Rect.Size().Y)
 
 	gl.TexImage2D(
 		gl.TEXTURE_2D,
 		0,
 		internalFormat,
-		int32(rgba.Rect.Size().X),
-		int32(rgba.Rect.Size().Y),
+		width,
+		height,
 		0,
 		gl.RGBA,
 		gl.UNSIGNED_BYTE,
@@ -193,10 +535,130 @@ func newTexture(name TextureType, file string, gammaCorrect bool) (*Texture, err
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
 	gl.GenerateMipmap(gl.TEXTURE_2D)
 
-	return &Texture{
+	return trackTexture(&Texture{
+		ID:          texture,
+		textureType: name,
+		Format:      uint32(internalFormat),
+		Width:       width,
+		Height:      height,
+		Levels:      mipLevels(width, height),
+	})
+}
+
+// S3TC sRGB formats from EXT_texture_sRGB, which are not part of the core profile bindings
+const (
+	compressedSRGBAlphaS3TCDXT1 = 0x8C4D
+	compressedSRGBAlphaS3TCDXT5 = 0x8C4F
+)
+
+type glImageFormat struct {
+	internal uint32
+	srgb     uint32 // the sRGB internal format, 0 if there is none
+	format   uint32
+	xtype    uint32
+}
+
+var glImageFormats = map[gpuimage.Format]glImageFormat{
+	gpuimage.R8:      {gl.R8, 0, gl.RED, gl.UNSIGNED_BYTE},
+	gpuimage.RG8:     {gl.RG8, 0, gl.RG, gl.UNSIGNED_BYTE},
+	gpuimage.RGBA8:   {gl.RGBA8, gl.SRGB8_ALPHA8, gl.RGBA, gl.UNSIGNED_BYTE},
+	gpuimage.BGRA8:   {gl.RGBA8, gl.SRGB8_ALPHA8, gl.BGRA, gl.UNSIGNED_BYTE},
+	gpuimage.RGBA16F: {gl.RGBA16F, 0, gl.RGBA, gl.HALF_FLOAT},
+	gpuimage.RGBA32F: {gl.RGBA32F, 0, gl.RGBA, gl.FLOAT},
+	gpuimage.BC1:     {gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, compressedSRGBAlphaS3TCDXT1, 0, 0},
+	gpuimage.BC3:     {gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, compressedSRGBAlphaS3TCDXT5, 0, 0},
+	gpuimage.BC5:     {gl.COMPRESSED_RG_RGTC2, 0, 0, 0},
+	gpuimage.BC7:     {gl.COMPRESSED_RGBA_BPTC_UNORM_ARB, gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM_ARB, 0, 0},
+}
+
+// compressedBlockSizes is the number of bytes per 4x4 block for the compressed internal formats
+var compressedBlockSizes = map[uint32]int{
+	gl.COMPRESSED_RGBA_S3TC_DXT1_EXT:        8,
+	compressedSRGBAlphaS3TCDXT1:             8,
+	gl.COMPRESSED_RGBA_S3TC_DXT5_EXT:        16,
+	compressedSRGBAlphaS3TCDXT5:             16,
+	gl.COMPRESSED_RG_RGTC2:                  16,
+	gl.COMPRESSED_RGBA_BPTC_UNORM_ARB:       16,
+	gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM_ARB: 16,
+}
+
+// twoChannel returns true for the red-green formats, normal maps in them only store x and y
+func (t *Texture) twoChannel() bool {
+	return t.Format == gl.RG8 || t.Format == gl.COMPRESSED_RG_RGTC2
+}
+
+// pixelSizes is the number of bytes per pixel for the uncompressed internal formats
+var pixelSizes = map[uint32]int{
+	gl.R8:           1,
+	gl.RG8:          2,
+	gl.RGBA:         4,
+	gl.RGBA8:        4,
+	gl.SRGB8_ALPHA8: 4,
+	gl.RGBA16F:      8,
+	gl.RGB32F:       12,
+	gl.RGBA32F:      16,
+}
+
+// newGPUTexture uploads a DDS or KTX image with its prebuilt mip levels, compressed formats are uploaded as they are
+func newGPUTexture(name TextureType, img *gpuimage.Image, gammaCorrect bool) (*Texture, error) {
+	f, ok := glImageFormats[img.Format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %s", img.Format)
+	}
+	internalFormat := f.internal
+	if (img.SRGB || gammaCorrect) && f.srgb != 0 {
+		internalFormat = f.srgb
+	}
+
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	// single and dual channel rows are not aligned to 4 bytes
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	for level, data := range img.Levels {
+		w, h := img.LevelDimensions(level)
+		if img.Format.Compressed() {
+			gl.CompressedTexImage2D(gl.TEXTURE_2D, int32(level), internalFormat, int32(w), int32(h), 0, int32(len(data)), gl.Ptr(data))
+		} else {
+			gl.TexImage2D(gl.TEXTURE_2D, int32(level), int32(internalFormat), int32(w), int32(h), 0, f.format, f.xtype, gl.Ptr(data))
+		}
+	}
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+
+	levels := int32(len(img.Levels))
+	if levels == 1 && !img.Format.Compressed() {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+		levels = mipLevels(int32(img.Width), int32(img.Height))
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, levels-1)
+
+	// let greyscale textures be sampled from any of the colour channels
+	if img.Format == gpuimage.R8 {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_SWIZZLE_G, gl.RED)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_SWIZZLE_B, gl.RED)
+	}
+
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+
+	if err := gl.GetError(); err != gl.NO_ERROR {
+		gl.DeleteTextures(1, &texture)
+		return nil, fmt.Errorf("uploading %s texture failed with OpenGL error 0x%x", img.Format, err)
+	}
+
+	return trackTexture(&Texture{
 		ID:          texture,
 		textureType: name,
-	}, nil
+		Format:      internalFormat,
+		Width:       int32(img.Width),
+		Height:      int32(img.Height),
+		Levels:      levels,
+		TopDown:     !img.BottomUp,
+	}), nil
 }
 
 // flip the image upside down so that opengl can use it as a texture properly
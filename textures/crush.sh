@@ -1,7 +0,0 @@
-#!/bin/sh
-for png in `find $1 -name "*.png"`;
-do
-echo "crushing $png"
-	pngcrush -rem allb -brute "$png" temp.png
-	mv -f temp.png $png
-done;
\ No newline at end of file
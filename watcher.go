@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileWatcher polls directories for files that have been created or modified, it's a simple alternative to OS
+// specific file notifications
+type fileWatcher struct {
+	dirs     []string
+	modTimes map[string]time.Time
+	scanned  bool
+}
+
+func newFileWatcher(dirs ...string) *fileWatcher {
+	w := &fileWatcher{
+		dirs:     dirs,
+		modTimes: make(map[string]time.Time),
+	}
+	// record the current state so that only later changes are reported
+	w.scan()
+	return w
+}
+
+// scan returns the files that changed since the previous scan
+func (w *fileWatcher) scan() []string {
+	var changed []string
+	for _, dir := range w.dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			modTime, seen := w.modTimes[path]
+			if seen && modTime.Equal(info.ModTime()) {
+				return nil
+			}
+			w.modTimes[path] = info.ModTime()
+			// new files are reported too, except on the first scan
+			if w.scanned {
+				changed = append(changed, path)
+			}
+			return nil
+		})
+	}
+	w.scanned = true
+	return changed
+}
+
+// Watch scans the directories every interval on a separate goroutine and calls changed with each changed file. A
+// file is only reported once its modification time has been stable for one interval, so that files that are still
+// being written are not picked up.
+func (w *fileWatcher) Watch(interval time.Duration, changed func(file string)) {
+	go func() {
+		pending := make(map[string]bool)
+		for range time.Tick(interval) {
+			current := make(map[string]bool)
+			for _, file := range w.scan() {
+				current[file] = true
+			}
+			for file := range pending {
+				if !current[file] {
+					changed(file)
+				}
+			}
+			pending = current
+		}
+	}()
+}